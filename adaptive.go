@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// adaptiveBatch adds cursor_adaptive, which tunes its FETCH size per batch
+// toward adaptiveTarget without holding more than adaptiveMaxBytes of rows
+// per batch.
+var (
+	adaptiveBatch    bool
+	adaptiveTarget   = 100 * time.Millisecond
+	adaptiveMaxBytes = int64(16 << 20)
+)
+
+// The FETCH sizes cursor_adaptive starts with and stays between.
+const (
+	adaptiveStart   = 10
+	adaptiveMaxSize = 1 << 20
+	// adaptiveSettled is how many batches in a row have to keep the size
+	// before it counts as the chosen one.
+	adaptiveSettled = 5
+)
+
+// AdaptiveStats describes how cursor_adaptive tuned its FETCH size.
+type AdaptiveStats struct {
+	Target   time.Duration
+	MaxBytes int64
+	// Size is the FETCH size the strategy ended with, and Settled the batch
+	// from which on it kept it, zero when it never settled.
+	Size    int
+	Settled int
+	Changes int
+}
+
+// loadAdaptive reads ADAPTIVE_BATCH, ADAPTIVE_TARGET and ADAPTIVE_MAX_BYTES.
+func loadAdaptive() error {
+	adaptiveBatch = os.Getenv("ADAPTIVE_BATCH") == "true"
+	if t := os.Getenv("ADAPTIVE_TARGET"); t != "" {
+		target, err := time.ParseDuration(t)
+		if err != nil || target <= 0 {
+			return fmt.Errorf("ADAPTIVE_TARGET must be a positive duration: %s", t)
+		}
+		adaptiveTarget = target
+	}
+	if b := os.Getenv("ADAPTIVE_MAX_BYTES"); b != "" {
+		bytes, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || bytes <= 0 {
+			return fmt.Errorf("ADAPTIVE_MAX_BYTES must be a positive number: %s", b)
+		}
+		adaptiveMaxBytes = bytes
+	}
+	return nil
+}
+
+// batchTuner picks the next FETCH size from the latency and bytes of the
+// last batch. It doubles the size while batches stay under half the target
+// and budget and halves it once one goes over, then only grows half way to
+// the smallest size that went over, so it converges instead of swinging
+// between two sizes.
+type batchTuner struct {
+	size int
+	// ceiling is the smallest size that went over, zero until one did.
+	ceiling int
+}
+
+func (t *batchTuner) next(latency time.Duration, bytes int64) int {
+	switch {
+	case latency > adaptiveTarget || bytes > adaptiveMaxBytes:
+		if t.ceiling == 0 || t.size < t.ceiling {
+			t.ceiling = t.size
+		}
+		t.size = max(adaptiveStart, t.size/2)
+	case latency < adaptiveTarget/2 && bytes < adaptiveMaxBytes/2:
+		grown := t.size * 2
+		if t.ceiling > 0 && grown >= t.ceiling {
+			grown = (t.size + t.ceiling) / 2
+		}
+		t.size = min(adaptiveMaxSize, max(t.size, grown))
+	}
+	return t.size
+}
+
+// fetchWithAdaptiveCursor fetches a cursor like the cursor strategy, starting
+// with a small FETCH size and tuning it after every batch.
+func fetchWithAdaptiveCursor(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:     "cursor_adaptive",
+		Adaptive: &AdaptiveStats{Target: adaptiveTarget, MaxBytes: adaptiveMaxBytes},
+	}
+	stats := result.Adaptive
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	sizes := newRowSizeRecorder()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer func() { tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, declareCursorQuery("adaptive_cursor", 0)); err != nil {
+		err = fmt.Errorf("failed to declare cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	if err := out.WriteHeader(projection); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	tuner := &batchTuner{size: adaptiveStart}
+	size := tuner.size
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := tx.Query(bctx, fetchCursorSizeQuery("adaptive_cursor", size))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var count, firstId, lastId int
+		var bytes int64
+		for rows.Next() {
+			record, aid, err := scanRecord(rows)
+			if err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+			bytes += int64(n)
+
+			if count == 0 {
+				firstId = aid
+			}
+			lastId = aid
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		if count == 0 {
+			break
+		}
+
+		latency := time.Since(batchStart)
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: latency,
+
+			QueryTimings: *timings,
+		})
+
+		// A short last batch says nothing about its size
+		if count == size {
+			if next := tuner.next(latency, bytes); next != size {
+				size = next
+				stats.Changes++
+				stats.Settled = 0
+			} else if stats.Settled == 0 {
+				stats.Settled = len(result.Batches)
+			}
+		}
+
+		result.Paused += control.Wait(ctx)
+	}
+
+	if _, err := tx.Exec(ctx, "CLOSE adaptive_cursor"); err != nil {
+		err = fmt.Errorf("failed to close cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	stats.Size = size
+	if stats.Settled > 0 && len(result.Batches)-stats.Settled+1 < adaptiveSettled {
+		stats.Settled = 0
+	}
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// describeAdaptive reports the FETCH size cursor_adaptive chose.
+func describeAdaptive(r Result) string {
+	a := r.Adaptive
+	if a.Settled == 0 {
+		return fmt.Sprintf("  %s did not settle on a FETCH size in %d batches, ended with %d after %d changes (target %s, at most %s bytes per batch)",
+			r.Type, len(r.Batches), a.Size, a.Changes, a.Target, human.Int(a.MaxBytes))
+	}
+	return fmt.Sprintf("  %s settled on FETCH %d from batch %d after %d changes (target %s, at most %s bytes per batch), set DATA_BATCH_SIZE=%d",
+		r.Type, a.Size, a.Settled, a.Changes, a.Target, human.Int(a.MaxBytes), a.Size)
+}
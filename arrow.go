@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// arrowIPC is the Arrow IPC format of the exports, file (Feather v2), which
+// readers can seek in, or stream.
+var arrowIPC = "file"
+
+// arrowBatchRows is the number of rows of an Arrow record batch. Zero flushes
+// a batch with every batch the strategy records, so record batches line up
+// with the fetches, see endArrowBatch.
+var arrowBatchRows int
+
+// arrowSinks are the open Arrow sinks that flush with the batches of their
+// strategy, by strategy.
+var arrowSinks sync.Map
+
+// loadArrow reads the ARROW_* variables.
+func loadArrow() error {
+	arrowIPC = envOr("ARROW_IPC", "file")
+	if arrowIPC != "file" && arrowIPC != "stream" {
+		return fmt.Errorf("ARROW_IPC must be file or stream, got %s", arrowIPC)
+	}
+	if r := os.Getenv("ARROW_BATCH_ROWS"); r != "" {
+		rows, err := strconv.Atoi(r)
+		if err != nil || rows <= 0 {
+			return fmt.Errorf("ARROW_BATCH_ROWS must be a positive number: %s", r)
+		}
+		arrowBatchRows = rows
+	}
+	return nil
+}
+
+// arrowWriter is the writer of either Arrow IPC format.
+type arrowWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// arrowSink writes the rows as Arrow record batches with one string column
+// per header column. Values are kept as the strings the CSV would hold.
+//
+// With ARROW_BATCH_ROWS a batch is flushed to the destination every
+// arrowBatchRows rows, without when the strategy records a batch.
+type arrowSink struct {
+	name    string
+	dst     destination
+	w       arrowWriter
+	b       *array.RecordBuilder
+	fields  []*array.StringBuilder
+	rows    int
+	metrics *recordMetrics
+	// err is the error of a flush at the end of a batch, returned by the
+	// next write.
+	err error
+}
+
+func newArrowSink(dst destination, name string) *arrowSink {
+	s := &arrowSink{name: name, dst: dst, metrics: newRecordMetrics(dst)}
+	if arrowBatchRows == 0 {
+		arrowSinks.Store(name, s)
+	}
+	return s
+}
+
+// endArrowBatch flushes the rows of the batch the strategy just recorded as
+// one record batch, if the strategy writes to an Arrow sink. Strategies
+// record a batch on the goroutine that writes its rows, or while holding the
+// lock the writes hold.
+func endArrowBatch(strategy string) {
+	v, ok := arrowSinks.Load(strategy)
+	if !ok {
+		return
+	}
+	s := v.(*arrowSink)
+	if s.w == nil {
+		return
+	}
+	if err := s.flush(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *arrowSink) WriteHeader(columns []string) error {
+	fields := make([]arrow.Field, len(columns))
+	for i, column := range columns {
+		fields[i] = arrow.Field{Name: column, Type: arrow.BinaryTypes.String}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	if arrowIPC == "stream" {
+		s.w = ipc.NewWriter(s.dst, ipc.WithSchema(schema))
+	} else {
+		w, err := ipc.NewFileWriter(s.dst, ipc.WithSchema(schema))
+		if err != nil {
+			return fmt.Errorf("error writing Arrow schema: %v", err)
+		}
+		s.w = w
+	}
+
+	s.b = array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	s.fields = make([]*array.StringBuilder, len(columns))
+	for i := range columns {
+		s.fields[i] = s.b.Field(i).(*array.StringBuilder)
+	}
+	return nil
+}
+
+func (s *arrowSink) WriteRow(record []string) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	size := 0
+	for i, value := range record {
+		s.fields[i].Append(value)
+		size += len(value)
+	}
+	s.rows++
+	s.metrics.observe(record)
+
+	if arrowBatchRows > 0 && s.rows >= arrowBatchRows {
+		if err := s.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// flush writes the rows built so far as one record batch.
+func (s *arrowSink) flush() error {
+	if s.rows == 0 {
+		return nil
+	}
+	rec := s.b.NewRecord()
+	defer rec.Release()
+	s.rows = 0
+	if err := s.w.Write(rec); err != nil {
+		return fmt.Errorf("error writing record to Arrow: %v", err)
+	}
+	return nil
+}
+
+func (s *arrowSink) Finalize() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.w == nil {
+		if err := s.WriteHeader(nil); err != nil {
+			return err
+		}
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if err := s.w.Close(); err != nil {
+		return fmt.Errorf("error writing record to Arrow: %v", err)
+	}
+	return s.dst.Finalize()
+}
+
+func (s *arrowSink) Close() error {
+	arrowSinks.CompareAndDelete(s.name, s)
+	if s.b != nil {
+		s.b.Release()
+		s.b = nil
+	}
+	return s.dst.Close()
+}
+
+func (s *arrowSink) Stats() WriteStats {
+	return s.dst.Stats()
+}
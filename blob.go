@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// blobFormat is how the blob scenario writes bytea values: hex, base64, skip
+// or file.
+var blobFormat = "hex"
+
+// blobSize is the size in bytes of the values the blob scenario seeds.
+var blobSize = 8192
+
+// blobLargeObjects makes the blob scenario also store every value as a large
+// object and stream it back.
+var blobLargeObjects bool
+
+const blobTable = "bench_blobs"
+
+// BlobStats accounts for the binary values a strategy exported.
+type BlobStats struct {
+	Values int
+	// Bytes is the raw size of the values, Encoded what they took in the
+	// export and Files what was written to external files.
+	Bytes   int64
+	Encoded int64
+	Files   int64
+}
+
+// seedBlobs creates the blob scenario table with a random payload of
+// blobSize bytes per row, which TOAST can not compress away.
+func seedBlobs(ctx context.Context, rows int) error {
+	start := time.Now()
+
+	statements := []string{
+		// Large objects outlive the rows referencing them
+		fmt.Sprintf(`DO $$ BEGIN
+			IF to_regclass('%[1]s') IS NOT NULL THEN
+				PERFORM lo_unlink(lo) FROM %[1]s WHERE lo IS NOT NULL;
+			END IF;
+		END $$`, blobTable),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", blobTable),
+		fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, payload bytea, lo oid)", blobTable),
+		fmt.Sprintf(`
+			INSERT INTO %s (id, payload)
+			SELECT g, substring(r.payload FROM 1 FOR %d)
+			FROM generate_series(1, %d) g,
+			LATERAL (
+				SELECT decode(string_agg(md5(random()::text || g || i), ''), 'hex') AS payload
+				FROM generate_series(1, %d) i
+			) r`, blobTable, blobSize, rows, (blobSize+15)/16),
+	}
+	if blobLargeObjects {
+		statements = append(statements, fmt.Sprintf("UPDATE %s SET lo = lo_from_bytea(0, payload)", blobTable))
+	}
+	statements = append(statements, fmt.Sprintf("VACUUM ANALYZE %s", blobTable))
+
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", blobTable, err)
+		}
+	}
+
+	var size string
+	err := pool.QueryRow(ctx, "SELECT pg_size_pretty(pg_total_relation_size($1::regclass))", blobTable).Scan(&size)
+	if err != nil {
+		return fmt.Errorf("failed to read size of %s: %w", blobTable, err)
+	}
+	fmt.Printf("seeded %d rows of %d bytes into %s (%s) in %.2f second\n",
+		rows, blobSize, blobTable, size, time.Since(start).Seconds())
+	return nil
+}
+
+// blobEncoder encodes binary values for the export and accounts for them.
+type blobEncoder struct {
+	dir   string
+	stats BlobStats
+}
+
+func newBlobEncoder(name string) (*blobEncoder, error) {
+	e := &blobEncoder{}
+	if blobFormat == "file" {
+		e.dir = outputPath(name + ".blobs")
+		if err := os.RemoveAll(e.dir); err != nil {
+			return nil, fmt.Errorf("error removing previous blobs: %v", err)
+		}
+		if err := os.MkdirAll(e.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating blob directory: %v", err)
+		}
+	}
+	return e, nil
+}
+
+// Encode returns the CSV field for a value. name identifies the value when it
+// is written to an external file.
+func (e *blobEncoder) Encode(name string, data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+
+	var field string
+	switch blobFormat {
+	case "hex":
+		// The bytea output format of PostgreSQL and COPY
+		field = `\x` + hex.EncodeToString(data)
+	case "base64":
+		field = base64.StdEncoding.EncodeToString(data)
+	case "skip":
+	case "file":
+		path := filepath.Join(e.dir, name+".bin")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return "", fmt.Errorf("error writing blob: %v", err)
+		}
+		e.stats.Files += int64(len(data))
+		field = path
+	default:
+		return "", fmt.Errorf("unknown blob format %q", blobFormat)
+	}
+
+	e.stats.Values++
+	e.stats.Bytes += int64(len(data))
+	e.stats.Encoded += int64(len(field))
+	return field, nil
+}
+
+type blobRow struct {
+	id      int64
+	payload []byte
+	lo      *uint32
+}
+
+// fetchWithBlobs pages through the blob scenario table by id, exporting the
+// bytea column and, with blobLargeObjects, the large object of every row.
+func fetchWithBlobs(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "blobs",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := []string{"id", "payload"}
+	if blobLargeObjects {
+		header = append(header, "lo")
+	}
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	blobs, err := newBlobEncoder(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	var lastId int64
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		// Large objects can only be read inside a transaction
+		tx, err := pool.Begin(bctx)
+		if err != nil {
+			err = fmt.Errorf("failed to begin transaction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		page, err := readBlobPage(bctx, tx, lastId, len(result.Batches) == 0)
+		if err != nil {
+			tx.Rollback(ctx)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if len(page) == 0 {
+			tx.Rollback(ctx)
+			break
+		}
+
+		for _, row := range page {
+			payload, err := blobs.Encode(fmt.Sprintf("%d", row.id), row.payload)
+			if err != nil {
+				tx.Rollback(ctx)
+				result.Err = err
+				res <- result
+				return err
+			}
+			record := []string{fmt.Sprintf("%d", row.id), payload}
+
+			if blobLargeObjects {
+				data, err := readLargeObject(bctx, tx, row.lo)
+				if err != nil {
+					tx.Rollback(ctx)
+					result.Err = err
+					res <- result
+					return err
+				}
+				lo, err := blobs.Encode(fmt.Sprintf("%d.lo", row.id), data)
+				if err != nil {
+					tx.Rollback(ctx)
+					result.Err = err
+					res <- result
+					return err
+				}
+				record = append(record, lo)
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				tx.Rollback(ctx)
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		firstId := page[0].id
+		lastId = page[len(page)-1].id
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("id %d..%d", firstId, lastId),
+			Rows:     len(page),
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Blobs = &blobs.stats
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// readBlobPage reads a whole page before any large object is opened, since
+// the connection can not run other queries while rows are being read.
+func readBlobPage(ctx context.Context, tx pgx.Tx, lastId int64, first bool) ([]blobRow, error) {
+	var args []any
+	if !first {
+		args = append(args, lastId)
+	}
+
+	rows, err := tx.Query(ctx, blobPageQuery(first), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
+	}
+	defer rows.Close()
+
+	var page []blobRow
+	for rows.Next() {
+		var row blobRow
+		if err := rows.Scan(&row.id, &row.payload, &row.lo); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		page = append(page, row)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+	}
+	return page, nil
+}
+
+func readLargeObject(ctx context.Context, tx pgx.Tx, oid *uint32) ([]byte, error) {
+	if oid == nil {
+		return nil, nil
+	}
+
+	objects := tx.LargeObjects()
+	lo, err := objects.Open(ctx, *oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open large object %d: %w", *oid, err)
+	}
+	defer lo.Close()
+
+	data, err := io.ReadAll(lo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read large object %d: %w", *oid, err)
+	}
+	return data, nil
+}
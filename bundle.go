@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bundleExports includes the exported rows in the bundle, which are left out
+// by default as they are usually far larger than everything else.
+var bundleExports bool
+
+// runBundle zips the files of a run, the latest unless a run name or
+// directory is given, into <run>.zip next to the run directory, and uploads
+// the archive with a PUT to BUNDLE_UPLOAD_URL when it is set.
+func runBundle(ctx context.Context, args []string) error {
+	dir := outputPath(latestLink)
+	if len(args) > 0 {
+		dir = args[0]
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			dir = outputPath(args[0])
+		}
+	}
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("error finding run: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err != nil {
+		return fmt.Errorf("%s is not a complete run, it has no manifest", dir)
+	}
+
+	path := dir + ".zip"
+	if err := checkOverwrite([]string{path}); err != nil {
+		return err
+	}
+	files, size, err := writeBundle(dir, path)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	fmt.Printf("bundled %d files of %s, %s bytes, into %s\n", files, dir, human.Int(size), path)
+
+	if url := os.Getenv("BUNDLE_UPLOAD_URL"); url != "" {
+		if err := uploadBundle(ctx, path, url); err != nil {
+			return err
+		}
+		fmt.Printf("uploaded %s\n", path)
+	}
+	return nil
+}
+
+// writeBundle writes every file of the run directory to a zip archive at
+// path, leaving out partial files and, unless BUNDLE_EXPORTS is set, the
+// exports. It returns the number and total size of the files.
+func writeBundle(dir, path string) (int, int64, error) {
+	archive, err := os.Create(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error creating bundle: %w", err)
+	}
+	defer archive.Close()
+
+	w := zip.NewWriter(archive)
+	prefix := filepath.Base(dir)
+	var files int
+	var size int64
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && !bundleExports && strings.HasSuffix(rel, ".blobs") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(rel, partialSuffix) || (!bundleExports && isExport(rel)) {
+			return nil
+		}
+
+		n, err := addToBundle(w, file, filepath.ToSlash(filepath.Join(prefix, rel)))
+		if err != nil {
+			return err
+		}
+		files++
+		size += n
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error bundling %s: %w", dir, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, 0, fmt.Errorf("error writing bundle: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return 0, 0, fmt.Errorf("error writing bundle: %w", err)
+	}
+	return files, size, nil
+}
+
+// isExport tells the exported rows of a strategy from the batch timings,
+// rejected rows and other files next to them.
+func isExport(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+	if strings.HasSuffix(name, ".batches.csv") || strings.HasSuffix(name, ".rejects.csv") {
+		return false
+	}
+	return strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".jsonl") ||
+		strings.HasSuffix(name, ".parquet") || strings.HasSuffix(name, ".arrow") || strings.HasSuffix(name, ".arrows")
+}
+
+func addToBundle(w *zip.Writer, file, name string) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return 0, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	entry, err := w.CreateHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(entry, f)
+}
+
+// bundleUploadTimeout bounds the upload of a bundle, which can hold the
+// exports.
+const bundleUploadTimeout = 10 * time.Minute
+
+// uploadBundle PUTs the bundle to url, such as a presigned URL of an object
+// storage bucket.
+func uploadBundle(ctx context.Context, path, url string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening bundle: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("error opening bundle: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, f)
+	if err != nil {
+		return fmt.Errorf("error uploading bundle: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+
+	client := &http.Client{Timeout: bundleUploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error uploading bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("error uploading bundle: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// flushBuffers evicts the benchmark table from shared_buffers by loading a
+// table larger than shared_buffers into it. A plain sequential scan would not
+// do, because large scans go through a small ring buffer that leaves the rest
+// of the cache untouched, so pg_prewarm is used to read it into the main pool.
+func flushBuffers(ctx context.Context, table string) error {
+	var tableBytes, sharedBytes int64
+	err := pool.QueryRow(ctx, `
+		SELECT pg_relation_size($1::regclass),
+			(SELECT setting::bigint * pg_size_bytes(unit) FROM pg_settings WHERE name = 'shared_buffers')`,
+		table).Scan(&tableBytes, &sharedBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read cache flusher size: %w", err)
+	}
+	if tableBytes < sharedBytes {
+		fmt.Printf("cache flusher %s (%d bytes) is smaller than shared_buffers (%d bytes), eviction will be partial\n",
+			table, tableBytes, sharedBytes)
+	}
+
+	if _, err := pool.Exec(ctx, "SELECT pg_prewarm($1::regclass, 'buffer')", table); err != nil {
+		return fmt.Errorf("failed to read cache flusher: %w", err)
+	}
+	return nil
+}
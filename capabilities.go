@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServerCapabilities are the features of the server the generated SQL adapts
+// to, detected from its version at the start of every run.
+type ServerCapabilities struct {
+	Version    string `json:"version"`
+	VersionNum int    `json:"version_num"`
+	// WithTies is FETCH FIRST ... WITH TIES, from PostgreSQL 13.
+	WithTies bool `json:"with_ties"`
+	// Merge is the MERGE statement, from PostgreSQL 15, and MergeReturning
+	// its RETURNING clause, from PostgreSQL 17.
+	Merge          bool `json:"merge"`
+	MergeReturning bool `json:"merge_returning"`
+	// Checkpointer is the pg_stat_checkpointer view, which took over the
+	// checkpoint counters of pg_stat_bgwriter in PostgreSQL 17.
+	Checkpointer bool `json:"checkpointer"`
+}
+
+// server are the capabilities of the server the run is connected to.
+var server ServerCapabilities
+
+// detectCapabilities reads the version of the server and derives what it
+// supports.
+func detectCapabilities(ctx context.Context) (ServerCapabilities, error) {
+	var c ServerCapabilities
+	err := pool.QueryRow(ctx, "SELECT current_setting('server_version'), current_setting('server_version_num')::int").Scan(&c.Version, &c.VersionNum)
+	if err != nil {
+		return c, fmt.Errorf("failed to read server version: %w", err)
+	}
+	c.WithTies = c.VersionNum >= 130000
+	c.Merge = c.VersionNum >= 150000
+	c.MergeReturning = c.VersionNum >= 170000
+	c.Checkpointer = c.VersionNum >= 170000
+	return c, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Checkpoint is a checkpoint observed while the strategies were running.
+type Checkpoint struct {
+	Time      time.Time
+	Requested bool
+}
+
+// checkpointWatcher polls the checkpoint counters and records the time at which
+// each of them moved. pg_stat_checkpointer replaced the checkpoint columns of
+// pg_stat_bgwriter in PostgreSQL 17.
+type checkpointWatcher struct {
+	interval time.Duration
+	query    string
+
+	mu          sync.Mutex
+	checkpoints []Checkpoint
+	timed       int64
+	requested   int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func watchCheckpoints(ctx context.Context, interval time.Duration) (*checkpointWatcher, error) {
+	w := &checkpointWatcher{
+		interval: interval,
+		query:    "SELECT checkpoints_timed, checkpoints_req FROM pg_stat_bgwriter",
+		done:     make(chan struct{}),
+	}
+	if server.Checkpointer {
+		w.query = "SELECT num_timed, num_requested FROM pg_stat_checkpointer"
+	}
+
+	if err := pool.QueryRow(ctx, w.query).Scan(&w.timed, &w.requested); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint stats: %w", err)
+	}
+
+	w.wg.Add(1)
+	go w.run(ctx)
+
+	return w, nil
+}
+
+func (w *checkpointWatcher) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			w.poll(ctx)
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *checkpointWatcher) poll(ctx context.Context) {
+	var timed, requested int64
+	if err := pool.QueryRow(ctx, w.query).Scan(&timed, &requested); err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ; w.timed < timed; w.timed++ {
+		w.checkpoints = append(w.checkpoints, Checkpoint{Time: now})
+	}
+	for ; w.requested < requested; w.requested++ {
+		w.checkpoints = append(w.checkpoints, Checkpoint{Time: now, Requested: true})
+	}
+}
+
+// Stop takes a final snapshot and returns every checkpoint seen during the run.
+func (w *checkpointWatcher) Stop() []Checkpoint {
+	close(w.done)
+	w.wg.Wait()
+	return w.Checkpoints()
+}
+
+func (w *checkpointWatcher) Checkpoints() []Checkpoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Checkpoint(nil), w.checkpoints...)
+}
+
+// near reports whether a checkpoint was observed while the batch was running,
+// allowing one poll interval of slack on either side.
+func (w *checkpointWatcher) near(b Batch) (Checkpoint, bool) {
+	from := b.Start.Add(-w.interval)
+	to := b.Start.Add(b.Duration + w.interval)
+
+	for _, c := range w.Checkpoints() {
+		if !c.Time.Before(from) && !c.Time.After(to) {
+			return c, true
+		}
+	}
+	return Checkpoint{}, false
+}
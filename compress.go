@@ -0,0 +1,116 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// outputCompression compresses the CSV and JSON Lines exports on their way
+// to disk, gzip or zstd, or not at all when empty.
+var outputCompression string
+
+// CompressionStats is what compressing an export took.
+type CompressionStats struct {
+	Codec string
+	// Input is the bytes before compression, the bytes written to the file
+	// are in the Bytes of the WriteStats.
+	Input int64
+	// Duration is the time spent compressing, without writing to the file.
+	Duration time.Duration
+}
+
+func loadCompression(compression string) error {
+	switch compression {
+	case "", "gzip", "zstd":
+	default:
+		return fmt.Errorf("unknown output compression %q, want gzip or zstd", compression)
+	}
+	if compression != "" && outputFormat != "csv" && outputFormat != "jsonl" {
+		return fmt.Errorf("OUTPUT_COMPRESSION only applies to csv and jsonl, %s compresses itself", outputFormat)
+	}
+	outputCompression = compression
+	return nil
+}
+
+// compressionExt is the file extension compression adds to the exports.
+func compressionExt() string {
+	switch outputCompression {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	}
+	return ""
+}
+
+// newCompressor returns the writer compressing into w, or nil without
+// compression.
+func newCompressor(w io.Writer) (io.WriteCloser, error) {
+	switch outputCompression {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		// A single encoder goroutine keeps the compression inside the timed
+		// writes
+		enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("error creating zstd encoder: %v", err)
+		}
+		return enc, nil
+	}
+	return nil, nil
+}
+
+// compressedExts are the file extensions of the compressed exports.
+var compressedExts = []string{".gz", ".zst"}
+
+// openDecompressed opens the file at path and decompresses it by its
+// extension, .gz or .zst, or reads it as is.
+func openDecompressed(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	switch filepath.Ext(path) {
+	case ".gz":
+		r, err = gzip.NewReader(file)
+	case ".zst":
+		var dec *zstd.Decoder
+		dec, err = zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		r = dec.IOReadCloser()
+	default:
+		return file, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error decompressing %s: %v", path, err)
+	}
+	return decompressedFile{Reader: r, file: file}, nil
+}
+
+// decompressedFile closes the decompressor along with the file it reads.
+type decompressedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f decompressedFile) Close() error {
+	if c, ok := f.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return f.file.Close()
+}
+
+// writerFunc turns a function into an io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adaptiveWorkers adds adaptive_parallel, which starts with one worker and
+// lets a controller add workers up to adaptiveWorkers while they pay off,
+// judged every adaptiveInterval.
+var (
+	adaptiveWorkers  int
+	adaptiveInterval = 2 * time.Second
+)
+
+const (
+	// adaptiveMinGain is the share of throughput a worker has to add to be
+	// worth its connection.
+	adaptiveMinGain = 0.05
+	// adaptiveLatencyFactor is how many times the page latency of the first
+	// step pages may take before the server counts as degrading.
+	adaptiveLatencyFactor = 2
+	// adaptiveChunkPages is how many pages of keys a worker takes at a time.
+	adaptiveChunkPages = 10
+)
+
+// ConcurrencyStats describes how adaptive_parallel tuned its worker count.
+type ConcurrencyStats struct {
+	MaxWorkers int
+	Interval   time.Duration
+	Steps      []ConcurrencyStep
+	// Optimal is the fewest workers that came within adaptiveMinGain of the
+	// best throughput, zero when the export ended before the first step.
+	Optimal int
+}
+
+// ConcurrencyStep is the throughput and page latency of one interval at a
+// worker count.
+type ConcurrencyStep struct {
+	Workers       int
+	RowsPerSecond float64
+	Latency       time.Duration
+}
+
+// loadAdaptiveWorkers reads ADAPTIVE_WORKERS and ADAPTIVE_INTERVAL.
+func loadAdaptiveWorkers() error {
+	if w := os.Getenv("ADAPTIVE_WORKERS"); w != "" {
+		workers, err := strconv.Atoi(w)
+		if err != nil || workers < 1 {
+			return fmt.Errorf("ADAPTIVE_WORKERS must be a positive number: %s", w)
+		}
+		adaptiveWorkers = workers
+	}
+	if i := os.Getenv("ADAPTIVE_INTERVAL"); i != "" {
+		interval, err := time.ParseDuration(i)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("ADAPTIVE_INTERVAL must be a positive duration: %s", i)
+		}
+		adaptiveInterval = interval
+	}
+	return nil
+}
+
+// concurrencyController hands out chunks of the key range to the workers of
+// adaptive_parallel and decides how many of them run. It adds one worker at
+// a time while the last one raised the throughput by adaptiveMinGain and
+// pages stay within adaptiveLatencyFactor of their latency in the first
+// step, and halves the workers once either fails. The count that failed
+// becomes a ceiling the workers then only grow back below, so they settle
+// instead of probing it again and again.
+type concurrencyController struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	maxWorkers int
+	workers    int
+	ceiling    int
+	baseline   time.Duration
+	done       bool
+
+	// next is the key the next chunk starts after, up to last.
+	next, last, width int
+
+	// The rows and pages read since windowStart.
+	windowStart time.Time
+	rows, pages int
+	latency     time.Duration
+
+	steps []ConcurrencyStep
+}
+
+func newConcurrencyController(maxWorkers int) *concurrencyController {
+	c := &concurrencyController{maxWorkers: maxWorkers, workers: 1}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// wait blocks the worker while the controller runs fewer workers, and
+// reports whether it should take another chunk.
+func (c *concurrencyController) wait(worker int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for worker >= c.workers && !c.done {
+		c.cond.Wait()
+	}
+	return !c.done
+}
+
+// chunk returns the next chunk of keys, and false once all are handed out.
+func (c *concurrencyController) chunk() (after, end int, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done || c.next >= c.last {
+		c.done = true
+		c.cond.Broadcast()
+		return 0, 0, false
+	}
+	after = c.next
+	c.next = min(c.next+c.width, c.last)
+	return after, c.next, true
+}
+
+// stop wakes the waiting workers so they return.
+func (c *concurrencyController) stop() {
+	c.mu.Lock()
+	c.done = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// record adds a page to the current window.
+func (c *concurrencyController) record(rows int, latency time.Duration) {
+	c.mu.Lock()
+	c.rows += rows
+	c.pages++
+	c.latency += latency
+	c.mu.Unlock()
+}
+
+// step closes the current window and adjusts the workers. A window without a
+// finished page is extended instead.
+func (c *concurrencyController) step(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pages == 0 {
+		return
+	}
+	c.adjust(ConcurrencyStep{
+		Workers:       c.workers,
+		RowsPerSecond: float64(c.rows) / now.Sub(c.windowStart).Seconds(),
+		Latency:       c.latency / time.Duration(c.pages),
+	})
+	c.windowStart, c.rows, c.pages, c.latency = now, 0, 0, 0
+}
+
+// adjust records a step and picks the worker count of the next one.
+func (c *concurrencyController) adjust(step ConcurrencyStep) {
+	var prev *ConcurrencyStep
+	if len(c.steps) > 0 {
+		prev = &c.steps[len(c.steps)-1]
+	}
+	if c.baseline == 0 {
+		c.baseline = step.Latency
+	}
+
+	degraded := step.Latency > c.baseline*adaptiveLatencyFactor
+	flattened := prev != nil && step.Workers > prev.Workers && step.RowsPerSecond < prev.RowsPerSecond*(1+adaptiveMinGain)
+	switch {
+	case degraded || flattened:
+		if c.ceiling == 0 || step.Workers < c.ceiling {
+			c.ceiling = step.Workers
+		}
+		c.workers = max(1, step.Workers/2)
+	case c.workers < c.maxWorkers && (c.ceiling == 0 || c.workers+1 < c.ceiling):
+		c.workers++
+	}
+	c.steps = append(c.steps, step)
+	c.cond.Broadcast()
+}
+
+// optimalWorkers returns the fewest workers whose mean throughput over their
+// steps came within adaptiveMinGain of the best one.
+func optimalWorkers(steps []ConcurrencyStep) int {
+	sums := make(map[int]float64)
+	counts := make(map[int]int)
+	for _, s := range steps {
+		sums[s.Workers] += s.RowsPerSecond
+		counts[s.Workers]++
+	}
+	var best float64
+	for workers, sum := range sums {
+		best = max(best, sum/float64(counts[workers]))
+	}
+
+	optimal := 0
+	for workers, sum := range sums {
+		if sum/float64(counts[workers]) >= best/(1+adaptiveMinGain) && (optimal == 0 || workers < optimal) {
+			optimal = workers
+		}
+	}
+	return optimal
+}
+
+// fetchWithAdaptiveParallel reads the key range in chunks of
+// adaptiveChunkPages pages with up to adaptiveWorkers workers, each keyset
+// paginating through one chunk at a time, while the controller decides how
+// many of them run.
+func fetchWithAdaptiveParallel(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:        "adaptive_parallel",
+		Concurrency: &ConcurrencyStats{MaxWorkers: adaptiveWorkers, Interval: adaptiveInterval},
+	}
+
+	// Open the sink the rows are written to
+	export, err := openParallelExport(&result, adaptiveWorkers)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer export.Close()
+
+	var first, last *int
+	if err := pool.QueryRow(ctx, keyBoundsQuery()).Scan(&first, &last); err != nil {
+		err = fmt.Errorf("failed to read key range: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	c := newConcurrencyController(adaptiveWorkers)
+	if first != nil {
+		c.next, c.last, c.width = *first-1, *last, batchSize*adaptiveChunkPages
+	}
+	if err := runAdaptiveWorkers(ctx, export, c); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.Concurrency.Steps = c.steps
+	result.Concurrency.Optimal = optimalWorkers(c.steps)
+
+	return finishParallel(export, start, result, res)
+}
+
+// runAdaptiveWorkers runs the workers until the chunks are read or one of
+// them failed, stepping the controller every adaptiveInterval, and returns
+// the first error.
+func runAdaptiveWorkers(ctx context.Context, export *parallelExport, c *concurrencyController) error {
+	var wait sync.WaitGroup
+	errs := make(chan error, c.maxWorkers)
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.windowStart = time.Now()
+	finished := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(adaptiveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				c.step(now)
+			case <-finished:
+				return
+			}
+		}
+	}()
+
+	fail := func(err error) {
+		errs <- err
+		cancel()
+		c.stop()
+	}
+	for worker := 0; worker < c.maxWorkers; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for c.wait(worker) {
+				lastId, end, ok := c.chunk()
+				if !ok {
+					return
+				}
+				for wctx.Err() == nil {
+					batchStart := time.Now()
+					bctx, timings := traceQueries(wctx)
+
+					page, pooled, err := readPage(bctx, pool, rangePageQuery(), lastId, end)
+					if err != nil {
+						fail(err)
+						return
+					}
+					latency := time.Since(batchStart)
+
+					// Check if there are no more rows in the chunk
+					if len(page) == 0 {
+						break
+					}
+
+					firstId := page[0].key
+					lastId = page[len(page)-1].key
+					err = export.writePage(ctx, worker, page, timings, Batch{
+						Start: batchStart,
+						Key:   fmt.Sprintf("worker %d %s %d..%d", worker, keyColumn, firstId, lastId),
+						Rows:  len(page),
+					})
+					if err != nil {
+						fail(err)
+						return
+					}
+					c.record(len(page), latency)
+
+					// Hold still while the run is paused
+					paused := control.Wait(ctx)
+					export.mu.Lock()
+					export.result.Paused += paused
+					export.pooling.add(pooled)
+					export.mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	wait.Wait()
+	close(finished)
+	close(errs)
+	return <-errs
+}
+
+// describeConcurrency reports the worker count adaptive_parallel found
+// optimal and the counts it went through.
+func describeConcurrency(r Result) string {
+	s := r.Concurrency
+	if s.Optimal == 0 {
+		return fmt.Sprintf("  %s ended before its first %s step, no worker count found", r.Type, s.Interval)
+	}
+
+	var best, one *ConcurrencyStep
+	counts := make([]string, len(s.Steps))
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		counts[i] = strconv.Itoa(step.Workers)
+		if step.Workers == s.Optimal && (best == nil || step.RowsPerSecond > best.RowsPerSecond) {
+			best = step
+		}
+		if step.Workers == 1 && one == nil {
+			one = step
+		}
+	}
+	line := fmt.Sprintf("  %s found %d of at most %d workers optimal, %s rows/sec at %s per page",
+		r.Type, s.Optimal, s.MaxWorkers, human.Float(best.RowsPerSecond, 0), human.Duration(best.Latency))
+	if one != nil && s.Optimal > 1 {
+		line += fmt.Sprintf(" against %s rows/sec at %s with one", human.Float(one.RowsPerSecond, 0), human.Duration(one.Latency))
+	}
+	return line + fmt.Sprintf("\n  %s ran %s workers in %s steps", r.Type, strings.Join(counts, " "), s.Interval)
+}
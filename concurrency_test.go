@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyControllerAdjust(t *testing.T) {
+	// Throughput rises by a tenth per worker up to five workers and then
+	// stays flat
+	throughput := func(workers int) float64 { return 1000 * (1 + 0.1*float64(min(workers, 5)-1)) }
+
+	c := newConcurrencyController(8)
+	var workers []int
+	for range 12 {
+		workers = append(workers, c.workers)
+		c.adjust(ConcurrencyStep{Workers: c.workers, RowsPerSecond: throughput(c.workers), Latency: time.Millisecond})
+	}
+	want := []int{1, 2, 3, 4, 5, 6, 3, 4, 5, 5, 5, 5}
+	if !slices.Equal(workers, want) {
+		t.Errorf("workers = %v, want %v", workers, want)
+	}
+	if got := optimalWorkers(c.steps); got != 5 {
+		t.Errorf("optimalWorkers = %d, want 5", got)
+	}
+}
+
+func TestConcurrencyControllerLatency(t *testing.T) {
+	c := newConcurrencyController(8)
+	for _, latency := range []time.Duration{10, 12, 15} {
+		c.adjust(ConcurrencyStep{Workers: c.workers, RowsPerSecond: float64(1000 * c.workers), Latency: latency * time.Millisecond})
+	}
+	if c.workers != 4 {
+		t.Fatalf("workers = %d, want 4", c.workers)
+	}
+	c.adjust(ConcurrencyStep{Workers: c.workers, RowsPerSecond: 5000, Latency: 25 * time.Millisecond})
+	if c.workers != 2 || c.ceiling != 4 {
+		t.Errorf("workers = %d and ceiling = %d after degraded latency, want 2 and 4", c.workers, c.ceiling)
+	}
+}
+
+func TestOptimalWorkers(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ConcurrencyStep
+		want  int
+	}{
+		{"none", nil, 0},
+		{"one", []ConcurrencyStep{{Workers: 1, RowsPerSecond: 100}}, 1},
+		{
+			"fewest within the gain",
+			[]ConcurrencyStep{{Workers: 1, RowsPerSecond: 100}, {Workers: 2, RowsPerSecond: 190}, {Workers: 3, RowsPerSecond: 198}},
+			2,
+		},
+		{
+			"mean of the steps",
+			[]ConcurrencyStep{
+				{Workers: 2, RowsPerSecond: 200}, {Workers: 4, RowsPerSecond: 300},
+				{Workers: 2, RowsPerSecond: 100}, {Workers: 4, RowsPerSecond: 300},
+			},
+			4,
+		},
+	}
+	for _, tt := range tests {
+		if got := optimalWorkers(tt.steps); got != tt.want {
+			t.Errorf("%s: optimalWorkers = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
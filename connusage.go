@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ConnUsage is what a strategy cost the connection budget of the server: the
+// pool connections it held and for how long.
+type ConnUsage struct {
+	Acquires int
+	// Peak is the most connections the strategy held at once.
+	Peak int
+	// Held is the connection time, the sum of how long each connection was
+	// held, so two connections held for a second count two seconds.
+	Held time.Duration
+	// Idle is the part of Held no query of the strategy's batches ran in,
+	// such as a cursor's transaction waiting while its rows are written.
+	Idle time.Duration
+}
+
+type connOwnerKey struct{}
+
+// withConnOwner charges the pool connections acquired with ctx to the
+// strategy.
+func withConnOwner(ctx context.Context, strategy string) context.Context {
+	return context.WithValue(ctx, connOwnerKey{}, strategy)
+}
+
+// connUsage meters the pool connections of the strategies of a run through
+// the acquire and release tracers of the pool. Unlike the AfterRelease hook,
+// which runs in the background and is skipped for connections the pool
+// destroys, TraceRelease runs in Release itself for every connection.
+var connUsage = &connMeter{}
+
+type heldConn struct {
+	owner string
+	since time.Time
+}
+
+type connMeter struct {
+	mu     sync.Mutex
+	held   map[*pgx.Conn]heldConn
+	active map[string]int
+	usage  map[string]*ConnUsage
+}
+
+// reset forgets the connections of a previous run.
+func (m *connMeter) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.held = map[*pgx.Conn]heldConn{}
+	m.active = map[string]int{}
+	m.usage = map[string]*ConnUsage{}
+}
+
+func (m *connMeter) acquire(ctx context.Context, conn *pgx.Conn) {
+	owner, ok := ctx.Value(connOwnerKey{}).(string)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.usage == nil {
+		return
+	}
+	usage := m.usage[owner]
+	if usage == nil {
+		usage = &ConnUsage{}
+		m.usage[owner] = usage
+	}
+	m.held[conn] = heldConn{owner: owner, since: time.Now()}
+	m.active[owner]++
+	usage.Acquires++
+	usage.Peak = max(usage.Peak, m.active[owner])
+}
+
+func (m *connMeter) release(conn *pgx.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.held[conn]
+	if !ok {
+		return
+	}
+	delete(m.held, conn)
+	m.active[h.owner]--
+	m.usage[h.owner].Held += time.Since(h.since)
+}
+
+func (queryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return ctx
+}
+
+func (queryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	if data.Err == nil {
+		connUsage.acquire(ctx, data.Conn)
+	}
+}
+
+func (queryTracer) TraceRelease(_ *pgxpool.Pool, data pgxpool.TraceReleaseData) {
+	connUsage.release(data.Conn)
+}
+
+// of returns the connection usage of the strategy, with the time its batches
+// spent in queries taken as busy, or nil if it used no pool connection.
+func (m *connMeter) of(r Result) *ConnUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, ok := m.usage[r.Type]
+	if !ok {
+		return nil
+	}
+	u := *usage
+	var busy time.Duration
+	for _, b := range r.Batches {
+		busy += b.Query
+	}
+	u.Idle = max(0, u.Held-busy)
+	return &u
+}
+
+// describeConnUsage reports the connection time of a strategy next to its
+// duration.
+func describeConnUsage(r Result) string {
+	u := r.Connections
+	return fmt.Sprintf("%s held %d connections at most, %s connection-seconds over %s, %s of them idle between queries",
+		r.Type, u.Peak, human.Float(u.Held.Seconds(), 2), human.Duration(r.Duration), human.Float(u.Idle.Seconds(), 2))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const consistencyTable = "bench_consistency"
+
+// consistencyRows is the size of the scratch table of the consistency demo.
+var consistencyRows = 10000
+
+// ConsistencyPass is what one offset pagination pass over the scratch table
+// observed while a writer was changing it.
+type ConsistencyPass struct {
+	Isolation string  `json:"isolation"`
+	Pages     int     `json:"pages"`
+	Rows      int     `json:"rows"`
+	Writes    int     `json:"writes"`
+	Repeated  []int64 `json:"repeated"`
+	Missed    []int64 `json:"missed"`
+}
+
+// consistencyWriter deletes random rows and inserts rows in front of all
+// others, the two changes that shift OFFSET pages.
+type consistencyWriter struct {
+	mu      sync.Mutex
+	deleted map[int64]bool
+	writes  int
+}
+
+// run writes until stop is closed. It finishes the write it is in before it
+// returns, so every write it records was acknowledged and no write it left
+// out may have committed after all.
+func (w *consistencyWriter) run(ctx context.Context, stop <-chan struct{}, rows int) error {
+	next := int64(0)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		id := rand.Int64N(int64(rows)) + 1
+		if _, err := pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", consistencyTable), id); err != nil {
+			return fmt.Errorf("failed to delete row: %w", err)
+		}
+		w.mu.Lock()
+		w.deleted[id] = true
+		w.writes++
+		w.mu.Unlock()
+
+		next--
+		if _, err := pool.Exec(ctx, fmt.Sprintf("INSERT INTO %s (id) VALUES ($1)", consistencyTable), next); err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+		w.mu.Lock()
+		w.writes++
+		w.mu.Unlock()
+	}
+}
+
+// demonstrateConsistency pages through a scratch table with OFFSET under READ
+// COMMITTED and then inside one REPEATABLE READ transaction, each time while
+// a writer changes the table, and reports the rows each pass repeated or
+// missed.
+func demonstrateConsistency(ctx context.Context) ([]ConsistencyPass, error) {
+	var passes []ConsistencyPass
+	for _, isolation := range []pgx.TxIsoLevel{pgx.ReadCommitted, pgx.RepeatableRead} {
+		pass, err := consistencyPass(ctx, isolation)
+		if err != nil {
+			return nil, err
+		}
+		passes = append(passes, pass)
+	}
+	return passes, nil
+}
+
+func consistencyPass(ctx context.Context, isolation pgx.TxIsoLevel) (ConsistencyPass, error) {
+	pass := ConsistencyPass{Isolation: string(isolation)}
+
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", consistencyTable),
+		fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY)", consistencyTable),
+		fmt.Sprintf("INSERT INTO %s SELECT generate_series(1, %d)", consistencyTable, consistencyRows),
+	}
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return pass, fmt.Errorf("failed to create %s: %w", consistencyTable, err)
+		}
+	}
+
+	// Every page of a READ COMMITTED pass is its own statement, a
+	// REPEATABLE READ pass reads all pages in one transaction
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: isolation})
+	if err != nil {
+		return pass, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { tx.Rollback(ctx) }()
+
+	writer := &consistencyWriter{deleted: make(map[int64]bool)}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	seen := make(map[int64]int)
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf("SELECT id FROM %s ORDER BY id LIMIT %d OFFSET %d",
+			consistencyTable, batchSize, pass.Pages*batchSize))
+		if err != nil {
+			close(stop)
+			return pass, fmt.Errorf("failed to fetch data: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			close(stop)
+			return pass, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// The first page pins the snapshot of a REPEATABLE READ pass, so
+		// the writer starts after it
+		if pass.Pages == 0 {
+			go func() { done <- writer.run(ctx, stop, consistencyRows) }()
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+		for _, id := range ids {
+			seen[id]++
+		}
+		pass.Pages++
+		pass.Rows += len(ids)
+
+		if isolation == pgx.ReadCommitted {
+			// Commit so the next page starts a new transaction
+			if err := tx.Commit(ctx); err != nil {
+				close(stop)
+				return pass, fmt.Errorf("failed to commit transaction: %w", err)
+			}
+			tx, err = pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: isolation})
+			if err != nil {
+				close(stop)
+				return pass, fmt.Errorf("failed to begin transaction: %w", err)
+			}
+		}
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		return pass, err
+	}
+
+	// Rows of the original table that were never deleted must be read
+	// exactly once, all others at most once
+	for id, n := range seen {
+		if n > 1 {
+			pass.Repeated = append(pass.Repeated, id)
+		}
+	}
+	for id := int64(1); id <= int64(consistencyRows); id++ {
+		if seen[id] == 0 && !writer.deleted[id] {
+			pass.Missed = append(pass.Missed, id)
+		}
+	}
+	slices.Sort(pass.Repeated)
+	slices.Sort(pass.Missed)
+	pass.Writes = writer.writes
+
+	return pass, nil
+}
+
+func writeConsistency(path string, passes []ConsistencyPass) error {
+	content, err := json.MarshalIndent(passes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding consistency results: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing consistency results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pausePolicy decides what transaction bound strategies do while paused:
+// "hold" keeps their transaction and cursor open, "release" commits it and
+// declares a new cursor after the last exported row on resume.
+var pausePolicy = "hold"
+
+// control lets a run be paused between batches and resumed later, e.g. to
+// yield to production traffic. Strategies finish their current batch first.
+var control = &pauseControl{}
+
+type pauseControl struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+// Toggle pauses a running benchmark or resumes a paused one.
+func (p *pauseControl) Toggle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resume)
+		fmt.Println("resumed")
+		return
+	}
+
+	p.paused = true
+	p.resume = make(chan struct{})
+	fmt.Println("paused, strategies stop after their current batch")
+}
+
+func (p *pauseControl) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Wait blocks while the run is paused and returns how long it waited.
+func (p *pauseControl) Wait(ctx context.Context) time.Duration {
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return 0
+	}
+	resume := p.resume
+	p.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+	case <-resume:
+	}
+	return time.Since(start)
+}
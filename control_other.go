@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// handlePauseSignals is a no-op where SIGUSR1 does not exist.
+func handlePauseSignals() {}
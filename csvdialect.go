@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// csvDialect is how the CSV exports are written, so they fit the loader that
+// reads them.
+type csvDialect struct {
+	header    bool
+	delimiter byte
+	quote     byte
+	crlf      bool
+}
+
+// exportCSV is the dialect of the CSV exports, RFC 4180 with a header line
+// and LF line endings unless the CSV_* variables change it.
+var exportCSV = csvDialect{header: true, delimiter: ',', quote: '"'}
+
+var csvDelimiters = map[string]byte{
+	"comma": ',',
+	"tab":   '\t',
+	"pipe":  '|',
+}
+
+// loadCSVDialect reads the CSV_* variables.
+func loadCSVDialect() error {
+	d := csvDialect{header: true, delimiter: ',', quote: '"'}
+	if h := os.Getenv("CSV_HEADER"); h != "" {
+		header, err := strconv.ParseBool(h)
+		if err != nil {
+			return fmt.Errorf("CSV_HEADER must be true or false, got %s", h)
+		}
+		d.header = header
+	}
+	if s := os.Getenv("CSV_DELIMITER"); s != "" {
+		c, ok := csvDelimiters[s]
+		if !ok && len(s) != 1 {
+			return fmt.Errorf("CSV_DELIMITER must be comma, tab, pipe or a single character, got %s", s)
+		}
+		if !ok {
+			c = s[0]
+		}
+		d.delimiter = c
+	}
+	if s := os.Getenv("CSV_QUOTE"); s != "" {
+		if len(s) != 1 {
+			return fmt.Errorf("CSV_QUOTE must be a single character, got %s", s)
+		}
+		d.quote = s[0]
+	}
+	switch e := os.Getenv("CSV_LINE_ENDING"); e {
+	case "", "lf":
+	case "crlf":
+		d.crlf = true
+	default:
+		return fmt.Errorf("CSV_LINE_ENDING must be lf or crlf, got %s", e)
+	}
+
+	if !validCSVByte(d.delimiter) || !validCSVByte(d.quote) || d.delimiter == d.quote {
+		return fmt.Errorf("CSV_DELIMITER and CSV_QUOTE must be two different ASCII characters other than a line break")
+	}
+	exportCSV = d
+	return nil
+}
+
+func validCSVByte(c byte) bool {
+	return c != 0 && c != '\r' && c != '\n' && c < utf8.RuneSelf
+}
+
+func (d csvDialect) lineEnding() string {
+	if d.crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// needsQuotes mirrors the quoting rules of encoding/csv.Writer with the
+// dialect's delimiter and quote.
+func (d csvDialect) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` {
+		return true
+	}
+	for i := 0; i < len(field); i++ {
+		if c := field[i]; c == '\n' || c == '\r' || c == d.quote || c == d.delimiter {
+			return true
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
+
+// recordSize returns the number of bytes csvWriter writes for the record.
+func (d csvDialect) recordSize(record []string) int {
+	size := max(len(record)-1, 0) + len(d.lineEnding())
+	for _, field := range record {
+		size += len(field)
+		if d.needsQuotes(field) {
+			size += strings.Count(field, string(d.quote)) + 2
+			if d.crlf {
+				// Line breaks in quoted fields are written as CRLF too
+				size += strings.Count(field, "\n") - strings.Count(field, "\r")
+			}
+		}
+	}
+	return size
+}
+
+// headerSize is the size of the header line, none without a header.
+func (d csvDialect) headerSize(columns []string) int64 {
+	if !d.header {
+		return 0
+	}
+	return int64(d.recordSize(columns))
+}
+
+// copyOptions are the options of a COPY to CSV in the dialect. COPY always
+// ends lines with LF.
+func (d csvDialect) copyOptions() string {
+	options := "FORMAT csv"
+	if d.header {
+		options += ", HEADER"
+	}
+	options += ", DELIMITER " + quoteLiteral(string(d.delimiter))
+	if d.quote != '"' {
+		options += ", QUOTE " + quoteLiteral(string(d.quote))
+	}
+	return options
+}
+
+// csvWriter writes records in a CSV dialect like encoding/csv.Writer, whose
+// quote character is fixed.
+type csvWriter struct {
+	w *bufio.Writer
+	d csvDialect
+	// special are the bytes a quoted field escapes.
+	special string
+}
+
+func newCSVWriter(w io.Writer, d csvDialect) *csvWriter {
+	return &csvWriter{w: bufio.NewWriter(w), d: d, special: "\r\n" + string(d.quote)}
+}
+
+func (w *csvWriter) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteByte(w.d.delimiter)
+		}
+		if !w.d.needsQuotes(field) {
+			w.w.WriteString(field)
+			continue
+		}
+
+		w.w.WriteByte(w.d.quote)
+		for len(field) > 0 {
+			i := strings.IndexAny(field, w.special)
+			if i < 0 {
+				i = len(field)
+			}
+			w.w.WriteString(field[:i])
+			field = field[i:]
+			if len(field) == 0 {
+				break
+			}
+			switch field[0] {
+			case w.d.quote:
+				w.w.WriteByte(w.d.quote)
+				w.w.WriteByte(w.d.quote)
+			case '\r':
+				if !w.d.crlf {
+					w.w.WriteByte('\r')
+				}
+			case '\n':
+				w.w.WriteString(w.d.lineEnding())
+			}
+			field = field[1:]
+		}
+		w.w.WriteByte(w.d.quote)
+	}
+	_, err := w.w.WriteString(w.d.lineEnding())
+	return err
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (w *csvWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var csvRecords = [][]string{
+	{"1", "2", "3"},
+	{"", "plain", ""},
+	{"with,comma", `with "quotes"`, "with\nnewline"},
+	{"with\r\ncrlf", " leading space", "\ttab"},
+	{`\.`, "trailing space ", "ünïcödé"},
+	{"only\rcr", `""`, "a|b"},
+	{},
+	{""},
+}
+
+func TestCSVWriterMatchesEncodingCSV(t *testing.T) {
+	d := csvDialect{header: true, delimiter: ',', quote: '"'}
+	for _, record := range csvRecords {
+		var want bytes.Buffer
+		w := csv.NewWriter(&want)
+		w.Write(record)
+		w.Flush()
+
+		var got bytes.Buffer
+		cw := newCSVWriter(&got, d)
+		if err := cw.Write(record); err != nil {
+			t.Fatalf("Write(%q): %v", record, err)
+		}
+		cw.Flush()
+
+		if got.String() != want.String() {
+			t.Errorf("Write(%q) = %q, want %q", record, got.String(), want.String())
+		}
+		if size := d.recordSize(record); size != want.Len() {
+			t.Errorf("recordSize(%q) = %d, want %d", record, size, want.Len())
+		}
+	}
+}
+
+func TestCSVWriterDialects(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      csvDialect
+		record []string
+		want   string
+	}{
+		{"tab", csvDialect{delimiter: '\t', quote: '"'}, []string{"a,b", "c\td"}, "a,b\t\"c\td\"\n"},
+		{"pipe", csvDialect{delimiter: '|', quote: '"'}, []string{"a|b", "c"}, "\"a|b\"|c\n"},
+		{"single quote", csvDialect{delimiter: ',', quote: '\''}, []string{"it's", `say "hi"`}, "'it''s',say \"hi\"\n"},
+		{"crlf", csvDialect{delimiter: ',', quote: '"', crlf: true}, []string{"a", "b"}, "a,b\r\n"},
+		{"crlf in field", csvDialect{delimiter: ',', quote: '"', crlf: true}, []string{"a\nb", "c\r\nd"}, "\"a\r\nb\",\"c\r\nd\"\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			w := newCSVWriter(&got, tt.d)
+			if err := w.Write(tt.record); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			if got.String() != tt.want {
+				t.Errorf("Write(%q) = %q, want %q", tt.record, got.String(), tt.want)
+			}
+			if size := tt.d.recordSize(tt.record); size != got.Len() {
+				t.Errorf("recordSize(%q) = %d, want %d", tt.record, size, got.Len())
+			}
+		})
+	}
+}
+
+func TestNeedsQuotes(t *testing.T) {
+	tests := []struct {
+		d     csvDialect
+		field string
+		want  bool
+	}{
+		{exportCSV, "", false},
+		{exportCSV, "plain", false},
+		{exportCSV, `\.`, true},
+		{exportCSV, "a,b", true},
+		{exportCSV, `a"b`, true},
+		{exportCSV, "a\nb", true},
+		{exportCSV, "a\rb", true},
+		{exportCSV, " a", true},
+		{exportCSV, "a ", false},
+		{exportCSV, "a|b", false},
+		{csvDialect{delimiter: '|', quote: '"'}, "a|b", true},
+		{csvDialect{delimiter: '|', quote: '"'}, "a,b", false},
+		{csvDialect{delimiter: ',', quote: '\''}, `a"b`, false},
+		{csvDialect{delimiter: ',', quote: '\''}, "a'b", true},
+	}
+	for _, tt := range tests {
+		if got := tt.d.needsQuotes(tt.field); got != tt.want {
+			t.Errorf("needsQuotes(%q) with %c and %c = %v, want %v", tt.field, tt.d.delimiter, tt.d.quote, got, tt.want)
+		}
+	}
+}
+
+func TestFastCSVReaderReadsCSVWriter(t *testing.T) {
+	for _, d := range []csvDialect{
+		{delimiter: ',', quote: '"'},
+		{delimiter: '|', quote: '\''},
+		{delimiter: '\t', quote: '"', crlf: true},
+	} {
+		var buf bytes.Buffer
+		w := newCSVWriter(&buf, d)
+		for _, record := range csvRecords {
+			// An empty record is written as an empty line, which reads back
+			// as one empty field
+			if len(record) == 0 {
+				continue
+			}
+			w.Write(record)
+		}
+		w.Flush()
+
+		r := &fastCSVReader{r: bufio.NewReader(&buf), delimiter: d.delimiter, quote: d.quote}
+		for _, want := range csvRecords {
+			if len(want) == 0 {
+				continue
+			}
+			record, err := r.Read()
+			if err != nil {
+				t.Fatalf("Read with %c and %c: %v", d.delimiter, d.quote, err)
+			}
+			got := make([]string, len(record))
+			for i, field := range record {
+				got[i] = string(field)
+			}
+			if d.crlf {
+				// Like encoding/csv, CRLF drops carriage returns and ends the
+				// lines of quoted fields with CRLF too
+				want = slices.Clone(want)
+				for i := range want {
+					want[i] = strings.ReplaceAll(strings.ReplaceAll(want[i], "\r", ""), "\n", "\r\n")
+				}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("Read with %c and %c = %q, want %q", d.delimiter, d.quote, got, want)
+			}
+		}
+	}
+}
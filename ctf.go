@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cursorTupleFractions are the cursor_tuple_fraction values the cursor is
+// repeated with, one strategy per value. Empty disables them.
+var cursorTupleFractions []float64
+
+// parseFractions parses a comma separated list of fractions between 0 and 1.
+func parseFractions(spec string) ([]float64, error) {
+	var fractions []float64
+	for _, f := range strings.Split(spec, ",") {
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || fraction < 0 || fraction > 1 {
+			return nil, fmt.Errorf("%q is not a fraction between 0 and 1", f)
+		}
+		fractions = append(fractions, fraction)
+	}
+	return fractions, nil
+}
+
+func cursorFractionStrategy(fraction float64) string {
+	return "cursor_ctf_" + strconv.FormatFloat(fraction, 'g', -1, 64)
+}
+
+// fetchWithCursorFraction returns a cursor strategy that plans its cursor with
+// the given cursor_tuple_fraction. Low fractions favor plans that return the
+// first rows quickly, such as an index scan, high fractions plans that finish
+// sooner, such as a sequential scan and sort.
+func fetchWithCursorFraction(fraction float64) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: cursorFractionStrategy(fraction),
+		}
+
+		// Open the sink the rows are written to
+		out, err := openSink(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		header := projection
+		if err := out.WriteHeader(header); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		sizes := newRowSizeRecorder()
+
+		// Start a transaction
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to begin transaction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		// The fraction only applies to the cursors of this transaction
+		_, err = tx.Exec(ctx, cursorTupleFractionQuery(fraction))
+		if err != nil {
+			err = fmt.Errorf("failed to set cursor_tuple_fraction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		_, err = tx.Exec(ctx, declareCursorQuery("ctf_cursor", 0))
+		if err != nil {
+			err = fmt.Errorf("failed to declare cursor: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		for {
+			batchStart := time.Now()
+			bctx, timings := traceQueries(ctx)
+
+			rows, err := tx.Query(bctx, fetchCursorQuery("ctf_cursor"))
+			if err != nil {
+				err = fmt.Errorf("failed to fetch data: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			count, err := writeAccountRows(bctx, rows, out, sizes)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Check if there are no more rows
+			if count == 0 {
+				break
+			}
+
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("fetch %d", len(result.Batches)+1),
+				Rows:     count,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+
+			// Hold still while the run is paused
+			result.Paused += control.Wait(ctx)
+		}
+
+		// Commit the transaction
+		if err := tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.RowSizes = sizes.Summary()
+		result.Writes = out.Stats()
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
+
+// FractionTradeoff is the first fetch latency and total time of the cursor
+// under one cursor_tuple_fraction.
+type FractionTradeoff struct {
+	Strategy   string
+	Fraction   string
+	FirstFetch time.Duration
+	Total      time.Duration
+}
+
+// fractionTradeoffs lists the cursor strategies in the order they ran, with
+// the plain cursor as the server default.
+func fractionTradeoffs(results []Result) []FractionTradeoff {
+	var tradeoffs []FractionTradeoff
+	for _, r := range results {
+		if r.Err != nil || len(r.Batches) == 0 {
+			continue
+		}
+		fraction, ok := strings.CutPrefix(r.Type, "cursor_ctf_")
+		if !ok {
+			if r.Type != "cursor" {
+				continue
+			}
+			fraction = "default"
+		}
+		tradeoffs = append(tradeoffs, FractionTradeoff{
+			Strategy:   r.Type,
+			Fraction:   fraction,
+			FirstFetch: r.Batches[0].Duration,
+			Total:      r.Duration,
+		})
+	}
+	if len(tradeoffs) < 2 {
+		return nil
+	}
+	return tradeoffs
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ctidBlocks is the number of heap blocks every page of the ctid strategy
+// covers. Zero disables it.
+var ctidBlocks int
+
+// fetchWithCtidRanges pages through the heap by physical position, one range
+// of ctidBlocks blocks per query, the way bulk ETL tools chunk tables without
+// a usable key. Pages come in heap order and vary in size with how full the
+// blocks are.
+func fetchWithCtidRanges(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "ctid_range",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	// Blocks added while the export runs are not read
+	var blocks int
+	if err := pool.QueryRow(ctx, relationBlocksQuery(), tableName()).Scan(&blocks); err != nil {
+		err = fmt.Errorf("failed to read table size: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	for first := 0; first < blocks; first += ctidBlocks {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := pool.Query(bctx, ctidRangeQuery(), ctidRangeArgs(first, first+ctidBlocks)...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, err := writeAccountRows(bctx, rows, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("blocks %d..%d", first, min(first+ctidBlocks, blocks)-1),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
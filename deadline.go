@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// batchDeadline is the soft deadline of a batch. Slower batches are logged and
+// their slowest query is explained, but they are not cancelled. Zero disables
+// the deadline.
+var batchDeadline time.Duration
+
+// explainSlowBatch logs a batch that missed the deadline and captures the plan
+// of its slowest query right away, while the data region that made it slow is
+// still in the state that caused it.
+func explainSlowBatch(ctx context.Context, strategy string, b *Batch) {
+	query := strings.TrimSpace(b.slowestSQL)
+	fmt.Printf("  %s slow batch #%d (%s) took %s, over the %s deadline\n",
+		strategy, b.Seq, b.Key, b.Duration, batchDeadline)
+
+	// FETCH, MOVE and COPY can not be explained, only plain queries
+	verb := strings.ToUpper(strings.SplitN(query, " ", 2)[0])
+	if verb != "SELECT" && verb != "WITH" {
+		return
+	}
+
+	plan, err := explain(ctx, query, b.slowestArgs...)
+	if err != nil {
+		fmt.Printf("  %s slow batch #%d could not be explained: %v\n", strategy, b.Seq, err)
+		return
+	}
+	b.Plan = plan
+	fmt.Printf("  %s slow batch #%d plan %s, %d buffers hit, %d read, %.2fms\n",
+		strategy, b.Seq, plan.Nodes(), plan.Root.SharedHit, plan.Root.SharedRead, plan.ExecutionTime)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// decodingPlugin is the output plugin of the logical_decoding strategy,
+// pgoutput, wal2json or test_decoding. Empty disables the strategy.
+var decodingPlugin string
+
+// decodingTable is the table whose changes the logical_decoding strategy
+// decodes, a copy of the benchmark table, so the changes it makes to fill the
+// LSN window leave the benchmark table alone.
+const decodingTable = "bench_logical_decoding"
+
+// DecodingStats describes the changes the logical decoding strategy read.
+type DecodingStats struct {
+	Plugin string
+	// Start and End are the LSN window the changes were written in.
+	Start, End string
+	// Changes are the rows the plugin returned, including the begin and
+	// commit messages of every transaction, and Bytes their size.
+	Changes int64
+	Bytes   int64
+	// Generate is the time writing the changes into the window took.
+	Generate time.Duration
+}
+
+func validDecodingPlugin(plugin string) bool {
+	return plugin == "pgoutput" || plugin == "wal2json" || plugin == "test_decoding"
+}
+
+// fetchWithLogicalDecoding measures change data capture next to the snapshot
+// exports. It creates a temporary slot, updates every row of its copy of the
+// table in pages of DATA_BATCH_SIZE rows, one transaction each, and then times
+// consuming the changes of that LSN window from the slot, writing them through
+// the sinks.
+func fetchWithLogicalDecoding(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:     "logical_decoding",
+		Decoding: &DecodingStats{Plugin: decodingPlugin},
+	}
+	stats := result.Decoding
+
+	var level string
+	if err := pool.QueryRow(ctx, "SHOW wal_level").Scan(&level); err != nil {
+		err = fmt.Errorf("failed to read wal_level: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	if level != "logical" {
+		err := fmt.Errorf("logical decoding needs wal_level = logical, the server has %s", level)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	if _, _, err := createWriteTable(ctx, decodingTable); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	if decodingPlugin == "pgoutput" {
+		for _, statement := range createPublicationQueries(decodingTable) {
+			if _, err := pool.Exec(ctx, statement); err != nil {
+				err = fmt.Errorf("failed to create publication: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+		}
+	}
+
+	// A temporary slot belongs to the session that created it
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to acquire connection: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer conn.Release()
+
+	slot := "bench_decoding_" + strconv.Itoa(os.Getpid())
+	if err := conn.QueryRow(ctx, createDecodingSlotQuery(), slot, decodingPlugin).Scan(&stats.Start); err != nil {
+		err = fmt.Errorf("failed to create replication slot: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	// Do not leave the slot holding back WAL while the connection idles
+	defer conn.Exec(ctx, dropDecodingSlotQuery(), slot)
+
+	// Fill the window with one transaction per page
+	generateStart := time.Now()
+	var lastId int
+	for {
+		var count int
+		rows, err := pool.Query(ctx, updatePageQuery(decodingTable), keysetPageArgs(lastId)...)
+		if err == nil {
+			for rows.Next() {
+				var key int
+				if err = rows.Scan(&key); err != nil {
+					break
+				}
+				lastId = max(lastId, key)
+				count++
+			}
+			rows.Close()
+			if err == nil {
+				err = rows.Err()
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to write changes: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+		if count == 0 {
+			break
+		}
+	}
+	stats.Generate = time.Since(generateStart)
+
+	if err := conn.QueryRow(ctx, currentWALQuery()).Scan(&stats.End); err != nil {
+		err = fmt.Errorf("failed to read WAL position: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	sizes := newRowSizeRecorder()
+
+	if err := out.WriteHeader([]string{"lsn", "xid", "data"}); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Time consuming the changes only
+	start = time.Now()
+	query := decodingChangesQuery(decodingPlugin, decodingTable)
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := conn.Query(bctx, query, slot, stats.End, batchSize)
+		if err != nil {
+			err = fmt.Errorf("failed to read changes: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var first, last string
+		var count int
+		for rows.Next() {
+			var lsn, xid string
+			var data []byte
+			if err := rows.Scan(&lsn, &xid, &data); err != nil {
+				rows.Close()
+				err = fmt.Errorf("failed to scan change: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			stats.Bytes += int64(len(data))
+
+			// pgoutput is binary, the other plugins write text
+			value := string(data)
+			if decodingPlugin == "pgoutput" {
+				value = formatValue(data)
+			}
+			n, err := timings.WriteRow(out, []string{lsn, xid, value})
+			if err != nil {
+				rows.Close()
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				first = lsn
+			}
+			last = lsn
+			count++
+		}
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("failed to read changes: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		if count == 0 {
+			break
+		}
+		stats.Changes += int64(count)
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("lsn %s..%s", first, last),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		result.Paused += control.Wait(ctx)
+	}
+
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareLogicalDecoding puts the change throughput of logical decoding next
+// to the row throughput of the snapshot export by custom_cursor.
+func compareLogicalDecoding(results []Result) (string, bool) {
+	var decoding, keyset *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "logical_decoding":
+			decoding = r
+		case r.Type == "custom_cursor":
+			keyset = r
+		}
+	}
+	if decoding == nil || decoding.Duration == 0 {
+		return "", false
+	}
+
+	d := decoding.Decoding
+	line := fmt.Sprintf("logical_decoding read %s %s changes of LSN window %s..%s at %s changes/sec, %s bytes/sec",
+		human.Int(d.Changes), d.Plugin, d.Start, d.End,
+		human.Float(float64(d.Changes)/decoding.Duration.Seconds(), 0), human.Float(float64(d.Bytes)/decoding.Duration.Seconds(), 0))
+	if keyset != nil && keyset.Duration > 0 {
+		line += fmt.Sprintf(", custom_cursor exported %s rows/sec", human.Float(float64(keyset.RowSizes.Rows)/keyset.Duration.Seconds(), 0))
+	}
+	return line, true
+}
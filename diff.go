@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func readManifest(path string) (Manifest, error) {
+	var manifest Manifest
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, fmt.Errorf("error reading manifest: %v", err)
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("error decoding manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// diffRuns compares the strategy durations of two runs from their manifests,
+// warning first when the runs did not measure the same thing.
+func diffRuns(oldPath, newPath string) error {
+	before, err := readManifest(oldPath)
+	if err != nil {
+		return err
+	}
+	after, err := readManifest(newPath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case before.Fingerprint == nil || after.Fingerprint == nil:
+		fmt.Println("warning: a run has no fingerprint, the runs may not be comparable")
+	case before.Fingerprint.Hash != after.Fingerprint.Hash:
+		fmt.Printf("warning: fingerprints differ (%s vs %s), the runs differ in %s\n",
+			before.Fingerprint.Hash, after.Fingerprint.Hash,
+			strings.Join(before.Fingerprint.Differences(after.Fingerprint), ", "))
+	}
+
+	if before.Settings != nil && after.Settings != nil {
+		for _, c := range changedSettings(before.Settings, after.Settings) {
+			fmt.Printf("setting %s changed from %s to %s\n", c.Name, orNone(c.Before), orNone(c.After))
+		}
+	}
+
+	previous := make(map[string]ManifestResult)
+	for _, r := range before.Results {
+		previous[r.Type] = r
+	}
+
+	fmt.Printf("%-26s %10s %10s %8s\n", "strategy", "before", "after", "change")
+	for _, r := range after.Results {
+		p, ok := previous[r.Type]
+		switch {
+		case !ok:
+			fmt.Printf("%-26s %10s %9.2fs %8s\n", r.Type, "-", r.Seconds, "new")
+		case p.Error != "" || r.Error != "":
+			fmt.Printf("%-26s %10s %10s %8s\n", r.Type, status(p), status(r), "-")
+		default:
+			change := "-"
+			if p.Seconds > 0 {
+				change = fmt.Sprintf("%+.1f%%", 100*(r.Seconds-p.Seconds)/p.Seconds)
+			}
+			fmt.Printf("%-26s %9.2fs %9.2fs %8s\n", r.Type, p.Seconds, r.Seconds, change)
+		}
+		delete(previous, r.Type)
+	}
+	for _, r := range before.Results {
+		if _, ok := previous[r.Type]; ok {
+			fmt.Printf("%-26s %9.2fs %10s %8s\n", r.Type, r.Seconds, "-", "removed")
+		}
+	}
+	return nil
+}
+
+func status(r ManifestResult) string {
+	if r.Error != "" {
+		return "failed"
+	}
+	return fmt.Sprintf("%.2fs", r.Seconds)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(unset)"
+	}
+	return value
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diskTestBytes is the size of the file doctor writes to measure the
+// throughput of the output directory.
+const diskTestBytes = 64 << 20
+
+// minDiskThroughput is the fsynced write throughput in MB/s below which the
+// output disk likely limits the strategies.
+const minDiskThroughput = 100
+
+// finding is one line of the doctor's readiness report.
+type finding struct {
+	level   string
+	check   string
+	message string
+}
+
+type doctorReport struct {
+	findings []finding
+}
+
+func (r *doctorReport) ok(check, format string, args ...any) {
+	r.findings = append(r.findings, finding{"ok", check, fmt.Sprintf(format, args...)})
+}
+
+func (r *doctorReport) warn(check, format string, args ...any) {
+	r.findings = append(r.findings, finding{"warn", check, fmt.Sprintf(format, args...)})
+}
+
+func (r *doctorReport) fail(check, format string, args ...any) {
+	r.findings = append(r.findings, finding{"FAIL", check, fmt.Sprintf(format, args...)})
+}
+
+// runDoctor inspects the server, the benchmark table and the client for
+// conditions that would bias the results and prints a readiness report. It
+// exits with a failure if a check failed.
+func runDoctor(ctx context.Context) int {
+	report := &doctorReport{}
+
+	if checkServer(ctx, report) {
+		checkTable(ctx, report)
+		checkIndexes(ctx, report)
+		checkAutovacuum(ctx, report)
+	}
+	checkFileLimit(report)
+	checkDisk(report)
+
+	var warnings, failures int
+	for _, f := range report.findings {
+		fmt.Printf("%-4s %s: %s\n", f.level, f.check, f.message)
+		switch f.level {
+		case "warn":
+			warnings++
+		case "FAIL":
+			failures++
+		}
+	}
+
+	switch {
+	case failures > 0:
+		fmt.Printf("not ready: %d checks failed, %d warnings\n", failures, warnings)
+		return exitFailed
+	case warnings > 0:
+		fmt.Printf("ready with %d warnings that may bias the results\n", warnings)
+	default:
+		fmt.Println("ready")
+	}
+	return exitOK
+}
+
+// checkServer reports the server version and whether the server is reachable
+// at all, in which case the other server checks can run.
+func checkServer(ctx context.Context, report *doctorReport) bool {
+	var version string
+	var versionNum int
+	err := pool.QueryRow(ctx, "SELECT current_setting('server_version'), current_setting('server_version_num')::int").Scan(&version, &versionNum)
+	if err != nil {
+		report.fail("server", "unable to query the server: %v", err)
+		return false
+	}
+	if versionNum < 130000 {
+		report.warn("server", "PostgreSQL %s is out of support, results may not carry over to current versions", version)
+	} else {
+		report.ok("server", "PostgreSQL %s", version)
+	}
+
+	var inRecovery bool
+	if err := pool.QueryRow(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery); err == nil && inRecovery {
+		report.warn("server", "the server is a standby, replay can conflict with long running cursors")
+	}
+	return true
+}
+
+// checkTable reports the size and statistics of the benchmark table, and how
+// much of it is dead tuples.
+func checkTable(ctx context.Context, report *doctorReport) {
+	var live, dead int64
+	var analyzed *time.Time
+	var size, sharedBuffers int64
+	err := pool.QueryRow(ctx, `
+		SELECT COALESCE(s.n_live_tup, 0), COALESCE(s.n_dead_tup, 0),
+			GREATEST(s.last_analyze, s.last_autoanalyze),
+			pg_table_size(c.oid),
+			(SELECT setting::bigint * current_setting('block_size')::bigint FROM pg_settings WHERE name = 'shared_buffers')
+		FROM pg_class c
+		LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+		WHERE c.oid = to_regclass($1)`, tableName()).Scan(&live, &dead, &analyzed, &size, &sharedBuffers)
+	if err != nil {
+		report.fail("table", "%s does not exist, create it with seed", tableName())
+		return
+	}
+
+	report.ok("table", "%s holds about %d rows in %s", tableName(), live, formatBytes(size))
+	if analyzed == nil {
+		report.warn("table", "%s was never analyzed, plans may be based on wrong estimates", tableName())
+	}
+	if live+dead > 0 && float64(dead)/float64(live+dead) > 0.2 {
+		report.warn("bloat", "%.0f%% of the tuples of %s are dead, scans read the bloat too, VACUUM it first",
+			100*float64(dead)/float64(live+dead), tableName())
+	}
+	if size < sharedBuffers {
+		report.warn("cache", "%s fits in shared_buffers (%s), warm runs only measure cached reads", tableName(), formatBytes(sharedBuffers))
+	}
+}
+
+// checkIndexes reports invalid indexes of the benchmark table and a missing
+// index on the key, which every page relies on.
+func checkIndexes(ctx context.Context, report *doctorReport) {
+	rows, err := pool.Query(ctx, `
+		SELECT i.indexrelid::regclass::text, i.indisvalid,
+			a.attname IS NOT NULL, pg_relation_size(i.indexrelid)
+		FROM pg_index i
+		LEFT JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0] AND a.attname = $2
+		WHERE i.indrelid = to_regclass($1)`, tableName(), keyColumn)
+	if err != nil {
+		report.fail("indexes", "unable to read indexes: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var keyIndexed bool
+	for rows.Next() {
+		var name string
+		var valid, onKey bool
+		var size int64
+		if err := rows.Scan(&name, &valid, &onKey, &size); err != nil {
+			report.fail("indexes", "unable to read indexes: %v", err)
+			return
+		}
+		if !valid {
+			report.fail("indexes", "index %s is invalid, REINDEX it", name)
+			continue
+		}
+		if onKey {
+			keyIndexed = true
+			report.ok("indexes", "%s leads with %s (%s)", name, keyColumn, formatBytes(size))
+		}
+	}
+	if rows.Err() != nil {
+		report.fail("indexes", "unable to read indexes: %v", rows.Err())
+		return
+	}
+	if !keyIndexed {
+		report.fail("indexes", "no index of %s leads with %s, every page scans the table", tableName(), keyColumn)
+	}
+}
+
+// checkAutovacuum reports autovacuum being off and vacuums running on the
+// benchmark table, which compete with the strategies for I/O.
+func checkAutovacuum(ctx context.Context, report *doctorReport) {
+	var enabled, tableOptions string
+	var running int
+	err := pool.QueryRow(ctx, `
+		SELECT current_setting('autovacuum'),
+			COALESCE(array_to_string(c.reloptions, ','), ''),
+			(SELECT count(*) FROM pg_stat_progress_vacuum p WHERE p.relid = c.oid)
+		FROM pg_class c
+		WHERE c.oid = to_regclass($1)`, tableName()).Scan(&enabled, &tableOptions, &running)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case enabled != "on":
+		report.warn("autovacuum", "autovacuum is off, dead tuples pile up between runs")
+	case strings.Contains(tableOptions, "autovacuum_enabled=false"):
+		report.warn("autovacuum", "autovacuum is disabled for %s", tableName())
+	default:
+		report.ok("autovacuum", "on")
+	}
+	if running > 0 {
+		report.warn("autovacuum", "a vacuum of %s is running and competes with the strategies for I/O", tableName())
+	}
+}
+
+// checkFileLimit reports an open file limit too low for the connections and
+// output files of a run.
+func checkFileLimit(report *doctorReport) {
+	soft, ok := openFileLimit()
+	if !ok {
+		return
+	}
+	// Every strategy keeps its output and a connection open, plus the pool
+	var enabled int
+	for _, s := range registeredStrategies() {
+		if s.enabled {
+			enabled++
+		}
+	}
+	need := uint64(2*enabled) + uint64(pool.Config().MaxConns) + 16
+	if soft < need {
+		report.warn("ulimit", "open file limit is %d, a run with every strategy may need %d, raise it with ulimit -n", soft, need)
+		return
+	}
+	report.ok("ulimit", "open file limit is %d", soft)
+}
+
+// checkDisk writes and fsyncs a test file in the output directory to measure
+// the throughput the file sink can reach.
+func checkDisk(report *doctorReport) {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		report.fail("disk", "unable to create %s: %v", outputDir, err)
+		return
+	}
+	path := filepath.Join(outputDir, ".doctor"+partialSuffix)
+	defer os.Remove(path)
+
+	file, err := os.Create(path)
+	if err != nil {
+		report.fail("disk", "unable to write to %s: %v", outputDir, err)
+		return
+	}
+	defer file.Close()
+
+	chunk := make([]byte, 1<<20)
+	start := time.Now()
+	for written := 0; written < diskTestBytes; written += len(chunk) {
+		if _, err := file.Write(chunk); err != nil {
+			report.fail("disk", "unable to write to %s: %v", outputDir, err)
+			return
+		}
+	}
+	if err := file.Sync(); err != nil {
+		report.fail("disk", "unable to sync %s: %v", path, err)
+		return
+	}
+
+	throughput := float64(diskTestBytes) / (1 << 20) / time.Since(start).Seconds()
+	if throughput < minDiskThroughput {
+		report.warn("disk", "%s writes %.0f MB/s, the file sink may limit the faster strategies", outputDir, throughput)
+		return
+	}
+	report.ok("disk", "%s writes %.0f MB/s", outputDir, throughput)
+}
+
+// formatBytes formats a size in bytes with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
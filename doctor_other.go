@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// openFileLimit reports no limit where rlimits do not exist.
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
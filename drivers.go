@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/lib/pq"
+)
+
+// drivers are the database/sql drivers the keyset strategy is repeated
+// through, next to pgx's native interface. Empty disables them.
+var drivers []string
+
+// poolDSN is the connection string of the current pool, for the drivers that
+// open connections of their own.
+var poolDSN string
+
+func validDriver(name string) bool {
+	return name == "stdlib" || name == "pq"
+}
+
+// openDriver opens a database/sql handle for the named driver. pgx stdlib
+// wraps the pool, so it shares its connections, tracer and session settings,
+// while lib/pq opens its own connections from the DSN.
+func openDriver(name string) (*sql.DB, error) {
+	switch name {
+	case "stdlib":
+		return stdlib.OpenDBFromPool(pool), nil
+	case "pq":
+		db, err := sql.Open("postgres", poolDSN)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open lib/pq: %v", err)
+		}
+		return db, nil
+	}
+	return nil, fmt.Errorf("unknown driver %q", name)
+}
+
+func driverStrategy(driver string) string {
+	return "custom_cursor_" + driver
+}
+
+// fetchWithDriver returns the custom_cursor strategy running through the named
+// database/sql driver instead of pgx, so the difference in their timings is
+// the overhead of the driver.
+func fetchWithDriver(driver string) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: driverStrategy(driver),
+		}
+
+		// Open the sink the rows are written to
+		out, err := openSink(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		header := projection
+		if err := out.WriteHeader(header); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		sizes := newRowSizeRecorder()
+
+		db, err := openDriver(driver)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer db.Close()
+
+		var lastId int
+		for {
+			batchStart := time.Now()
+			bctx, timings := traceQueries(ctx)
+
+			rows, err := db.QueryContext(bctx, keysetPageQuery(selectList()), keysetPageArgs(lastId)...)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch data: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Process each row in the batch
+			firstId := lastId + 1
+			var count int
+			for rows.Next() {
+				record, aid, err := scanSQLRecord(rows)
+				if err != nil {
+					rows.Close()
+					err = fmt.Errorf("failed to scan row: %w", err)
+					result.Err = err
+					res <- result
+					return err
+				}
+
+				n, err := timings.WriteRow(out, record)
+				if err != nil {
+					rows.Close()
+					result.Err = err
+					res <- result
+					return err
+				}
+				sizes.Add(n)
+
+				lastId = aid
+				count++
+			}
+
+			rows.Close()
+
+			if rows.Err() != nil {
+				err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Check if there are no more rows
+			if count == 0 {
+				break
+			}
+
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+				Rows:     count,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+
+			// Hold still while the run is paused
+			result.Paused += control.Wait(ctx)
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.RowSizes = sizes.Summary()
+		result.Writes = out.Stats()
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
+
+// DriverOverhead is the time a driver added to the keyset strategy compared
+// to pgx's native interface.
+type DriverOverhead struct {
+	Driver   string
+	Native   time.Duration
+	Duration time.Duration
+}
+
+func driverOverheads(results []Result) []DriverOverhead {
+	var native *Result
+	for i := range results {
+		if results[i].Type == "custom_cursor" && results[i].Err == nil {
+			native = &results[i]
+		}
+	}
+	if native == nil {
+		return nil
+	}
+
+	var overheads []DriverOverhead
+	for _, r := range results {
+		driver, ok := strings.CutPrefix(r.Type, "custom_cursor_")
+		if !ok || !validDriver(driver) || r.Err != nil {
+			continue
+		}
+		overheads = append(overheads, DriverOverhead{Driver: driver, Native: native.Duration, Duration: r.Duration})
+	}
+	return overheads
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// estimateThreshold is how many times off a row estimate must be before the
+// report calls it out, the point from which planners pick a different plan.
+const estimateThreshold = 10
+
+// representativePage returns the page query of the paginated strategies that
+// do not capture a plan themselves, with the arguments of the page halfway
+// through the key range. Strategies that read the table in a single query
+// have none, explaining it would repeat the whole export.
+func representativePage(strategy string) (string, []any, bool) {
+	switch strategy {
+	case "custom_cursor", "custom_cursor_unprepared", "custom_cursor_text", "custom_cursor_binary", "custom_cursor_prefetch":
+		return keysetPageQuery(selectList()), keysetPageArgs(limit / 2), true
+	case "offset_limit", "offset_limit_unprepared":
+		return offsetPageQuery(), offsetPageArgs(limit / 2), true
+	}
+	return "", nil, false
+}
+
+// explainRepresentativePages captures the plan of the representative page of
+// every successful strategy that has none yet. It runs after the strategies
+// so the extra queries do not compete with them.
+func explainRepresentativePages(ctx context.Context, results []Result) {
+	for i := range results {
+		result := &results[i]
+		if result.Err != nil || result.Plan != nil {
+			continue
+		}
+		query, args, ok := representativePage(result.Type)
+		if !ok {
+			continue
+		}
+		plan, err := explain(ctx, query, args...)
+		if err != nil {
+			fmt.Printf("  %s: %v\n", result.Type, err)
+			continue
+		}
+		result.Plan = plan
+	}
+}
+
+// worstEstimate returns the node whose row estimate is furthest off across
+// the plans of a result.
+func worstEstimate(result Result) (RowEstimate, bool) {
+	var worst RowEstimate
+	var found bool
+	for _, plan := range []*Plan{result.Plan, result.RangePlan} {
+		if plan == nil {
+			continue
+		}
+		for _, e := range plan.Estimates() {
+			if !found || e.Divergence > worst.Divergence {
+				worst, found = e, true
+			}
+		}
+	}
+	return worst, found
+}
+
+// describeEstimate prints a row estimate for the summary, e.g. "Index Scan
+// on pgbench_accounts planned 1,000 rows and returned 50,000, 50.0x off".
+func describeEstimate(e RowEstimate) string {
+	var b strings.Builder
+	b.WriteString(e.Node)
+	if e.Relation != "" {
+		fmt.Fprintf(&b, " on %s", e.Relation)
+	}
+	fmt.Fprintf(&b, " planned %s rows and returned %s, %sx off",
+		human.Float(e.Planned, 0), human.Float(e.Actual, 0), human.Float(e.Divergence, 1))
+	return b.String()
+}
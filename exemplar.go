@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// batchBuckets are the upper bounds in seconds of the batch latency
+// histogram.
+var batchBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// batchHistogram is the batch latency histogram of a strategy. Every bucket
+// keeps the slowest batch that fell into it as its exemplar.
+type batchHistogram struct {
+	// counts holds the batches per bucket, the last one past all bounds.
+	counts    []int64
+	sum       float64
+	count     int64
+	exemplars []*BatchTrace
+}
+
+// BatchTrace is a sampled batch, served under its trace ID so a latency spike
+// can be followed to the batch and the SQL that caused it.
+type BatchTrace struct {
+	TraceID    string    `json:"trace_id"`
+	Strategy   string    `json:"strategy"`
+	Seq        int       `json:"seq"`
+	Start      time.Time `json:"start"`
+	Key        string    `json:"key"`
+	Rows       int       `json:"rows"`
+	DurationMs float64   `json:"duration_ms"`
+	// SQL and Args are the batch's slowest query.
+	SQL  string   `json:"sql,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+var traces = struct {
+	sync.Mutex
+	batches map[string]*BatchTrace
+}{batches: make(map[string]*BatchTrace)}
+
+func newTraceID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// observeBatch adds the batch to the strategy's latency histogram. A batch
+// slower than the exemplar of its bucket takes its place and is given a trace
+// ID, so the exemplars sample the slow batches.
+func observeBatch(strategy string, b *Batch) {
+	seconds := b.Duration.Seconds()
+	bucket := len(batchBuckets)
+	for i, bound := range batchBuckets {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	m := strategyMetricsFor(strategy)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := &m.batches
+	if h.counts == nil {
+		h.counts = make([]int64, len(batchBuckets)+1)
+		h.exemplars = make([]*BatchTrace, len(batchBuckets)+1)
+	}
+	h.counts[bucket]++
+	h.sum += seconds
+	h.count++
+
+	previous := h.exemplars[bucket]
+	if previous != nil && previous.DurationMs >= float64(b.Duration)/float64(time.Millisecond) {
+		return
+	}
+
+	b.TraceID = newTraceID()
+	trace := &BatchTrace{
+		TraceID:    b.TraceID,
+		Strategy:   strategy,
+		Seq:        b.Seq,
+		Start:      b.Start,
+		Key:        b.Key,
+		Rows:       b.Rows,
+		DurationMs: float64(b.Duration) / float64(time.Millisecond),
+		SQL:        b.slowestSQL,
+	}
+	for _, arg := range b.slowestArgs {
+		trace.Args = append(trace.Args, fmt.Sprint(arg))
+	}
+	h.exemplars[bucket] = trace
+
+	traces.Lock()
+	if previous != nil {
+		delete(traces.batches, previous.TraceID)
+	}
+	traces.batches[trace.TraceID] = trace
+	traces.Unlock()
+}
+
+// writeBatchHistograms writes the batch latency histograms, with exemplars in
+// the OpenMetrics format.
+func writeBatchHistograms(w http.ResponseWriter, names []string, openMetrics bool) {
+	fmt.Fprintln(w, "# HELP bench_batch_duration_seconds Latency of the batches of a strategy.")
+	fmt.Fprintln(w, "# TYPE bench_batch_duration_seconds histogram")
+	for _, name := range names {
+		m := strategyMetricsFor(name)
+		m.mu.Lock()
+		h := m.batches
+		if h.counts == nil {
+			m.mu.Unlock()
+			continue
+		}
+		counts := append([]int64(nil), h.counts...)
+		exemplars := append([]*BatchTrace(nil), h.exemplars...)
+		m.mu.Unlock()
+
+		var cumulative int64
+		for i, count := range counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(batchBuckets) {
+				le = strconv.FormatFloat(batchBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "bench_batch_duration_seconds_bucket{strategy=%q,le=%q} %d", name, le, cumulative)
+			if e := exemplars[i]; e != nil && openMetrics {
+				ended := e.Start.Add(time.Duration(e.DurationMs * float64(time.Millisecond)))
+				fmt.Fprintf(w, " # {trace_id=%q} %g %.3f", e.TraceID, e.DurationMs/1000, float64(ended.UnixMilli())/1000)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "bench_batch_duration_seconds_sum{strategy=%q} %g\n", name, h.sum)
+		fmt.Fprintf(w, "bench_batch_duration_seconds_count{strategy=%q} %d\n", name, h.count)
+	}
+}
+
+// writeTrace serves the sampled batch of a trace ID as JSON.
+func writeTrace(w http.ResponseWriter, r *http.Request) {
+	traces.Lock()
+	trace, ok := traces.batches[r.PathValue("id")]
+	traces.Unlock()
+	if !ok {
+		http.Error(w, "unknown trace", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(trace)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Experiment is a set of runs described by an experiment file: every profile
+// against every target, repeated.
+type Experiment struct {
+	// Name is the directory of the experiment in the output directory, the
+	// name of the file without its extension by default.
+	Name string `yaml:"name"`
+	// Config is the file the profiles are read from, bench.yaml by default.
+	Config   string             `yaml:"config"`
+	Profiles []string           `yaml:"profiles"`
+	Targets  []ExperimentTarget `yaml:"targets"`
+	// Repetitions is how often every run is repeated, once by default.
+	Repetitions int `yaml:"repetitions"`
+}
+
+// ExperimentTarget is a database the profiles are run against, in place of
+// the database of the profile.
+type ExperimentTarget struct {
+	Name string `yaml:"name"`
+	DSN  string `yaml:"dsn"`
+}
+
+// experimentRun is one run of an experiment.
+type experimentRun struct {
+	Profile    string `json:"profile"`
+	Target     string `json:"target,omitempty"`
+	Repetition int    `json:"repetition"`
+	// Name is the run's directory in the experiment directory.
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+
+	dsn string
+}
+
+// loadExperiment reads and checks the experiment file at path.
+func loadExperiment(path string) (Experiment, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Experiment{}, fmt.Errorf("error reading experiment file: %v", err)
+	}
+
+	var experiment Experiment
+	if err := yaml.Unmarshal(content, &experiment); err != nil {
+		return Experiment{}, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if experiment.Name == "" {
+		experiment.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if experiment.Config == "" {
+		experiment.Config = "bench.yaml"
+	}
+	if experiment.Repetitions == 0 {
+		experiment.Repetitions = 1
+	}
+
+	switch {
+	case len(experiment.Profiles) == 0:
+		return Experiment{}, fmt.Errorf("%s lists no profiles", path)
+	case experiment.Repetitions < 0:
+		return Experiment{}, fmt.Errorf("repetitions of %s must be positive", path)
+	case strings.ContainsAny(experiment.Name, `/\`) || experiment.Name == "." || experiment.Name == "..":
+		return Experiment{}, fmt.Errorf("experiment name %q cannot name a directory", experiment.Name)
+	}
+	for _, profile := range experiment.Profiles {
+		if _, err := loadProfile(experiment.Config, profile); err != nil {
+			return Experiment{}, err
+		}
+		if strings.ContainsAny(profile, `/\`) {
+			return Experiment{}, fmt.Errorf("profile name %q cannot name a directory", profile)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, target := range experiment.Targets {
+		if target.Name == "" || target.DSN == "" {
+			return Experiment{}, fmt.Errorf("every target of %s needs a name and a dsn", path)
+		}
+		if strings.ContainsAny(target.Name, `/\.`) {
+			return Experiment{}, fmt.Errorf("target name %q must not contain a path", target.Name)
+		}
+		if seen[target.Name] {
+			return Experiment{}, fmt.Errorf("duplicate target %s", target.Name)
+		}
+		seen[target.Name] = true
+	}
+	return experiment, nil
+}
+
+// runs lists the runs of the experiment, the repetitions of a profile and
+// target one after another.
+func (e Experiment) runs() []experimentRun {
+	targets := e.Targets
+	if len(targets) == 0 {
+		// The database of the profile
+		targets = []ExperimentTarget{{}}
+	}
+
+	var runs []experimentRun
+	for _, profile := range e.Profiles {
+		for _, target := range targets {
+			for repetition := 1; repetition <= e.Repetitions; repetition++ {
+				parts := []string{profile}
+				if target.Name != "" {
+					parts = append(parts, target.Name)
+				}
+				parts = append(parts, strconv.Itoa(repetition))
+				runs = append(runs, experimentRun{
+					Profile:    profile,
+					Target:     target.Name,
+					Repetition: repetition,
+					Name:       strings.Join(parts, "-"),
+					dsn:        target.DSN,
+				})
+			}
+		}
+	}
+	return runs
+}
+
+// runExperiment runs every run of the experiment file that has not completed
+// yet, each as a run of its own in the experiment's directory, and then
+// summarizes all of them. A run is complete once its manifest is written, so
+// running the experiment again after an interruption or a failed run picks
+// up the runs that are left.
+func runExperiment(ctx context.Context, path string) error {
+	experiment, err := loadExperiment(path)
+	if err != nil {
+		return err
+	}
+	dir := outputPath(experiment.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating experiment directory: %v", err)
+	}
+
+	// Every run starts a process of its own, so the profile configures it
+	// from scratch like a run from the command line
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the executable: %v", err)
+	}
+
+	runs := experiment.runs()
+	var failed int
+	for i := range runs {
+		run := &runs[i]
+		runDir := filepath.Join(dir, run.Name)
+		if _, err := os.Stat(filepath.Join(runDir, "manifest.json")); err == nil {
+			fmt.Printf("run %d of %d, %s, already complete\n", i+1, len(runs), run.Name)
+			continue
+		}
+		fmt.Printf("run %d of %d, %s\n", i+1, len(runs), run.Name)
+
+		args := []string{"-config", experiment.Config, "-profile", run.Profile, "-output-dir", dir, "-run-name", run.Name}
+		if run.dsn != "" {
+			args = append(args, "-dsn", run.dsn)
+		}
+		if _, err := os.Stat(runDir); err == nil {
+			// An earlier attempt failed part way, its outputs are replaced
+			args = append(args, "-force")
+		}
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		// The experiment decides the targets, not the environment, and keeps
+		// all of its runs
+		cmd.Env = append(os.Environ(), "TARGETS=", "IO_SETTINGS=", "BENCH_PROFILE=", "RETAIN_RUNS=", "RETAIN_DAYS=")
+		if err := cmd.Run(); err != nil {
+			run.Error = err.Error()
+			failed++
+			fmt.Printf("run %s failed: %v\n", run.Name, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	summary, err := summarizeExperiment(experiment, dir, runs)
+	if err != nil {
+		return err
+	}
+	printExperimentSummary(summary)
+	if err := writeExperimentSummary(filepath.Join(dir, "experiment.json"), summary); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs failed, run the experiment again to retry them", failed, len(runs))
+	}
+	return nil
+}
+
+// ExperimentSummary consolidates the runs of an experiment, saved to
+// experiment.json in its directory.
+type ExperimentSummary struct {
+	Name string          `json:"name"`
+	Runs []experimentRun `json:"runs"`
+	// Cells are the profile and target pairs in the order they ran.
+	Cells      []string             `json:"cells"`
+	Strategies []ExperimentStrategy `json:"strategies"`
+}
+
+// ExperimentStrategy is how a strategy did in every cell of an experiment.
+type ExperimentStrategy struct {
+	Type  string                     `json:"type"`
+	Cells map[string]ExperimentTimes `json:"cells"`
+}
+
+// ExperimentTimes are the seconds of the completed repetitions of a strategy
+// in one cell.
+type ExperimentTimes struct {
+	Runs   int     `json:"runs"`
+	Failed int     `json:"failed,omitempty"`
+	Mean   float64 `json:"mean_seconds"`
+	Min    float64 `json:"min_seconds"`
+	Max    float64 `json:"max_seconds"`
+}
+
+func (r experimentRun) cell() string {
+	if r.Target == "" {
+		return r.Profile
+	}
+	return r.Profile + "/" + r.Target
+}
+
+// summarizeExperiment reads the manifests of the completed runs.
+func summarizeExperiment(experiment Experiment, dir string, runs []experimentRun) (ExperimentSummary, error) {
+	summary := ExperimentSummary{Name: experiment.Name, Runs: runs}
+	strategies := make(map[string]*ExperimentStrategy)
+	var order []string
+	for _, run := range runs {
+		cell := run.cell()
+		if len(summary.Cells) == 0 || summary.Cells[len(summary.Cells)-1] != cell {
+			summary.Cells = append(summary.Cells, cell)
+		}
+
+		path := filepath.Join(dir, run.Name, "manifest.json")
+		if _, err := os.Stat(path); err != nil || run.Error != "" {
+			continue
+		}
+		manifest, err := readManifest(path)
+		if err != nil {
+			return summary, err
+		}
+
+		for _, r := range manifest.Results {
+			s, ok := strategies[r.Type]
+			if !ok {
+				s = &ExperimentStrategy{Type: r.Type, Cells: make(map[string]ExperimentTimes)}
+				strategies[r.Type] = s
+				order = append(order, r.Type)
+			}
+			times := s.Cells[cell]
+			if r.Error != "" {
+				times.Failed++
+				s.Cells[cell] = times
+				continue
+			}
+			if times.Runs == 0 || r.Seconds < times.Min {
+				times.Min = r.Seconds
+			}
+			times.Max = max(times.Max, r.Seconds)
+			times.Mean += (r.Seconds - times.Mean) / float64(times.Runs+1)
+			times.Runs++
+			s.Cells[cell] = times
+		}
+	}
+
+	for _, name := range order {
+		summary.Strategies = append(summary.Strategies, *strategies[name])
+	}
+	return summary, nil
+}
+
+// printExperimentSummary prints the mean seconds of every strategy per cell,
+// with the range over the repetitions.
+func printExperimentSummary(summary ExperimentSummary) {
+	fmt.Printf("%-26s", "strategy")
+	for _, cell := range summary.Cells {
+		fmt.Printf(" %24s", cell)
+	}
+	fmt.Println()
+	for _, s := range summary.Strategies {
+		fmt.Printf("%-26s", s.Type)
+		for _, cell := range summary.Cells {
+			times, ok := s.Cells[cell]
+			value := "-"
+			switch {
+			case ok && times.Runs > 1:
+				value = fmt.Sprintf("%.2fs (%.2f-%.2f)", times.Mean, times.Min, times.Max)
+			case ok && times.Runs == 1:
+				value = fmt.Sprintf("%.2fs", times.Mean)
+			case ok:
+				value = "failed"
+			}
+			fmt.Printf(" %24s", value)
+		}
+		fmt.Println()
+	}
+}
+
+func writeExperimentSummary(path string, summary ExperimentSummary) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding experiment summary: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing experiment summary: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PlanNode is the subset of an EXPLAIN (FORMAT JSON) plan node the reports use.
+type PlanNode struct {
+	NodeType    string     `json:"Node Type"`
+	Relation    string     `json:"Relation Name"`
+	Index       string     `json:"Index Name"`
+	Direction   string     `json:"Scan Direction"`
+	Filter      string     `json:"Filter"`
+	Removed     float64    `json:"Rows Removed by Filter"`
+	PlanRows    float64    `json:"Plan Rows"`
+	ActualRows  float64    `json:"Actual Rows"`
+	ActualLoops float64    `json:"Actual Loops"`
+	HeapFetches int64      `json:"Heap Fetches"`
+	SharedHit   int64      `json:"Shared Hit Blocks"`
+	SharedRead  int64      `json:"Shared Read Blocks"`
+	Plans       []PlanNode `json:"Plans"`
+}
+
+// Plan is the result of explaining a strategy's representative query.
+type Plan struct {
+	Query         string
+	Root          PlanNode
+	ExecutionTime float64
+}
+
+// Nodes returns the node types of the plan from the root down, e.g.
+// "Limit > Index Only Scan".
+func (p *Plan) Nodes() string {
+	var names []string
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		names = append(names, n.NodeType)
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	return strings.Join(names, " > ")
+}
+
+// IndexOrder reports the direction the plan reads an index in to return its
+// rows in order, Forward or Backward, and "" when it sorts them instead or
+// reads no index. A descending page reads an ascending index backwards and
+// a descending one forwards.
+func (p *Plan) IndexOrder() string {
+	var direction string
+	var sorts bool
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		switch {
+		case n.NodeType == "Sort" || n.NodeType == "Incremental Sort":
+			sorts = true
+		case n.Direction != "" && direction == "":
+			direction = n.Direction
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	if sorts {
+		return ""
+	}
+	return direction
+}
+
+// HeapFetches sums the heap fetches of every index only scan in the plan.
+func (p *Plan) HeapFetches() int64 {
+	var total int64
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		total += n.HeapFetches
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	return total
+}
+
+// Filters returns the filter conditions of the plan from the root down.
+func (p *Plan) Filters() []string {
+	var filters []string
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		if n.Filter != "" {
+			filters = append(filters, n.Filter)
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	return filters
+}
+
+// RowsRemoved sums the rows every node of the plan read and then discarded
+// by its filter, per loop as EXPLAIN reports them.
+func (p *Plan) RowsRemoved() float64 {
+	var total float64
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		total += n.Removed
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	return total
+}
+
+// Buffers returns the shared buffers the plan hit or read.
+func (p *Plan) Buffers() int64 {
+	return p.Root.SharedHit + p.Root.SharedRead
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// explain runs EXPLAIN ANALYZE on the query and returns its plan. The query is
+// executed, so it must not have side effects.
+func explain(ctx context.Context, query string, args ...any) (*Plan, error) {
+	return explainOn(ctx, pool, query, args...)
+}
+
+// explainOn is explain on a given connection, for settings that differ
+// between connections.
+func explainOn(ctx context.Context, conn queryRower, query string, args ...any) (*Plan, error) {
+	var content []byte
+	err := conn.QueryRow(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query, args...).Scan(&content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	var plans []struct {
+		Plan          PlanNode `json:"Plan"`
+		ExecutionTime float64  `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(content, &plans); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("empty plan")
+	}
+
+	return &Plan{Query: query, Root: plans[0].Plan, ExecutionTime: plans[0].ExecutionTime}, nil
+}
+
+// RowEstimate compares the rows the planner expected a plan node to return
+// with the rows it did, per loop as EXPLAIN reports both.
+type RowEstimate struct {
+	Node     string
+	Relation string
+	Planned  float64
+	Actual   float64
+	// Divergence is how many times the larger of the two is over the
+	// smaller, 1 for an exact estimate, in either direction.
+	Divergence float64
+}
+
+// Estimates returns the row estimates of every node of the plan that ran.
+// Nodes below a Limit stop as soon as it has its rows, so returning fewer
+// rows than planned there is not a misestimate and only more rows count.
+func (p *Plan) Estimates() []RowEstimate {
+	var estimates []RowEstimate
+	var walk func(n PlanNode, limited bool)
+	walk = func(n PlanNode, limited bool) {
+		if n.ActualLoops > 0 && (!limited || n.ActualRows > n.PlanRows) {
+			planned, actual := max(n.PlanRows, 1), max(n.ActualRows, 1)
+			estimates = append(estimates, RowEstimate{
+				Node:       n.NodeType,
+				Relation:   n.Relation,
+				Planned:    n.PlanRows,
+				Actual:     n.ActualRows,
+				Divergence: max(planned, actual) / min(planned, actual),
+			})
+		}
+		for _, child := range n.Plans {
+			walk(child, limited || n.NodeType == "Limit")
+		}
+	}
+	walk(p.Root, false)
+	return estimates
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlanEstimates(t *testing.T) {
+	tests := []struct {
+		name string
+		root PlanNode
+		want []RowEstimate
+	}{
+		{
+			name: "exact",
+			root: PlanNode{NodeType: "Seq Scan", Relation: "a", PlanRows: 100, ActualRows: 100, ActualLoops: 1},
+			want: []RowEstimate{{"Seq Scan", "a", 100, 100, 1}},
+		},
+		{
+			name: "under and over",
+			root: PlanNode{NodeType: "Hash Join", PlanRows: 10, ActualRows: 1000, ActualLoops: 1, Plans: []PlanNode{
+				{NodeType: "Seq Scan", Relation: "a", PlanRows: 500, ActualRows: 50, ActualLoops: 1},
+			}},
+			want: []RowEstimate{{"Hash Join", "", 10, 1000, 100}, {"Seq Scan", "a", 500, 50, 10}},
+		},
+		{
+			name: "zero rows count as one",
+			root: PlanNode{NodeType: "Index Scan", Relation: "a", PlanRows: 1, ActualRows: 0, ActualLoops: 1},
+			want: []RowEstimate{{"Index Scan", "a", 1, 0, 1}},
+		},
+		{
+			name: "never executed",
+			root: PlanNode{NodeType: "Seq Scan", Relation: "a", PlanRows: 100},
+		},
+		{
+			name: "below a limit only more rows count",
+			root: PlanNode{NodeType: "Limit", PlanRows: 10, ActualRows: 10, ActualLoops: 1, Plans: []PlanNode{
+				{NodeType: "Index Scan", Relation: "a", PlanRows: 100000, ActualRows: 10, ActualLoops: 1},
+				{NodeType: "Sort", PlanRows: 5, ActualRows: 50, ActualLoops: 1, Plans: []PlanNode{
+					{NodeType: "Seq Scan", Relation: "b", PlanRows: 1000, ActualRows: 50, ActualLoops: 1},
+				}},
+			}},
+			want: []RowEstimate{{"Limit", "", 10, 10, 1}, {"Sort", "", 5, 50, 10}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Plan{Root: tt.root}
+			if got := p.Estimates(); !slices.Equal(got, tt.want) {
+				t.Errorf("Estimates() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanIndexOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		root PlanNode
+		want string
+	}{
+		{"forward", PlanNode{NodeType: "Limit", Plans: []PlanNode{{NodeType: "Index Scan", Direction: "Forward"}}}, "Forward"},
+		{"backward", PlanNode{NodeType: "Limit", Plans: []PlanNode{{NodeType: "Index Only Scan", Direction: "Backward"}}}, "Backward"},
+		{"sorted", PlanNode{NodeType: "Limit", Plans: []PlanNode{{NodeType: "Sort", Plans: []PlanNode{{NodeType: "Index Scan", Direction: "Forward"}}}}}, ""},
+		{"no index", PlanNode{NodeType: "Limit", Plans: []PlanNode{{NodeType: "Seq Scan"}}}, ""},
+	}
+	for _, tt := range tests {
+		p := &Plan{Root: tt.root}
+		if got := p.IndexOrder(); got != tt.want {
+			t.Errorf("%s: IndexOrder() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exit codes of the export subcommand.
+const (
+	exitOK = iota
+	exitFailed
+	exitUsage
+)
+
+// exportOptions configure a production export of one table.
+type exportOptions struct {
+	strategy  string
+	table     string
+	key       string
+	out       string
+	batchSize int
+	resume    bool
+}
+
+// exportProgress is saved next to a keyset export after every page so an
+// interrupted export can continue where it stopped.
+type exportProgress struct {
+	Table   string `json:"table"`
+	Key     string `json:"key"`
+	LastKey string `json:"last_key"`
+	Offset  int64  `json:"offset"`
+	Rows    int64  `json:"rows"`
+	// Upload is the multipart upload of an export to S3, which -resume
+	// adds parts to.
+	Upload *s3Upload `json:"upload,omitempty"`
+}
+
+// runExport exports a table with a single strategy and returns the process
+// exit code: 0 when the export completed, 1 when it failed and 2 when it was
+// invoked incorrectly.
+func runExport(ctx context.Context, args []string) int {
+	opts := exportOptions{}
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&opts.strategy, "strategy", "keyset", "export strategy, keyset or copy")
+	flags.StringVar(&opts.table, "table", "pgbench_accounts", "table to export")
+	flags.StringVar(&opts.key, "key", "aid", "unique key column the keyset strategy pages by")
+	flags.StringVar(&opts.out, "out", "", "CSV file or s3://bucket/key to write")
+	flags.IntVar(&opts.batchSize, "batch", 10000, "rows per keyset page")
+	flags.BoolVar(&opts.resume, "resume", false, "continue an interrupted keyset export")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	start := time.Now()
+	var rows int64
+	var err error
+	switch opts.strategy {
+	case "keyset":
+		rows, err = exportKeyset(ctx, opts)
+	case "copy":
+		rows, err = exportCopy(ctx, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d rows of %s to %s in %.2f second\n",
+		rows, opts.table, opts.out, time.Since(start).Seconds())
+	return exitOK
+}
+
+func (o exportOptions) validate() error {
+	switch {
+	case o.out == "":
+		return errors.New("-out is required")
+	case isS3URL(o.out):
+		if _, key, err := parseS3URL(o.out); err != nil || key == "" {
+			return fmt.Errorf("invalid S3 location %q, want s3://bucket/key", o.out)
+		}
+	case strings.Contains(o.out, "://"):
+		return fmt.Errorf("only local paths and s3:// locations are supported for -out, got %q", o.out)
+	}
+	switch {
+	case o.strategy != "keyset" && o.strategy != "copy":
+		return fmt.Errorf("unknown export strategy %q, use keyset or copy", o.strategy)
+	case o.resume && o.strategy != "keyset":
+		return errors.New("-resume is only supported by the keyset strategy")
+	case o.batchSize <= 0:
+		return errors.New("-batch must be positive")
+	case exportCSV.crlf:
+		return errors.New("exports are written by COPY, which only ends lines with LF, unset CSV_LINE_ENDING")
+	}
+	return nil
+}
+
+// progressPath is the file the progress of a keyset export is saved to, next
+// to a local output and in the working directory for an S3 output.
+func (o exportOptions) progressPath() string {
+	if isS3URL(o.out) {
+		return strings.ReplaceAll(strings.TrimPrefix(o.out, "s3://"), "/", "_") + ".progress"
+	}
+	return o.out + ".progress"
+}
+
+// keysetOutput is where a keyset export writes, a local file or an S3 object.
+type keysetOutput interface {
+	io.Writer
+	// Checkpoint makes the output written so far durable when it can and
+	// reports whether it did, which is when the progress is saved.
+	Checkpoint() (bool, error)
+	// Complete publishes the output once every page was written.
+	Complete() error
+	Close() error
+}
+
+// openKeysetOutput opens the output of a keyset export, at the saved
+// progress when resuming.
+func openKeysetOutput(ctx context.Context, opts exportOptions, progress *exportProgress) (keysetOutput, error) {
+	if !isS3URL(opts.out) {
+		if progress.Upload != nil {
+			return nil, fmt.Errorf("%s belongs to an export to S3", opts.progressPath())
+		}
+		flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		if opts.resume {
+			flags = os.O_WRONLY
+		}
+		file, err := os.OpenFile(opts.out, flags, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening output: %v", err)
+		}
+
+		// Drop whatever was written after the last saved page
+		if err := file.Truncate(progress.Offset); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error truncating output: %v", err)
+		}
+		if _, err := file.Seek(progress.Offset, 0); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error seeking output: %v", err)
+		}
+		return fileKeysetOutput{file}, nil
+	}
+
+	client, err := newS3Client()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := parseS3URL(opts.out)
+	if err != nil {
+		return nil, err
+	}
+	if opts.resume {
+		// Parts uploaded after the last saved page are replaced or left out
+		// of the object
+		if progress.Upload == nil {
+			return nil, fmt.Errorf("%s belongs to an export to a local file", opts.progressPath())
+		}
+		progress.Upload.client, progress.Upload.ctx = client, ctx
+		progress.Upload.bucket, progress.Upload.key = bucket, key
+		return s3KeysetOutput{progress.Upload}, nil
+	}
+	exists, err := client.exists(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%s already exists", opts.out)
+	}
+	progress.Upload = newS3Upload(ctx, client, bucket, key)
+	return s3KeysetOutput{progress.Upload}, nil
+}
+
+type fileKeysetOutput struct {
+	*os.File
+}
+
+func (f fileKeysetOutput) Checkpoint() (bool, error) {
+	if err := f.Sync(); err != nil {
+		return false, fmt.Errorf("error syncing file: %v", err)
+	}
+	return true, nil
+}
+
+func (f fileKeysetOutput) Complete() error {
+	if err := f.File.Close(); err != nil {
+		return fmt.Errorf("error closing file: %v", err)
+	}
+	return nil
+}
+
+// s3KeysetOutput saves the progress whenever a part was uploaded at the end
+// of a page. The upload of a failed export is left for -resume.
+type s3KeysetOutput struct {
+	*s3Upload
+}
+
+func (o s3KeysetOutput) Checkpoint() (bool, error) {
+	return o.FlushPart()
+}
+
+func (o s3KeysetOutput) Close() error {
+	return nil
+}
+
+// exportKeyset pages through the table by its key. Each page is bounded by its
+// last key and copied by the server, so any column types are encoded exactly
+// as COPY encodes them. After every page the file is synced and the last key
+// saved, which is what -resume continues from. An S3 output saves the last
+// key whenever the pages written add up to a part of its upload.
+func exportKeyset(ctx context.Context, opts exportOptions) (int64, error) {
+	table := pgx.Identifier(strings.Split(opts.table, ".")).Sanitize()
+	key := pgx.Identifier{opts.key}.Sanitize()
+
+	progress := exportProgress{Table: opts.table, Key: opts.key}
+	if opts.resume {
+		content, err := os.ReadFile(opts.progressPath())
+		if err != nil {
+			return 0, fmt.Errorf("error reading export progress: %v", err)
+		}
+		if err := json.Unmarshal(content, &progress); err != nil {
+			return 0, fmt.Errorf("error decoding export progress: %w", err)
+		}
+		if progress.Table != opts.table || progress.Key != opts.key {
+			return 0, fmt.Errorf("%s belongs to an export of %s by %s", opts.progressPath(), progress.Table, progress.Key)
+		}
+	}
+
+	out, err := openKeysetOutput(ctx, opts, &progress)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	// The pages written, which are ahead of the saved progress until the
+	// output is checkpointed
+	lastKey, offset, rows := progress.LastKey, progress.Offset, progress.Rows
+	for {
+		var where string
+		if rows > 0 {
+			where = fmt.Sprintf("WHERE %s > %s", key, quoteLiteral(lastKey))
+		}
+
+		var pageKey *string
+		var count int64
+		err := conn.QueryRow(ctx, fmt.Sprintf(`
+			SELECT max(k)::text, count(*)
+			FROM (SELECT %s AS k FROM %s %s ORDER BY %s LIMIT %d) page`,
+			key, table, where, key, opts.batchSize)).Scan(&pageKey, &count)
+		if err != nil {
+			return progress.Rows, fmt.Errorf("failed to find page bounds: %w", err)
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		bound := fmt.Sprintf("%s <= %s", key, quoteLiteral(*pageKey))
+		if where != "" {
+			where += " AND " + bound
+		} else {
+			where = "WHERE " + bound
+		}
+		// Only the first page starts with the header
+		dialect := exportCSV
+		dialect.header = dialect.header && rows == 0
+		command := fmt.Sprintf("COPY (SELECT * FROM %s %s ORDER BY %s) TO STDOUT WITH (%s)",
+			table, where, key, dialect.copyOptions())
+
+		counter := &countingWriter{w: out}
+		if _, err := conn.Conn().PgConn().CopyTo(ctx, counter, command); err != nil {
+			return progress.Rows, fmt.Errorf("failed to copy page: %w", err)
+		}
+		lastKey = *pageKey
+		offset += counter.n
+		rows += count
+
+		saved, err := out.Checkpoint()
+		if err != nil {
+			return progress.Rows, err
+		}
+		if !saved {
+			continue
+		}
+		progress.LastKey, progress.Offset, progress.Rows = lastKey, offset, rows
+		if err := saveExportProgress(opts.progressPath(), progress); err != nil {
+			return progress.Rows, err
+		}
+	}
+
+	if err := out.Complete(); err != nil {
+		return progress.Rows, err
+	}
+	if err := os.Remove(opts.progressPath()); err != nil && !os.IsNotExist(err) {
+		return rows, fmt.Errorf("error removing export progress: %v", err)
+	}
+	return rows, nil
+}
+
+// exportCopy copies the whole table in one statement into a .partial file and
+// renames it into place once complete.
+func exportCopy(ctx context.Context, opts exportOptions) (int64, error) {
+	table := pgx.Identifier(strings.Split(opts.table, ".")).Sanitize()
+	if isS3URL(opts.out) {
+		return exportCopyToS3(ctx, opts, table)
+	}
+
+	if _, err := os.Stat(opts.out); err == nil {
+		return 0, fmt.Errorf("%s already exists", opts.out)
+	}
+	file, err := os.Create(opts.out + partialSuffix)
+	if err != nil {
+		return 0, fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	command := fmt.Sprintf("COPY %s TO STDOUT WITH (%s)", table, exportCSV.copyOptions())
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, file, command)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy data: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("error syncing file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("error closing file: %v", err)
+	}
+	if err := os.Rename(opts.out+partialSuffix, opts.out); err != nil {
+		return 0, fmt.Errorf("error renaming file: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// exportCopyToS3 copies the whole table in one statement into an upload,
+// which only becomes the object once complete and is dropped otherwise.
+func exportCopyToS3(ctx context.Context, opts exportOptions, table string) (int64, error) {
+	client, err := newS3Client()
+	if err != nil {
+		return 0, err
+	}
+	bucket, key, err := parseS3URL(opts.out)
+	if err != nil {
+		return 0, err
+	}
+	exists, err := client.exists(ctx, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, fmt.Errorf("%s already exists", opts.out)
+	}
+	upload := newS3Upload(ctx, client, bucket, key)
+	defer upload.Abort()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	command := fmt.Sprintf("COPY %s TO STDOUT WITH (%s)", table, exportCSV.copyOptions())
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, upload, command)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy data: %w", err)
+	}
+	if err := upload.Complete(); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func saveExportProgress(path string, progress exportProgress) error {
+	content, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("error encoding export progress: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("error writing export progress: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing export progress: %v", err)
+	}
+	return nil
+}
+
+// quoteLiteral quotes s as an SQL string literal, which PostgreSQL coerces to
+// the type of the column it is compared with.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// sinkNames are the destinations every strategy writes its output to, set
+// from SINKS. file is the CSV file in the output directory, or stdout for the
+// streamed strategy.
+var sinkNames = []string{"file"}
+
+// sinkS3URL is the s3://bucket/prefix the s3 sink uploads to, set from
+// SINK_S3_URL.
+var sinkS3URL string
+
+// SinkTiming is the time a strategy spent writing to one of its sinks.
+type SinkTiming struct {
+	Name     string
+	Duration time.Duration
+	Bytes    int64
+	// Checksum is the SHA-256 of the output, for the checksum sink.
+	Checksum string
+}
+
+func validSink(name string) bool {
+	switch name {
+	case "file", "checksum", "s3", "discard":
+		return true
+	}
+	return false
+}
+
+// openSinkDestination opens a single named sink of a strategy's output.
+func openSinkDestination(sink, name string) (destination, error) {
+	switch sink {
+	case "file":
+		return openFileDestination(name)
+	case "checksum":
+		return newChecksumDestination(name)
+	case "s3":
+		return newS3Destination(name)
+	case "discard":
+		return discardDestination{}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q", sink)
+}
+
+type timedDestination struct {
+	destination
+	timing SinkTiming
+}
+
+// fanoutDestination writes the same output to several sinks in turn and times
+// every one of them, showing the cost each destination adds to a single pass
+// over the data.
+type fanoutDestination struct {
+	sinks []*timedDestination
+}
+
+func (f *fanoutDestination) Write(p []byte) (int, error) {
+	for _, s := range f.sinks {
+		start := time.Now()
+		n, err := s.Write(p)
+		s.timing.Duration += time.Since(start)
+		s.timing.Bytes += int64(n)
+		if err != nil {
+			return n, fmt.Errorf("error writing to %s sink: %w", s.timing.Name, err)
+		}
+	}
+	return len(p), nil
+}
+
+func (f *fanoutDestination) Finalize() error {
+	for _, s := range f.sinks {
+		start := time.Now()
+		err := s.Finalize()
+		s.timing.Duration += time.Since(start)
+		if err != nil {
+			return err
+		}
+		if c, ok := s.destination.(*checksumDestination); ok {
+			s.timing.Checksum = c.sum
+		}
+	}
+	return nil
+}
+
+func (f *fanoutDestination) Close() error {
+	var errs []error
+	for _, s := range f.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the write statistics of the file sink together with the
+// timing of every sink.
+func (f *fanoutDestination) Stats() WriteStats {
+	var stats WriteStats
+	for _, s := range f.sinks {
+		if st := s.Stats(); st.Written {
+			stats = st
+		}
+	}
+	for _, s := range f.sinks {
+		stats.Sinks = append(stats.Sinks, s.timing)
+	}
+	return stats
+}
+
+// checksumDestination hashes the output and saves the digest in the format of
+// sha256sum when finalized. With OUTPUT_COMPRESSION it compresses the output
+// like the file sink does and hashes the compressed file, so the digest
+// checks the file on disk.
+type checksumDestination struct {
+	hash       hash.Hash
+	compressor io.WriteCloser
+	path       string
+	name       string
+	sum        string
+}
+
+func newChecksumDestination(name string) (*checksumDestination, error) {
+	c := &checksumDestination{hash: sha256.New(), path: outputPath(name + outputFileExt() + ".sha256"), name: name}
+	var err error
+	c.compressor, err = newCompressor(c.hash)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checksumDestination) Write(p []byte) (int, error) {
+	if c.compressor != nil {
+		return c.compressor.Write(p)
+	}
+	return c.hash.Write(p)
+}
+
+func (c *checksumDestination) Finalize() error {
+	if c.compressor != nil {
+		if err := c.compressor.Close(); err != nil {
+			return fmt.Errorf("error compressing checksum input: %v", err)
+		}
+	}
+	c.sum = hex.EncodeToString(c.hash.Sum(nil))
+	line := fmt.Sprintf("%s  %s%s\n", c.sum, filepath.Base(c.name), outputFileExt())
+	if err := os.WriteFile(c.path, []byte(line), 0o644); err != nil {
+		return fmt.Errorf("error writing checksum: %v", err)
+	}
+	return nil
+}
+
+// s3Destination uploads the output to SINK_S3_URL as it is written, to the
+// path of the file sink's file under OUTPUT_DIR. Like the file sink it
+// compresses with OUTPUT_COMPRESSION, and the object only appears once
+// finalized.
+type s3Destination struct {
+	upload     *s3Upload
+	compressor io.WriteCloser
+}
+
+func newS3Destination(name string) (*s3Destination, error) {
+	client, err := newS3Client()
+	if err != nil {
+		return nil, err
+	}
+	bucket, prefix, err := parseS3URL(sinkS3URL)
+	if err != nil {
+		return nil, err
+	}
+	file, err := filepath.Rel(outputRoot, outputPath(name+outputFileExt()))
+	if err != nil {
+		return nil, err
+	}
+	key := path.Join(prefix, filepath.ToSlash(file))
+
+	d := &s3Destination{upload: newS3Upload(context.Background(), client, bucket, key)}
+	d.compressor, err = newCompressor(d.upload)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *s3Destination) Write(p []byte) (int, error) {
+	if d.compressor != nil {
+		return d.compressor.Write(p)
+	}
+	return d.upload.Write(p)
+}
+
+func (d *s3Destination) Finalize() error {
+	if d.compressor != nil {
+		if err := d.compressor.Close(); err != nil {
+			return fmt.Errorf("error compressing upload: %v", err)
+		}
+	}
+	return d.upload.Complete()
+}
+
+func (d *s3Destination) Close() error {
+	return d.upload.Abort()
+}
+
+func (d *s3Destination) Stats() WriteStats {
+	return WriteStats{}
+}
+
+func (c *checksumDestination) Close() error {
+	return nil
+}
+
+func (c *checksumDestination) Stats() WriteStats {
+	return WriteStats{}
+}
+
+// discardDestination drops the output, measuring a strategy without the cost
+// of storing its rows.
+type discardDestination struct{}
+
+func (discardDestination) Write(p []byte) (int, error) {
+	return io.Discard.Write(p)
+}
+
+func (discardDestination) Finalize() error {
+	return nil
+}
+
+func (discardDestination) Close() error {
+	return nil
+}
+
+func (discardDestination) Stats() WriteStats {
+	return WriteStats{}
+}
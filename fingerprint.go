@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// fingerprintVariables are the settings of the tool that change what a run
+// measures. Names, notes and output options are left out.
+var fingerprintVariables = []string{
+	"SCENARIO", "STRATEGIES", "KEYS_FILE", "KEYSET_ORDER", "JUMP_STRIDE",
+	"INDEX_ONLY_COMPARE", "PREFETCH", "HOLD_CURSOR", "REFCURSOR", "REFCURSOR_FUNCTION", "PREPARE_COMPARE", "ADAPTIVE_BATCH", "ADAPTIVE_TARGET", "ADAPTIVE_MAX_BYTES", "REVERSE_COMPARE", "RESULT_FORMAT", "RESULT_FORMAT_COMPARE", "LOAD_FILE", "DUCKDB_DATABASE", "CLICKHOUSE_URL", "API_URL", "API_PAGINATION", "WRITE_COMPARE", "DELETE_CHUNK", "DELETE_VACUUM_EVERY", "LOGICAL_DECODING", "SCROLL_BACK_EVERY", "PARALLEL_WORKERS", "PARALLEL_SHARDS", "SNAPSHOT_PARALLEL", "CACHE_FLUSH_TABLE",
+	"MINMAX_CHUNK", "CTID_BLOCKS", "CURSOR_TUPLE_FRACTIONS", "DRIVERS", "BENCH_SCHEMA",
+	"BENCH_TABLE", "BENCH_KEY", "BENCH_COLUMNS", "VALUE_POOLING", "QUERY_FILE", "VALIDATION_RULES",
+	"THINK_TIME", "THINK_TIME_DIST", "STREAM", "STREAM_FORMAT", "OUTPUT_FORMAT", "PARQUET_ROW_GROUP_ROWS", "PARQUET_COMPRESSION", "ARROW_IPC", "ARROW_BATCH_ROWS", "OUTPUT_COMPRESSION", "SINKS",
+	"CSV_HEADER", "CSV_DELIMITER", "CSV_QUOTE", "CSV_LINE_ENDING",
+	"FSYNC_BYTES", "PAUSE_POLICY", "PRE_RUN_SQL", "POST_RUN_SQL", "PRE_STRATEGY_SQL", "POST_STRATEGY_SQL", "QUOTA_ROWS_PER_SEC", "QUOTA_MAX_ACTIVE", "QUOTA_MAX_REPLICATION_LAG", "BATCH_RETRIES", "BLOB_FORMAT", "BLOB_SIZE",
+	"BLOB_LARGE_OBJECTS", "TOAST_SIZE", "TENANTS", "TENANT_ID", "SOFT_DELETE_RATE", "DB_ROLE", "ROW_SECURITY",
+	"POOL_MAX_CONNS", "POOL_MIN_CONNS", "POOL_MAX_CONN_LIFETIME",
+	"POOL_MAX_CONN_IDLE_TIME", "POOL_HEALTH_CHECK_PERIOD",
+}
+
+// fingerprintSettings are the server settings that change what a run
+// measures.
+var fingerprintSettings = []string{
+	"server_version_num", "shared_buffers", "work_mem", "effective_cache_size",
+	"random_page_cost", "seq_page_cost", "effective_io_concurrency",
+	"max_parallel_workers_per_gather", "jit", "huge_pages",
+	"checkpoint_timeout", "max_wal_size", "synchronous_commit",
+}
+
+// Fingerprint identifies the inputs of a run. Runs with different
+// fingerprints measured different things, and Config, Schema and Settings
+// tell which of the inputs differ.
+type Fingerprint struct {
+	Hash     string `json:"hash"`
+	Config   string `json:"config"`
+	Schema   string `json:"schema"`
+	Settings string `json:"settings"`
+}
+
+func fingerprintHash(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// fingerprintRun hashes the configuration of the run, the definition of the
+// benchmark table and its indexes, and the server settings.
+func fingerprintRun(ctx context.Context) (*Fingerprint, error) {
+	config := []string{
+		fmt.Sprintf("DATA_LIMIT=%d", limit),
+		fmt.Sprintf("DATA_BATCH_SIZE=%d", batchSize),
+	}
+	for _, name := range fingerprintVariables {
+		config = append(config, name+"="+os.Getenv(name))
+	}
+
+	var schema []string
+	rows, err := pool.Query(ctx, `
+		SELECT column_name || ' ' || data_type
+		FROM information_schema.columns
+		WHERE table_name = $1
+		UNION ALL
+		SELECT indexdef
+		FROM pg_indexes
+		WHERE tablename = $1`, benchTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		schema = append(schema, line)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", rows.Err())
+	}
+	sort.Strings(schema)
+
+	var settings []string
+	rows, err = pool.Query(ctx, "SELECT name || '=' || setting FROM pg_settings WHERE name = ANY($1) ORDER BY name", fingerprintSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read settings: %w", err)
+		}
+		settings = append(settings, line)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", rows.Err())
+	}
+
+	f := &Fingerprint{
+		Config:   fingerprintHash(config),
+		Schema:   fingerprintHash(schema),
+		Settings: fingerprintHash(settings),
+	}
+	f.Hash = fingerprintHash([]string{f.Config, f.Schema, f.Settings})
+	return f, nil
+}
+
+// Differences names the inputs that differ between two fingerprints.
+func (f *Fingerprint) Differences(other *Fingerprint) []string {
+	var differences []string
+	if f.Config != other.Config {
+		differences = append(differences, "configuration")
+	}
+	if f.Schema != other.Schema {
+		differences = append(differences, "schema")
+	}
+	if f.Settings != other.Settings {
+		differences = append(differences, "server settings")
+	}
+	return differences
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// envFlags are the command line flags of the tool. Every flag sets the
+// environment variable of the same setting, so a flag overrides the variable
+// and the .env file and the rest of the configuration only reads the
+// environment.
+var envFlags = []struct {
+	name  string
+	env   string
+	usage string
+	// boolean flags take no value and set their variable to true
+	boolean bool
+}{
+	{"dsn", "DATABASE_URL", "connection string used instead of the DB_* variables", false},
+	{"limit", "DATA_LIMIT", "highest aid the strategies read", false},
+	{"batch-size", "DATA_BATCH_SIZE", "rows per batch", false},
+	{"strategies", "STRATEGIES", "comma separated strategies to run, or -name to leave one out, default all enabled", false},
+	{"scenario", "SCENARIO", "extra scenario to run: keys, blobs, toast, tenants or softdelete", false},
+	{"keys-file", "KEYS_FILE", "file of keys for the key list lookups, - for stdin", false},
+	{"stream", "STREAM", "strategy to stream to stdout", false},
+	{"stream-format", "STREAM_FORMAT", "format of the stream, csv or ndjson", false},
+	{"output-format", "OUTPUT_FORMAT", "format of the exports, csv, jsonl, parquet or arrow", false},
+	{"csv-delimiter", "CSV_DELIMITER", "delimiter of the csv exports, comma, tab, pipe or a single character", false},
+	{"compress", "OUTPUT_COMPRESSION", "compress the csv and jsonl exports with gzip or zstd", false},
+	{"sinks", "SINKS", "comma separated sinks: file, checksum, s3, discard", false},
+	{"progress-format", "PROGRESS_FORMAT", "progress output, text or jsonl", false},
+	{"force", "FORCE", "overwrite the outputs of a previous run", true},
+	{"smoke-test", "SMOKE_TEST", "only count the rows of the first 1000 keys, writing nothing", true},
+	{"output-dir", "OUTPUT_DIR", "directory the run directories are created in", false},
+	{"run-name", "RUN_NAME", "name of the run in the manifest and of its directory", false},
+	{"run-notes", "RUN_NOTES", "notes of the run in the manifest", false},
+	{"retain-runs", "RETAIN_RUNS", "number of latest runs kept in the output directory", false},
+	{"retain-days", "RETAIN_DAYS", "days the runs in the output directory are kept", false},
+	{"report-template", "REPORT_TEMPLATE", "template to render a report with", false},
+	{"io-settings", "IO_SETTINGS", "comma separated I/O setting variants to repeat the run with", false},
+	{"targets", "TARGETS", "comma separated name=dsn databases to run against", false},
+}
+
+// parseFlags loads the .env file, if there is one, and applies the command
+// line flags on top of the environment. It returns the arguments after the
+// flags, which start with the subcommand.
+func parseFlags() ([]string, error) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] [seed|explain|doctor|export|consistency|mix|sweep|diff|read|bundle|experiment|gc|rpc] [args]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Flags override the environment variable named in their description.")
+		flags.PrintDefaults()
+	}
+
+	envFile := flags.String("env-file", ".env", "file to load environment variables from, if it exists")
+	configPath := flags.String("config", "bench.yaml", "file to read profiles from")
+	profile := flags.String("profile", "", "profile of the config file to run with (BENCH_PROFILE)")
+	for _, f := range envFlags {
+		usage := fmt.Sprintf("%s (%s)", f.usage, f.env)
+		if f.boolean {
+			flags.Bool(f.name, false, usage)
+		} else {
+			flags.String(f.name, "", usage)
+		}
+	}
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+
+	// Without a .env file the configuration comes from the environment and
+	// the flags alone, as in containers and CI
+	if err := godotenv.Load(*envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error loading %s: %w", *envFile, err)
+	}
+
+	// A profile overrides the environment, flags override the profile
+	if *profile == "" {
+		*profile = os.Getenv("BENCH_PROFILE")
+	}
+	if *profile != "" {
+		p, err := loadProfile(*configPath, *profile)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.apply(); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, e := range envFlags {
+			if e.name == f.Name && err == nil {
+				err = os.Setenv(e.env, f.Value.String())
+			}
+		}
+	})
+	return flags.Args(), err
+}
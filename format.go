@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// resultFormat is the result format RESULT_FORMAT forces on custom_cursor,
+// nil to let pgx pick binary for the types it knows and text for the rest.
+var resultFormat pgx.QueryResultFormats
+
+// formatCompare adds custom_cursor_text and custom_cursor_binary, which page
+// like custom_cursor with every column in the text or the binary format.
+var formatCompare bool
+
+// parseResultFormat parses RESULT_FORMAT, text or binary.
+func parseResultFormat(format string) (pgx.QueryResultFormats, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case "text":
+		return pgx.QueryResultFormats{pgx.TextFormatCode}, nil
+	case "binary":
+		return pgx.QueryResultFormats{pgx.BinaryFormatCode}, nil
+	}
+	return nil, fmt.Errorf("unknown result format %s, expected text or binary", format)
+}
+
+func formatName(format pgx.QueryResultFormats) string {
+	if len(format) > 0 && format[0] == pgx.BinaryFormatCode {
+		return "binary"
+	}
+	return "text"
+}
+
+// FormatStats separates the time spent decoding the rows of a result format
+// from the time spent receiving them.
+type FormatStats struct {
+	Format string
+	// Scan is the time spent decoding the rows into their records.
+	Scan time.Duration
+	// Bytes are the encoded values the server sent, without the protocol
+	// framing.
+	Bytes int64
+}
+
+// scan scans a row into its record, timing the scan and counting the bytes of
+// its values. It scans without accounting when s is nil.
+func (s *FormatStats) scan(rows pgx.Rows) ([]string, int, error) {
+	if s == nil {
+		return scanRecord(rows)
+	}
+	for _, v := range rows.RawValues() {
+		s.Bytes += int64(len(v))
+	}
+	start := time.Now()
+	record, key, err := scanRecord(rows)
+	s.Scan += time.Since(start)
+	return record, key, err
+}
+
+func fetchWithTextFormat(ctx context.Context, res chan<- Result) error {
+	return fetchCustomCursor(ctx, res, "custom_cursor_text", pgx.QueryExecModeCacheStatement, pgx.QueryResultFormats{pgx.TextFormatCode})
+}
+
+func fetchWithBinaryFormat(ctx context.Context, res chan<- Result) error {
+	return fetchCustomCursor(ctx, res, "custom_cursor_binary", pgx.QueryExecModeCacheStatement, pgx.QueryResultFormats{pgx.BinaryFormatCode})
+}
+
+// compareFormats compares the scan and transfer time of the text and binary
+// result formats. The transfer time is the query time left after scanning
+// and writing the rows.
+func compareFormats(results []Result) []string {
+	byFormat := map[string]*Result{}
+	for i := range results {
+		if r := &results[i]; r.Err == nil && r.Format != nil && len(r.Batches) > 0 {
+			byFormat[r.Format.Format] = r
+		}
+	}
+
+	var lines []string
+	for _, format := range []string{"text", "binary"} {
+		r := byFormat[format]
+		if r == nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s received %s bytes of %s values, %s transferring and %s scanning",
+			r.Type, human.Int(r.Format.Bytes), format, human.Duration(transferTime(r)), human.Duration(r.Format.Scan)))
+	}
+
+	text, binary := byFormat["text"], byFormat["binary"]
+	if text != nil && binary != nil {
+		lines = append(lines, fmt.Sprintf("text format took %+.1f%% bytes, %+.1f%% transfer and %+.1f%% scan time over binary",
+			100*(float64(text.Format.Bytes)/float64(binary.Format.Bytes)-1),
+			100*(ratio(transferTime(text), transferTime(binary))-1),
+			100*(ratio(text.Format.Scan, binary.Format.Scan)-1)))
+	}
+	return lines
+}
+
+// transferTime is the time the queries of a format strategy spent on the
+// server and the wire.
+func transferTime(r *Result) time.Duration {
+	var query, write time.Duration
+	for _, b := range r.Batches {
+		query += b.Query
+		write += b.Write
+	}
+	return query - write - r.Format.Scan
+}
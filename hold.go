@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// holdCursor enables the cursor_hold strategy.
+var holdCursor bool
+
+// fetchWithHoldCursor declares a WITH HOLD cursor and commits right away, so
+// the batches are fetched outside of any transaction. Committing materializes
+// the whole result of the cursor, which the strategy times separately.
+func fetchWithHoldCursor(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "cursor_hold",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	// A holdable cursor belongs to its session, so every fetch needs the
+	// same connection
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to acquire connection: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, declareHoldCursorQuery("hold_cursor"))
+	if err != nil {
+		err = fmt.Errorf("failed to declare cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// The commit runs the query to completion and stores its rows
+	materializeStart := time.Now()
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.Materialize = time.Since(materializeStart)
+	defer conn.Exec(context.Background(), "CLOSE hold_cursor")
+
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := conn.Query(bctx, fetchCursorQuery("hold_cursor"))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Process each row in the batch
+		var count, firstId, lastId int
+		for rows.Next() {
+			record, aid, err := scanRecord(rows)
+			if err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				firstId = aid
+			}
+			lastId = aid
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("%s %d..%d", keyColumn, firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused, the cursor holds no snapshot
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareHoldCursor compares the holdable cursor with the transaction bound
+// one of the cursor strategy.
+func compareHoldCursor(results []Result) (string, bool) {
+	var hold, cursor *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "cursor_hold":
+			hold = r
+		case r.Type == "cursor":
+			cursor = r
+		}
+	}
+	if hold == nil || cursor == nil {
+		return "", false
+	}
+	return fmt.Sprintf("cursor_hold took %.2fs, %s of it materializing at commit, cursor %.2fs within its transaction",
+		hold.Duration.Seconds(), hold.Materialize, cursor.Duration.Seconds()), true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// runHooks are the SQL scripts run before and after every run and every
+// strategy, read from the files named by the *_SQL variables. Empty hooks
+// are skipped.
+var runHooks struct {
+	PreRun, PostRun           string
+	PreStrategy, PostStrategy string
+}
+
+// HookRun is the timing of one hook, saved in the manifest.
+type HookRun struct {
+	Hook     string  `json:"hook"`
+	Path     string  `json:"path"`
+	Strategy string  `json:"strategy,omitempty"`
+	Seconds  float64 `json:"seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// hookLog collects the hooks of a run, which strategies run concurrently.
+type hookLog struct {
+	mu   sync.Mutex
+	runs []HookRun
+}
+
+// loadHooks reads the PRE_RUN_SQL, POST_RUN_SQL, PRE_STRATEGY_SQL and
+// POST_STRATEGY_SQL paths. The scripts are read when they run, so they can
+// be edited between the runs of several targets.
+func loadHooks() error {
+	hooks := []struct {
+		env  string
+		path *string
+	}{
+		{"PRE_RUN_SQL", &runHooks.PreRun},
+		{"POST_RUN_SQL", &runHooks.PostRun},
+		{"PRE_STRATEGY_SQL", &runHooks.PreStrategy},
+		{"POST_STRATEGY_SQL", &runHooks.PostStrategy},
+	}
+	for _, h := range hooks {
+		path := os.Getenv(h.env)
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s: %v", h.env, err)
+		}
+		*h.path = path
+	}
+	return nil
+}
+
+// run executes the script at path as one simple query, so it may hold
+// several statements, and logs how long it took. Scripts run on any
+// connection of the pool, so session settings they make do not carry over
+// to the strategies.
+func (l *hookLog) run(ctx context.Context, hook, path, strategy string) error {
+	if path == "" {
+		return nil
+	}
+
+	start := time.Now()
+	script, err := os.ReadFile(path)
+	if err == nil {
+		_, err = pool.Exec(ctx, string(script))
+	}
+	if err != nil {
+		err = fmt.Errorf("%s hook %s failed: %w", hook, path, err)
+	}
+
+	entry := HookRun{Hook: hook, Path: path, Strategy: strategy, Seconds: time.Since(start).Seconds()}
+	label := hook + " hook"
+	if strategy != "" {
+		label = strategy + " " + label
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		fmt.Printf("%s %s failed after %s: %v\n", label, path, human.Duration(time.Since(start)), err)
+	} else {
+		fmt.Printf("%s %s took %s\n", label, path, human.Duration(time.Since(start)))
+	}
+
+	l.mu.Lock()
+	l.runs = append(l.runs, entry)
+	l.mu.Unlock()
+	return err
+}
+
+// wrap runs the strategy between the strategy hooks. A strategy whose
+// pre-strategy hook failed is not run and fails with the hook's error.
+func (l *hookLog) wrap(s strategy) func(context.Context, chan<- Result) error {
+	if runHooks.PreStrategy == "" && runHooks.PostStrategy == "" {
+		return s.run
+	}
+	return func(ctx context.Context, res chan<- Result) error {
+		// Keep the results open until the post-strategy hook ran
+		wg.Add(1)
+		defer wg.Done()
+
+		if err := l.run(ctx, "pre-strategy", runHooks.PreStrategy, s.name); err != nil {
+			res <- Result{Type: s.name, Err: err}
+			wg.Done()
+			return err
+		}
+		err := s.run(ctx, res)
+		l.run(ctx, "post-strategy", runHooks.PostStrategy, s.name)
+		return err
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// human formats the numbers and durations of the summary printed to the
+// terminal, chosen by REPORT_LOCALE. The output files, manifest, metrics and
+// progress stream are meant for parsers and always use plain numbers.
+var human numberLocale
+
+// numberLocale is how a locale writes numbers. The zero value is the C locale,
+// which prints numbers and durations the way Go does.
+type numberLocale struct {
+	thousands string
+	decimal   string
+	// humanize writes durations as 1m 42s instead of 1m42.31s.
+	humanize bool
+}
+
+var numberLocales = map[string]numberLocale{
+	"C":  {},
+	"en": {thousands: ",", decimal: ".", humanize: true},
+	"de": {thousands: ".", decimal: ",", humanize: true},
+	"fr": {thousands: " ", decimal: ",", humanize: true},
+	"ch": {thousands: "'", decimal: ".", humanize: true},
+}
+
+// parseReportLocale returns the named locale. Regional variants such as
+// en_US.UTF-8 fall back to their language.
+func parseReportLocale(name string) (numberLocale, error) {
+	if name == "" || name == "POSIX" {
+		return numberLocale{}, nil
+	}
+	if l, ok := numberLocales[name]; ok {
+		return l, nil
+	}
+	language, _, _ := strings.Cut(name, "_")
+	if l, ok := numberLocales[strings.ToLower(language)]; ok {
+		return l, nil
+	}
+
+	names := make([]string, 0, len(numberLocales))
+	for n := range numberLocales {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return numberLocale{}, fmt.Errorf("unknown REPORT_LOCALE %q, use one of %s", name, strings.Join(names, ", "))
+}
+
+// Int writes n with thousands separators.
+func (l numberLocale) Int(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	if l.thousands == "" {
+		return digits
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.thousands)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// Float writes v with prec decimals, separating the thousands of its integer
+// part.
+func (l numberLocale) Float(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if l.decimal == "" {
+		return s
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return s
+	}
+	whole = l.Int(n)
+	if n == 0 && strings.HasPrefix(s, "-") {
+		whole = "-0"
+	}
+	if fraction == "" {
+		return whole
+	}
+	return whole + l.decimal + fraction
+}
+
+// Duration writes d as 1h 2m 3s, 4.12s, 380.5ms or 12µs, or the way Go does
+// in the C locale.
+func (l numberLocale) Duration(d time.Duration) string {
+	if !l.humanize {
+		return d.String()
+	}
+
+	switch {
+	case d >= time.Minute:
+		d = d.Round(time.Second)
+		h, m, s := int64(d/time.Hour), int64(d/time.Minute%60), int64(d/time.Second%60)
+		if h > 0 {
+			return fmt.Sprintf("%sh %dm %ds", l.Int(h), m, s)
+		}
+		return fmt.Sprintf("%dm %ds", m, s)
+	case d >= time.Second:
+		return l.Float(d.Seconds(), 2) + "s"
+	case d >= time.Millisecond:
+		return l.Float(float64(d)/float64(time.Millisecond), 1) + "ms"
+	default:
+		return l.Float(float64(d)/float64(time.Microsecond), 0) + "µs"
+	}
+}
+
+// Seconds writes how long a strategy ran, as 4.12 second in the C locale.
+func (l numberLocale) Seconds(d time.Duration) string {
+	if !l.humanize {
+		return fmt.Sprintf("%.2f second", d.Seconds())
+	}
+	return l.Duration(d)
+}
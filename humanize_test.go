@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReportLocale(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    numberLocale
+		wantErr bool
+	}{
+		{name: "", want: numberLocale{}},
+		{name: "POSIX", want: numberLocale{}},
+		{name: "C", want: numberLocale{}},
+		{name: "de", want: numberLocales["de"]},
+		{name: "en_US.UTF-8", want: numberLocales["en"]},
+		{name: "FR_fr", want: numberLocales["fr"]},
+		{name: "xx", wantErr: true},
+		{name: "xx_DE", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseReportLocale(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReportLocale(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseReportLocale(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNumberLocaleInt(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int64
+		want   string
+	}{
+		{"C", 1234567, "1234567"},
+		{"en", 0, "0"},
+		{"en", 999, "999"},
+		{"en", 1000, "1,000"},
+		{"en", 1234567, "1,234,567"},
+		{"en", -1234567, "-1,234,567"},
+		{"en", -100, "-100"},
+		{"de", 1234567, "1.234.567"},
+		{"fr", 1234567, "1\u202f234\u202f567"},
+		{"ch", 1234567, "1'234'567"},
+	}
+	for _, tt := range tests {
+		if got := numberLocales[tt.locale].Int(tt.n); got != tt.want {
+			t.Errorf("%s Int(%d) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestNumberLocaleFloat(t *testing.T) {
+	tests := []struct {
+		locale string
+		v      float64
+		prec   int
+		want   string
+	}{
+		{"C", 1234567.891, 2, "1234567.89"},
+		{"en", 1234567.891, 2, "1,234,567.89"},
+		{"en", 1234567.891, 0, "1,234,568"},
+		{"de", 1234567.891, 2, "1.234.567,89"},
+		{"fr", 0.5, 1, "0,5"},
+		{"de", -0.25, 2, "-0,25"},
+		{"en", -1234.5, 1, "-1,234.5"},
+	}
+	for _, tt := range tests {
+		if got := numberLocales[tt.locale].Float(tt.v, tt.prec); got != tt.want {
+			t.Errorf("%s Float(%v, %d) = %q, want %q", tt.locale, tt.v, tt.prec, got, tt.want)
+		}
+	}
+}
+
+func TestNumberLocaleDuration(t *testing.T) {
+	tests := []struct {
+		locale string
+		d      time.Duration
+		want   string
+	}{
+		{"C", 102310 * time.Millisecond, "1m42.31s"},
+		{"en", 102310 * time.Millisecond, "1m 42s"},
+		{"en", 3723 * time.Second, "1h 2m 3s"},
+		{"de", 4120 * time.Millisecond, "4,12s"},
+		{"en", 380500 * time.Microsecond, "380.5ms"},
+		{"en", 12 * time.Microsecond, "12µs"},
+	}
+	for _, tt := range tests {
+		if got := numberLocales[tt.locale].Duration(tt.d); got != tt.want {
+			t.Errorf("%s Duration(%s) = %q, want %q", tt.locale, tt.d, got, tt.want)
+		}
+	}
+}
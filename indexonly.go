@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// explainKeysetPage explains the keyset page halfway through the key range,
+// which is representative of every page of the custom cursor strategies.
+func explainKeysetPage(ctx context.Context, columns string) (*Plan, error) {
+	return explain(ctx, keysetPageQuery(columns), keysetPageArgs(limit/2)...)
+}
+
+// fetchWithIndexOnly pages like the custom cursor strategy but only selects
+// the primary key, so every page can be answered by an index only scan.
+func fetchWithIndexOnly(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "custom_cursor_index_only",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := []string{keyColumn}
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	var lastId int
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := pool.Query(bctx, keysetPageQuery(keyName()), keysetPageArgs(lastId)...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		firstId := lastId + 1
+		var count int
+		for rows.Next() {
+			var aid int
+			if err := rows.Scan(&aid); err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, []string{fmt.Sprintf("%d", aid)})
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			lastId = aid
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+
+	if plan, err := explainKeysetPage(ctx, keyName()); err == nil {
+		result.Plan = plan
+	}
+	res <- result
+
+	return nil
+}
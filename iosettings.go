@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ioSettingNames are the I/O settings a session can set that IO_SETTINGS
+// varies. io_combine_limit only exists from Postgres 17.
+var ioSettingNames = []string{"io_combine_limit", "effective_io_concurrency", "maintenance_io_concurrency"}
+
+// sessionSetting is a setting every connection of a run sets.
+type sessionSetting struct {
+	Name  string
+	Value string
+}
+
+// sessionSettings are set on every new connection, for the I/O variant being
+// run.
+var sessionSettings []sessionSetting
+
+// ioVariants are the combinations of I/O settings from IO_SETTINGS the
+// benchmark is repeated with.
+var ioVariants [][]sessionSetting
+
+// parseIOVariants parses a comma separated list of variants, each one or
+// more name=value settings joined with +.
+func parseIOVariants(spec string) ([][]sessionSetting, error) {
+	var variants [][]sessionSetting
+	for _, entry := range strings.Split(spec, ",") {
+		var variant []sessionSetting
+		for _, setting := range strings.Split(strings.TrimSpace(entry), "+") {
+			name, value, ok := strings.Cut(setting, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("expected name=value, got %q", setting)
+			}
+			if !slices.Contains(ioSettingNames, name) {
+				return nil, fmt.Errorf("%s is not one of %s", name, strings.Join(ioSettingNames, ", "))
+			}
+			variant = append(variant, sessionSetting{Name: name, Value: value})
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+func variantLabel(variant []sessionSetting) string {
+	if len(variant) == 0 {
+		return "default"
+	}
+	parts := make([]string, len(variant))
+	for i, s := range variant {
+		parts[i] = s.Name + "=" + s.Value
+	}
+	return strings.Join(parts, "+")
+}
+
+// expandIOVariants repeats every target for the server defaults and then for
+// every variant, so the defaults are the baseline of the comparison.
+func expandIOVariants(targets []Target, variants [][]sessionSetting) []Target {
+	var expanded []Target
+	for _, target := range targets {
+		for _, variant := range append([][]sessionSetting{nil}, variants...) {
+			name := variantLabel(variant)
+			if target.Name != "" {
+				name = target.Name + "-" + name
+			}
+			expanded = append(expanded, Target{Name: name, DSN: target.DSN, Settings: variant})
+		}
+	}
+	return expanded
+}
+
+// missingSettings returns the settings the server at dsn does not know, which
+// would fail every connection that sets them.
+func missingSettings(ctx context.Context, dsn string, settings []sessionSetting) ([]string, error) {
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	var missing []string
+	for _, s := range settings {
+		var known bool
+		err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_settings WHERE name = $1)", s.Name).Scan(&known)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read settings: %w", err)
+		}
+		if !known {
+			missing = append(missing, s.Name)
+		}
+	}
+	return missing, nil
+}
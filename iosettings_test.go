@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIOVariants(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    [][]sessionSetting
+		wantErr bool
+	}{
+		{spec: "io_combine_limit=128kB", want: [][]sessionSetting{{{"io_combine_limit", "128kB"}}}},
+		{
+			spec: "effective_io_concurrency=1, effective_io_concurrency=64+maintenance_io_concurrency=64",
+			want: [][]sessionSetting{
+				{{"effective_io_concurrency", "1"}},
+				{{"effective_io_concurrency", "64"}, {"maintenance_io_concurrency", "64"}},
+			},
+		},
+		{spec: "io_combine_limit", wantErr: true},
+		{spec: "io_combine_limit=", wantErr: true},
+		{spec: "work_mem=64MB", wantErr: true},
+		{spec: "io_combine_limit=128kB+", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseIOVariants(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIOVariants(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIOVariants(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestExpandIOVariants(t *testing.T) {
+	variants := [][]sessionSetting{
+		{{"effective_io_concurrency", "1"}},
+		{{"effective_io_concurrency", "64"}, {"maintenance_io_concurrency", "64"}},
+	}
+	tests := []struct {
+		targets []Target
+		want    []Target
+	}{
+		{
+			targets: []Target{{DSN: "db"}},
+			want: []Target{
+				{Name: "default", DSN: "db"},
+				{Name: "effective_io_concurrency=1", DSN: "db", Settings: variants[0]},
+				{Name: "effective_io_concurrency=64+maintenance_io_concurrency=64", DSN: "db", Settings: variants[1]},
+			},
+		},
+		{
+			targets: []Target{{Name: "a", DSN: "a"}, {Name: "b", DSN: "b"}},
+			want: []Target{
+				{Name: "a-default", DSN: "a"},
+				{Name: "a-effective_io_concurrency=1", DSN: "a", Settings: variants[0]},
+				{Name: "a-effective_io_concurrency=64+maintenance_io_concurrency=64", DSN: "a", Settings: variants[1]},
+				{Name: "b-default", DSN: "b"},
+				{Name: "b-effective_io_concurrency=1", DSN: "b", Settings: variants[0]},
+				{Name: "b-effective_io_concurrency=64+maintenance_io_concurrency=64", DSN: "b", Settings: variants[1]},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := expandIOVariants(tt.targets, variants); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandIOVariants(%+v) = %+v, want %+v", tt.targets, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// jumpStride is the distance in pages between the pages fetched by the jump
+// strategies. Zero disables them.
+var jumpStride int
+
+// fetchWithCursorJump jumps to every jumpStride-th page of a cursor with MOVE
+// and fetches that page, the cursor equivalent of an OFFSET page jump.
+func fetchWithCursorJump(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "cursor_jump",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	// Start a transaction
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, declareCursorQuery("jump_cursor", 0))
+	if err != nil {
+		err = fmt.Errorf("failed to declare cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	skip := (jumpStride - 1) * batchSize
+	for page := 0; ; page += jumpStride {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		// The first page needs no jump, every later one skips the pages in between
+		if page > 0 && skip > 0 {
+			_, err = tx.Exec(bctx, moveCursorQuery("jump_cursor", skip))
+			if err != nil {
+				err = fmt.Errorf("failed to move cursor: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+		}
+
+		rows, err := tx.Query(bctx, fetchForwardQuery("jump_cursor"))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, err := writeAccountRows(bctx, rows, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("page %d", page),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// fetchWithOffsetJump fetches the same pages as fetchWithCursorJump with an
+// OFFSET query per page.
+func fetchWithOffsetJump(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "offset_jump",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	for page := 0; ; page += jumpStride {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := pool.Query(bctx, offsetPageQuery(), offsetPageArgs(page*batchSize)...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, err := writeAccountRows(bctx, rows, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("page %d", page),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sortKey is one column of a keyset sort specification.
+type sortKey struct {
+	column string
+	desc   bool
+	// tieBreaker is set on the aid appended to a specification without it.
+	tieBreaker bool
+}
+
+// keysetOrder is the sort specification of the keyset_multi strategy, set
+// from KEYSET_ORDER.
+var keysetOrder []sortKey
+
+var accountColumns = map[string]int{"aid": 0, "bid": 1, "abalance": 2}
+
+// parseSortSpec parses an ORDER BY list such as "bid DESC, aid ASC". aid is
+// appended as a tie breaker when missing, since keyset pagination needs a
+// total order to not skip or repeat rows.
+func parseSortSpec(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(strings.ToLower(part))
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid sort key %q", strings.TrimSpace(part))
+		}
+
+		key := sortKey{column: fields[0]}
+		if _, ok := accountColumns[key.column]; !ok {
+			return nil, fmt.Errorf("unknown sort column %q", key.column)
+		}
+		if seen[key.column] {
+			return nil, fmt.Errorf("duplicate sort column %q", key.column)
+		}
+		seen[key.column] = true
+
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "asc":
+			case "desc":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q", fields[1])
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	if !seen["aid"] {
+		keys = append(keys, sortKey{column: "aid", tieBreaker: true})
+	}
+	return keys, nil
+}
+
+// keysetPageOrder is the sort specification the pages of the order are read
+// in. Servers with FETCH FIRST ... WITH TIES page by the given columns alone,
+// as a page then ends after the last row tied with its last row, and need no
+// index that includes the aid tie breaker.
+func keysetPageOrder(order []sortKey) (keys []sortKey, withTies bool) {
+	if n := len(order); n > 1 && order[n-1].tieBreaker && server.WithTies {
+		return order[:n-1], true
+	}
+	return order, false
+}
+
+func orderByClause(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.desc {
+			direction = "DESC"
+		}
+		parts[i] = key.column + " " + direction
+	}
+	return strings.Join(parts, ", ")
+}
+
+// seekPredicate returns the condition selecting the rows after the row whose
+// sort key values are bound to $1..$n. Row comparisons like (a, b) > ($1, $2)
+// only work when every column sorts the same way, so mixed directions expand
+// to (a > $1) OR (a = $1 AND b < $2) OR ...
+func seekPredicate(keys []sortKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		var conditions []string
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", keys[j].column, j+1))
+		}
+
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", key.column, op, i+1))
+		terms[i] = "(" + strings.Join(conditions, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// compareRows compares two account rows by the sort specification and returns
+// a negative number when a sorts before b.
+func compareRows(keys []sortKey, a, b [3]int) int {
+	for _, key := range keys {
+		i := accountColumns[key.column]
+		if a[i] == b[i] {
+			continue
+		}
+		less := a[i] < b[i]
+		if key.desc {
+			less = !less
+		}
+		if less {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// fetchWithKeysetMulti pages by the KEYSET_ORDER sort specification and checks
+// that the exported rows really come out in that order.
+func fetchWithKeysetMulti(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "keyset_multi",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := []string{"aid", "bid", "abalance"}
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	order, withTies := keysetPageOrder(keysetOrder)
+	var last [3]int
+	var misordered int
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		// The first page has no seek predicate
+		first := len(result.Batches) == 0
+		var args []any
+		if !first {
+			for _, key := range order {
+				args = append(args, last[accountColumns[key.column]])
+			}
+		}
+
+		rows, err := pool.Query(bctx, keysetMultiPageQuery(keysetOrder, first), args...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var count int
+		for rows.Next() {
+			var row [3]int
+			if err := rows.Scan(&row[0], &row[1], &row[2]); err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			record := []string{
+				fmt.Sprintf("%d", row[0]),
+				fmt.Sprintf("%d", row[1]),
+				fmt.Sprintf("%d", row[2]),
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			// Every row must sort strictly after the one before it, or
+			// after or tied with it within a page fetched with ties
+			if count > 0 || len(result.Batches) > 0 {
+				c := compareRows(order, last, row)
+				if c > 0 || (c == 0 && !(withTies && count > 0)) {
+					misordered++
+				}
+			}
+			last = row
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("after %v", args),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	if misordered > 0 {
+		err := fmt.Errorf("keyset_multi: %d rows out of %s order", misordered, orderByClause(order))
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSortSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []sortKey
+		wantErr bool
+	}{
+		{spec: "aid", want: []sortKey{{column: "aid"}}},
+		{spec: "bid DESC, aid ASC", want: []sortKey{{column: "bid", desc: true}, {column: "aid"}}},
+		{spec: "BID desc", want: []sortKey{{column: "bid", desc: true}, {column: "aid", tieBreaker: true}}},
+		{spec: "abalance, bid desc", want: []sortKey{{column: "abalance"}, {column: "bid", desc: true}, {column: "aid", tieBreaker: true}}},
+		{spec: "aid desc", want: []sortKey{{column: "aid", desc: true}}},
+		{spec: "filler", wantErr: true},
+		{spec: "bid, bid", wantErr: true},
+		{spec: "bid sideways", wantErr: true},
+		{spec: "bid desc nulls", wantErr: true},
+		{spec: "bid,", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSortSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSortSpec(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseSortSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSeekPredicate(t *testing.T) {
+	tests := []struct {
+		keys []sortKey
+		want string
+	}{
+		{[]sortKey{{column: "aid"}}, "((aid > $1))"},
+		{[]sortKey{{column: "aid", desc: true}}, "((aid < $1))"},
+		{
+			[]sortKey{{column: "bid", desc: true}, {column: "aid"}},
+			"((bid < $1) OR (bid = $1 AND aid > $2))",
+		},
+		{
+			[]sortKey{{column: "abalance"}, {column: "bid", desc: true}, {column: "aid"}},
+			"((abalance > $1) OR (abalance = $1 AND bid < $2) OR (abalance = $1 AND bid = $2 AND aid > $3))",
+		},
+	}
+	for _, tt := range tests {
+		if got := seekPredicate(tt.keys); got != tt.want {
+			t.Errorf("seekPredicate(%+v) = %s, want %s", tt.keys, got, tt.want)
+		}
+	}
+}
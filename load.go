@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// loadFile is the CSV export, in the CSV dialect of the exports, that the
+// load strategies write back into the database. Empty disables them.
+var loadFile string
+
+// maxBindParameters is the most parameters a statement can bind.
+const maxBindParameters = 65535
+
+// LoadStats describes the rows a load strategy wrote.
+type LoadStats struct {
+	Table string
+	Rows  int64
+}
+
+// resolveLoadFile resolves the links in path before the run moves the latest
+// link to its own directory, so LOAD_FILE can name the export of the previous
+// run through it.
+func resolveLoadFile(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+// openLoadFile opens the load file and reads its header. A file without a
+// header holds the exported columns, BENCH_COLUMNS.
+func openLoadFile() (*os.File, *loadReader, []string, error) {
+	file, err := os.Open(loadFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error opening LOAD_FILE: %v", err)
+	}
+	reader := &loadReader{r: &fastCSVReader{
+		r:         bufio.NewReaderSize(file, readBufferSize),
+		delimiter: exportCSV.delimiter,
+		quote:     exportCSV.quote,
+	}}
+	if !exportCSV.header {
+		return file, reader, slices.Clone(projection), nil
+	}
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("error reading header of LOAD_FILE: %v", err)
+	}
+	return file, reader, slices.Clone(header), nil
+}
+
+// loadReader reads the records of the load file as strings, which are valid
+// until the next Read. Every record must have as many fields as the first.
+type loadReader struct {
+	r      *fastCSVReader
+	record []string
+	fields int
+	line   int
+}
+
+func (l *loadReader) Read() ([]string, error) {
+	fields, err := l.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	l.line++
+	if l.fields == 0 {
+		l.fields = len(fields)
+	} else if len(fields) != l.fields {
+		return nil, fmt.Errorf("record %d has %d fields, expected %d", l.line, len(fields), l.fields)
+	}
+	l.record = l.record[:0]
+	for _, field := range fields {
+		l.record = append(l.record, string(field))
+	}
+	return l.record, nil
+}
+
+// loader writes the rows of the load file into table and returns how many it
+// wrote, recording batches into result where it can tell them apart.
+type loader func(ctx context.Context, table string, columns []string, result *Result) (int64, error)
+
+// loadTableCreator creates the empty table a load strategy writes into.
+type loadTableCreator func(ctx context.Context, table string, columns []string) error
+
+// runLoad runs a load strategy into its own empty table, bench_<strategy>,
+// with the columns of the load file, created by create.
+func runLoad(ctx context.Context, res chan<- Result, name string, create loadTableCreator, load loader) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: name,
+		Load: &LoadStats{Table: "bench_" + name},
+	}
+
+	file, _, columns, err := openLoadFile()
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	file.Close()
+
+	if err := create(ctx, result.Load.Table, columns); err != nil {
+		err = fmt.Errorf("failed to create %s: %w", result.Load.Table, err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Time the load only, not creating its table
+	start = time.Now()
+	rows, err := load(ctx, result.Load.Table, columns, &result)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.Load.Rows = rows
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// createLoadTable creates the table of a load strategy as an empty copy of
+// the benchmark table.
+func createLoadTable(ctx context.Context, table string, columns []string) error {
+	for _, statement := range createLoadTableQueries(table, columns) {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadWithInsert writes the rows with multi-row INSERT statements of
+// DATA_BATCH_SIZE rows each, or as many as fit into the bind parameters.
+func loadWithInsert(ctx context.Context, res chan<- Result) error {
+	return runLoad(ctx, res, "load_insert", createLoadTable, func(ctx context.Context, table string, columns []string, result *Result) (int64, error) {
+		file, reader, _, err := openLoadFile()
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		perStatement := min(batchSize, maxBindParameters/len(columns))
+		args := make([]any, 0, perStatement*len(columns))
+		var rows int64
+		var pending int
+		batchStart := time.Now()
+
+		flush := func() error {
+			bctx, timings := traceQueries(ctx)
+			if _, err := pool.Exec(bctx, insertRowsQuery(table, columns, pending), args...); err != nil {
+				return fmt.Errorf("failed to insert rows: %w", err)
+			}
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("rows %d..%d", rows-int64(pending)+1, rows),
+				Rows:     pending,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+			args, pending = args[:0], 0
+			batchStart = time.Now()
+			return nil
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return rows, fmt.Errorf("error reading LOAD_FILE: %v", err)
+			}
+			for _, value := range record {
+				args = append(args, loadValue(value))
+			}
+			rows++
+			pending++
+
+			if pending == perStatement {
+				if err := flush(); err != nil {
+					return rows, err
+				}
+			}
+		}
+		if pending > 0 {
+			if err := flush(); err != nil {
+				return rows, err
+			}
+		}
+		return rows, nil
+	})
+}
+
+// loadWithCopy streams the load file as it is to COPY FROM STDIN, letting
+// the server parse the CSV.
+func loadWithCopy(ctx context.Context, res chan<- Result) error {
+	return runLoad(ctx, res, "load_copy", createLoadTable, func(ctx context.Context, table string, columns []string, result *Result) (int64, error) {
+		file, err := os.Open(loadFile)
+		if err != nil {
+			return 0, fmt.Errorf("error opening LOAD_FILE: %v", err)
+		}
+		defer file.Close()
+
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		defer conn.Release()
+
+		tag, err := conn.Conn().PgConn().CopyFrom(ctx, file, copyFromCommand(table, columns))
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy rows: %w", err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
+
+// loadWithCopyFrom parses the load file on the client and sends the rows with
+// pgx.CopyFrom, which uses the binary COPY format.
+func loadWithCopyFrom(ctx context.Context, res chan<- Result) error {
+	return runLoad(ctx, res, "load_copyfrom", createLoadTable, func(ctx context.Context, table string, columns []string, result *Result) (int64, error) {
+		file, reader, _, err := openLoadFile()
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		defer conn.Release()
+
+		// The binary format needs values of the column types, not text
+		rows, err := conn.Query(ctx, loadColumnsQuery(table, columns))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		fields := rows.FieldDescriptions()
+		rows.Close()
+
+		types := conn.Conn().TypeMap()
+		codecs := make([]*pgtype.Type, len(fields))
+		for i, field := range fields {
+			t, ok := types.TypeForOID(field.DataTypeOID)
+			if !ok {
+				return 0, fmt.Errorf("column %s has a type pgx can not copy in binary", columns[i])
+			}
+			codecs[i] = t
+		}
+
+		values := make([]any, len(columns))
+		source := pgx.CopyFromFunc(func() ([]any, error) {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading LOAD_FILE: %v", err)
+			}
+			for i, value := range record {
+				if value == "" {
+					values[i] = nil
+					continue
+				}
+				t := codecs[i]
+				if ts, ok := exportedTime(t.OID, value); ok {
+					values[i] = ts
+					continue
+				}
+				values[i], err = t.Codec.DecodeValue(types, t.OID, pgtype.TextFormatCode, []byte(value))
+				if err != nil {
+					return nil, fmt.Errorf("error decoding %s value %q: %v", columns[i], value, err)
+				}
+			}
+			return values, nil
+		})
+
+		copied, err := conn.Conn().CopyFrom(ctx, pgx.Identifier{table}, columns, source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy rows: %w", err)
+		}
+		return copied, nil
+	})
+}
+
+// exportedTime parses a value of a date or timestamp column the way
+// formatValue exports it, as RFC 3339, which the text codecs do not take.
+func exportedTime(oid uint32, value string) (time.Time, bool) {
+	switch oid {
+	case pgtype.TimestamptzOID, pgtype.TimestampOID, pgtype.DateOID:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// loadValue is the parameter of a CSV value, where an empty value is NULL
+// like in the exports.
+func loadValue(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// compareLoads compares the rows per second of the load strategies.
+func compareLoads(results []Result) []string {
+	var lines []string
+	for _, r := range results {
+		if r.Err != nil || r.Load == nil || r.Duration == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s loaded %s rows into %s at %s rows/sec",
+			r.Type, human.Int(r.Load.Rows), r.Load.Table, human.Float(float64(r.Load.Rows)/r.Duration.Seconds(), 0)))
+	}
+	return lines
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lookupKeys are the account ids looked up by the key list strategies.
+var lookupKeys []int
+
+// readKeys reads one key per line from the file at path, or from stdin when
+// path is "-". Blank lines are ignored.
+func readKeys(path string) ([]int, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening key file: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var keys []int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", line, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading keys: %v", err)
+	}
+	return keys, nil
+}
+
+// writeAccountRows writes every row to the sink and returns how many there were.
+func writeAccountRows(ctx context.Context, rows pgx.Rows, out rowSink, sizes *rowSizeRecorder) (int, error) {
+	defer rows.Close()
+
+	timings := timingsFrom(ctx)
+
+	var count int
+	for rows.Next() {
+		record, _, err := scanRecord(rows)
+		if err != nil {
+			return count, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		n, err := timings.WriteRow(out, record)
+		if err != nil {
+			return count, err
+		}
+		sizes.Add(n)
+		count++
+	}
+
+	if rows.Err() != nil {
+		return count, fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+	}
+	return count, nil
+}
+
+// fetchWithKeyArray looks the keys up in chunks of the batch size with a
+// single = ANY($1) query per chunk.
+func fetchWithKeyArray(ctx context.Context, res chan<- Result) error {
+	return fetchKeys(ctx, res, "key_array", func(ctx context.Context, chunk []int, out rowSink, sizes *rowSizeRecorder) (int, error) {
+		rows, err := pool.Query(ctx, keyArrayQuery(), chunk)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch data: %w", err)
+		}
+		return writeAccountRows(ctx, rows, out, sizes)
+	})
+}
+
+// fetchWithKeyPoint looks every key up with its own query, one round trip per
+// key. Batches still group batch size keys so timings stay comparable.
+func fetchWithKeyPoint(ctx context.Context, res chan<- Result) error {
+	return fetchKeys(ctx, res, "key_point", func(ctx context.Context, chunk []int, out rowSink, sizes *rowSizeRecorder) (int, error) {
+		var count int
+		for _, key := range chunk {
+			rows, err := pool.Query(ctx, keyPointQuery(), key)
+			if err != nil {
+				return count, fmt.Errorf("failed to fetch data: %w", err)
+			}
+			n, err := writeAccountRows(ctx, rows, out, sizes)
+			count += n
+			if err != nil {
+				return count, err
+			}
+		}
+		return count, nil
+	})
+}
+
+type lookupFunc func(ctx context.Context, chunk []int, out rowSink, sizes *rowSizeRecorder) (int, error)
+
+func fetchKeys(ctx context.Context, res chan<- Result, name string, lookup lookupFunc) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: name,
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	for i := 0; i < len(lookupKeys); i += batchSize {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+		chunk := lookupKeys[i:min(i+batchSize, len(lookupKeys))]
+
+		count, err := lookup(bctx, chunk, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("keys %d..%d", i+1, i+len(chunk)),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// fetchWithKeyJoin copies the keys into a temporary table and joins it against
+// the accounts in a single query.
+func fetchWithKeyJoin(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "key_join",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// The temporary table only lives as long as the transaction
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "CREATE TEMPORARY TABLE lookup_keys (aid int PRIMARY KEY) ON COMMIT DROP")
+	if err != nil {
+		err = fmt.Errorf("failed to create key table: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	loadStart := time.Now()
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"lookup_keys"}, []string{"aid"},
+		pgx.CopyFromSlice(len(lookupKeys), func(i int) ([]any, error) {
+			return []any{lookupKeys[i]}, nil
+		}))
+	if err != nil {
+		err = fmt.Errorf("failed to copy keys: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.addBatch(ctx, Batch{
+		Seq:      1,
+		Start:    loadStart,
+		Key:      "load keys",
+		Duration: time.Since(loadStart),
+	})
+
+	sizes := newRowSizeRecorder()
+
+	queryStart := time.Now()
+	qctx, timings := traceQueries(ctx)
+	rows, err := tx.Query(qctx, keyJoinQuery())
+	if err != nil {
+		err = fmt.Errorf("failed to fetch data: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	count, err := writeAccountRows(qctx, rows, out, sizes)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.addBatch(ctx, Batch{
+		Seq:      2,
+		Start:    queryStart,
+		Key:      "join",
+		Rows:     count,
+		Duration: time.Since(queryStart),
+
+		QueryTimings: *timings,
+	})
+
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
@@ -2,90 +2,1367 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
 )
 
 var wg sync.WaitGroup
 var pool *pgxpool.Pool
-var limit int
-var batchSize int
+var limit = 1000000
+var batchSize = 100
+var anomalyK float64
+var think thinkTime
+var capacityConcurrency int
+var indexOnlyCompare bool
 
 type Result struct {
-	Type    string
-	Err     error
-	Message string
+	Type     string
+	Err      error
+	Message  string
+	Duration time.Duration
+	Batches  []Batch
+	Outliers []Batch
+
+	// ThinkTime is the total time spent pausing between pages and is
+	// included in Duration.
+	ThinkTime time.Duration
+	// Paused is the time the strategy was held by a pause and is included
+	// in Duration.
+	Paused time.Duration
+	// Throttled is the time the strategy was held back by the quotas and is
+	// included in Duration.
+	Throttled time.Duration
+
+	Capacity  *Capacity
+	CostModel *CostModel
+	RowSizes  RowSizes
+	Writes    WriteStats
+
+	// Notes are the lines a registered Strategy reported with its metrics.
+	Notes []string
+
+	// Connections are the pool connections the strategy held.
+	Connections *ConnUsage
+
+	// Prefetch describes the overlap of a prefetching strategy.
+	Prefetch *PrefetchStats
+
+	// Skip describes the key ranges a skip scan read and skipped.
+	Skip *SkipStats
+
+	// Scroll times the backward fetches of the scroll cursor strategy.
+	Scroll *ScrollStats
+
+	// Snapshot describes the snapshot the workers of the snapshot parallel
+	// strategy shared.
+	Snapshot *SnapshotStats
+
+	// Materialize is the time a holdable cursor took to store its rows at
+	// commit.
+	Materialize time.Duration
+	// Open is the time the function of the refcursor strategy took to open
+	// its cursor.
+	Open time.Duration
+
+	// Load describes the rows a load strategy wrote back into the database.
+	Load *LoadStats
+
+	// Write describes the write amplification of a write strategy.
+	Write *WriteAmplification
+
+	// Adaptive describes how the adaptive cursor strategy tuned its FETCH
+	// size.
+	Adaptive *AdaptiveStats
+
+	// Concurrency describes how the adaptive parallel strategy tuned its
+	// worker count.
+	Concurrency *ConcurrencyStats
+
+	// Decoding describes the changes read by the logical decoding strategy.
+	Decoding *DecodingStats
+
+	// Format times the scans of a strategy that forces the result format.
+	Format *FormatStats
+
+	// Pooling compares the bytes of values of in-memory pages with and
+	// without VALUE_POOLING.
+	Pooling *PoolStats
+
+	// Blobs accounts for the binary values of the blob scenario.
+	Blobs *BlobStats
+
+	// Quarantined are the key ranges that kept failing after BATCH_RETRIES
+	// retries and were left out of the export.
+	Quarantined []QuarantinedRange
+
+	// SoftDelete checks the export of a soft delete scenario strategy
+	// against the rows deleted while it ran.
+	SoftDelete *SoftDeleteCheck
+
+	// Plan is the EXPLAIN ANALYZE output of a representative page query,
+	// for the strategies that capture one.
+	Plan *Plan
+	// RangePlan is the plan of the equivalent range page, for the
+	// strategies that compare their pages against it.
+	RangePlan *Plan
+	// Estimate is the plan node whose row estimate is furthest off the
+	// rows it returned.
+	Estimate *RowEstimate
+}
+
+type Batch struct {
+	Seq      int
+	Start    time.Time
+	Key      string
+	Rows     int
+	Duration time.Duration
+
+	QueryTimings
+	NearCheckpoint bool
+	// Plan is the plan of the batch's slowest query, captured when the
+	// batch missed BATCH_DEADLINE.
+	Plan *Plan
+	// TraceID is set on the batches sampled as exemplars of the metrics.
+	TraceID string
 }
 
 func main() {
-	err := godotenv.Load()
+	args, err := parseFlags()
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if dataLimit := os.Getenv("DATA_LIMIT"); dataLimit != "" {
+		limit, err = strconv.Atoi(dataLimit)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if dataBatch := os.Getenv("DATA_BATCH_SIZE"); dataBatch != "" {
+		batchSize, err = strconv.Atoi(dataBatch)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	anomalyK = 3
+	if k := os.Getenv("ANOMALY_K"); k != "" {
+		anomalyK, err = strconv.ParseFloat(k, 64)
+		if err != nil {
+			fmt.Println("Error converting string to float:", err)
+			return
+		}
+	}
+
+	think, err = parseThinkTime(os.Getenv("THINK_TIME"), os.Getenv("THINK_TIME_DIST"))
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		fmt.Println(err)
+		return
+	}
+
+	if c := os.Getenv("CAPACITY_CONCURRENCY"); c != "" {
+		capacityConcurrency, err = strconv.Atoi(c)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	streamStrategy = os.Getenv("STREAM")
+	streamFormat = os.Getenv("STREAM_FORMAT")
+	if streamFormat == "" {
+		streamFormat = "csv"
+	}
+	if streamFormat != "csv" && streamFormat != "ndjson" {
+		fmt.Println("Unknown stream format:", streamFormat)
+		return
+	}
+	outputFormat = os.Getenv("OUTPUT_FORMAT")
+	if outputFormat == "" {
+		outputFormat = "csv"
+	}
+	if outputFormat != "csv" && outputFormat != "jsonl" && outputFormat != "parquet" && outputFormat != "arrow" {
+		fmt.Println("Unknown output format:", outputFormat)
+		return
+	}
+	if err := loadCompression(os.Getenv("OUTPUT_COMPRESSION")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := loadCSVDialect(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if streamStrategy != "" {
+		// Keep stdout for the streamed rows, everything else goes to stderr
+		os.Stdout = os.Stderr
+	}
+
+	switch f := os.Getenv("PROGRESS_FORMAT"); f {
+	case "", "text":
+	case "jsonl":
+		if streamStrategy != "" {
+			fmt.Println("PROGRESS_FORMAT=jsonl and STREAM both need stdout")
+			return
+		}
+		// Keep stdout for the progress stream, everything else goes to stderr
+		progress.open(os.Stdout)
+		os.Stdout = os.Stderr
+	default:
+		fmt.Println("Unknown progress format:", f)
+		return
+	}
+
+	indexOnlyCompare = os.Getenv("INDEX_ONLY_COMPARE") == "true"
+
+	if spec := os.Getenv("KEYSET_ORDER"); spec != "" {
+		keysetOrder, err = parseSortSpec(spec)
+		if err != nil {
+			fmt.Println("Invalid keyset order:", err)
+			return
+		}
+	}
+
+	if p := os.Getenv("PAUSE_POLICY"); p != "" {
+		if p != "hold" && p != "release" {
+			fmt.Println("Unknown pause policy:", p)
+			return
+		}
+		pausePolicy = p
+	}
+
+	if j := os.Getenv("JUMP_STRIDE"); j != "" {
+		jumpStride, err = strconv.Atoi(j)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if b := os.Getenv("FSYNC_BYTES"); b != "" {
+		syncBytes, err = strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	forceOverwrite = os.Getenv("FORCE") == "true"
+	bundleExports = os.Getenv("BUNDLE_EXPORTS") == "true"
+	valuePooling = os.Getenv("VALUE_POOLING") == "true"
+	writeRejects = os.Getenv("VALIDATION_REJECTS") == "true"
+	parallelShards = os.Getenv("PARALLEL_SHARDS") == "true"
+	snapshotParallel = os.Getenv("SNAPSHOT_PARALLEL") == "true"
+	holdCursor = os.Getenv("HOLD_CURSOR") == "true"
+	prepareCompare = os.Getenv("PREPARE_COMPARE") == "true"
+	writeCompare = os.Getenv("WRITE_COMPARE") == "true"
+	reverseCompare = os.Getenv("REVERSE_COMPARE") == "true"
+	decodingPlugin = os.Getenv("LOGICAL_DECODING")
+	if decodingPlugin != "" && !validDecodingPlugin(decodingPlugin) {
+		fmt.Println("Unknown logical decoding plugin:", decodingPlugin)
+		return
+	}
+	formatCompare = os.Getenv("RESULT_FORMAT_COMPARE") == "true"
+	resultFormat, err = parseResultFormat(os.Getenv("RESULT_FORMAT"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	loadFile = resolveLoadFile(os.Getenv("LOAD_FILE"))
+	refcursorFunction = os.Getenv("REFCURSOR_FUNCTION")
+	refcursorEnabled = os.Getenv("REFCURSOR") == "true" || refcursorFunction != ""
+	cleanOrphans = os.Getenv("CLEAN_ORPHANS") != "false"
+	cleanOrphanSessions = os.Getenv("CLEAN_ORPHAN_SESSIONS") == "true"
+
+	human, err = parseReportLocale(os.Getenv("REPORT_LOCALE"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if policy := os.Getenv("SCHEMA_POLICY"); policy != "" {
+		if err := validSchemaPolicy(policy); err != nil {
+			fmt.Println(err)
+			return
+		}
+		schemaPolicy = policy
+	}
+
+	if rules := os.Getenv("VALIDATION_RULES"); rules != "" {
+		validationRules, err = parseValidationRules(rules)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	smokeTest = os.Getenv("SMOKE_TEST") == "true"
+
+	if sinks := os.Getenv("SINKS"); sinks != "" {
+		sinkNames = nil
+		for _, sink := range strings.Split(sinks, ",") {
+			sink = strings.TrimSpace(sink)
+			if !validSink(sink) {
+				fmt.Println("Unknown sink:", sink)
+				return
+			}
+			sinkNames = append(sinkNames, sink)
+		}
+	}
+	if slices.Contains(sinkNames, "s3") {
+		sinkS3URL = os.Getenv("SINK_S3_URL")
+		if _, _, err := parseS3URL(sinkS3URL); err != nil {
+			fmt.Println("SINK_S3_URL must be set for the s3 sink:", err)
+			return
+		}
+		if _, err := newS3Client(); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if smokeTest {
+		applySmokeTest()
+	}
+
+	if f := os.Getenv("BLOB_FORMAT"); f != "" {
+		switch f {
+		case "hex", "base64", "skip", "file":
+			blobFormat = f
+		default:
+			fmt.Println("BLOB_FORMAT must be hex, base64, skip or file")
+			return
+		}
+	}
+	if b := os.Getenv("BLOB_SIZE"); b != "" {
+		blobSize, err = strconv.Atoi(b)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+	blobLargeObjects = os.Getenv("BLOB_LARGE_OBJECTS") == "true"
+
+	if t := os.Getenv("TOAST_SIZE"); t != "" {
+		toastSize, err = strconv.Atoi(t)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if t := os.Getenv("TENANTS"); t != "" {
+		tenantCount, err = strconv.Atoi(t)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+	if t := os.Getenv("TENANT_ID"); t != "" {
+		tenantID, err = strconv.Atoi(t)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if r := os.Getenv("SOFT_DELETE_RATE"); r != "" {
+		softDeleteRate, err = strconv.Atoi(r)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if r := os.Getenv("BATCH_RETRIES"); r != "" {
+		batchRetries, err = strconv.Atoi(r)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if c := os.Getenv("CONSISTENCY_ROWS"); c != "" {
+		consistencyRows, err = strconv.Atoi(c)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if w := os.Getenv("PARALLEL_WORKERS"); w != "" {
+		parallelWorkers, err = strconv.Atoi(w)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if f := os.Getenv("CURSOR_TUPLE_FRACTIONS"); f != "" {
+		cursorTupleFractions, err = parseFractions(f)
+		if err != nil {
+			fmt.Println("Invalid CURSOR_TUPLE_FRACTIONS:", err)
+			return
+		}
+	}
+
+	if d := os.Getenv("DRIVERS"); d != "" {
+		for _, driver := range strings.Split(d, ",") {
+			driver = strings.TrimSpace(driver)
+			if !validDriver(driver) {
+				fmt.Println("Unknown driver:", driver)
+				return
+			}
+			drivers = append(drivers, driver)
+		}
+	}
+
+	if c := os.Getenv("MINMAX_CHUNK"); c != "" {
+		minmaxChunk, err = strconv.Atoi(c)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if c := os.Getenv("DELETE_CHUNK"); c != "" {
+		deleteChunk, err = strconv.Atoi(c)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if v := os.Getenv("DELETE_VACUUM_EVERY"); v != "" {
+		deleteVacuumEvery, err = strconv.Atoi(v)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if s := os.Getenv("SCROLL_BACK_EVERY"); s != "" {
+		scrollBackEvery, err = strconv.Atoi(s)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if c := os.Getenv("CTID_BLOCKS"); c != "" {
+		ctidBlocks, err = strconv.Atoi(c)
+		if err != nil {
+			fmt.Println("Error converting string to int:", err)
+			return
+		}
+	}
+
+	if d := os.Getenv("BATCH_DEADLINE"); d != "" {
+		batchDeadline, err = time.ParseDuration(d)
+		if err != nil {
+			fmt.Println("Error parsing BATCH_DEADLINE:", err)
+			return
+		}
+	}
+
+	sessionRole = os.Getenv("DB_ROLE")
+	if r := os.Getenv("ROW_SECURITY"); r != "" {
+		if r != "on" && r != "off" {
+			fmt.Println("ROW_SECURITY must be on or off")
+			return
+		}
+		rowSecurity = r
+	}
+
+	if dir := os.Getenv("OUTPUT_DIR"); dir != "" {
+		outputDir = dir
+		outputRoot = dir
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+
+	if v := os.Getenv("IO_SETTINGS"); v != "" {
+		ioVariants, err = parseIOVariants(v)
+		if err != nil {
+			fmt.Println("Invalid IO_SETTINGS:", err)
+			return
+		}
+	}
+
+	metricsAddr = os.Getenv("METRICS_ADDR")
+	if r := os.Getenv("METRICS_HASH_ROWS"); r != "" {
+		metricsHashRows, err = strconv.ParseInt(r, 10, 64)
+		if err != nil || metricsHashRows <= 0 {
+			fmt.Println("METRICS_HASH_ROWS must be a positive number:", r)
+			return
+		}
+	}
+
+	if err := loadTable(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := loadQuery(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadPoolSettings(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadQuotas(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadHooks(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadAdaptive(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadAdaptiveWorkers(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadRetention(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadSweep(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadWarehouses(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadRestAPI(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadParquet(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := loadArrow(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if t := os.Getenv("TARGETS"); t != "" {
+		targets, err = parseTargets(t)
+		if err != nil {
+			fmt.Println("Invalid TARGETS:", err)
+			return
+		}
+	}
+
+	if len(args) > 0 && args[0] == "explain" {
+		var name string
+		if len(args) > 1 {
+			name = args[1]
+		}
+		if err := explainStrategy(name); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "diff" {
+		if len(args) != 3 {
+			log.Fatal("usage: diff <old manifest.json> <new manifest.json>")
+		}
+		if err := diffRuns(args[1], args[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "read" {
+		if err := runRead(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "bundle" {
+		if err := runBundle(context.Background(), args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "gc" {
+		if err := runGC(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "experiment" {
+		if len(args) < 2 || len(args) > 3 || args[1] != "run" {
+			log.Fatal("usage: experiment run [experiments.yaml]")
+		}
+		path := "experiments.yaml"
+		if len(args) == 3 {
+			path = args[2]
+		}
+		if err := runExperiment(context.Background(), path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	dsn, err := buildDSN()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// Clean up after runs that crashed before the pool of this one connects
+	if cleanOrphans {
+		if err := cleanPartialFiles(outputDir); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if cleanOrphanSessions {
+		if err := cleanOrphanedSessions(ctx, dsn); err != nil {
+			fmt.Println("orphaned session cleanup disabled:", err)
+		}
+	}
+
+	// Create a connection pool
+	pool, err = newPool(ctx, dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	poolDSN = dsn
+
+	defer pool.Close()
+	defer keepSessionsAlive(ctx, dsn)()
+
+	if len(args) > 0 && args[0] == "seed" {
+		distribution := "dense"
+		if len(args) > 1 {
+			distribution = args[1]
+		}
+
+		switch distribution {
+		case "keys":
+			err = seedKeyTables(ctx, limit)
+		case "blobs":
+			err = seedBlobs(ctx, limit)
+		case "toast":
+			err = seedToast(ctx, limit)
+		case "tenants":
+			err = seedTenants(ctx, limit)
+		case "softdelete":
+			err = seedSoftDelete(ctx, limit)
+		default:
+			if customTable {
+				log.Fatal("seed only creates pgbench_accounts, unset BENCH_TABLE and BENCH_COLUMNS")
+			}
+			err = seed(ctx, distribution, limit)
+		}
+		if err != nil {
+			log.Fatalf("Unable to seed data: %v", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "doctor" {
+		code := runDoctor(ctx)
+		pool.Close()
+		os.Exit(code)
+	}
+
+	if len(args) > 0 && args[0] == "export" {
+		code := runExport(ctx, args[1:])
+		pool.Close()
+		os.Exit(code)
+	}
+
+	if len(args) > 0 && args[0] == "consistency" {
+		if err := checkOverwrite([]string{outputPath("consistency.json")}); err != nil {
+			log.Fatal(err)
+		}
+
+		passes, err := demonstrateConsistency(ctx)
+		if err != nil {
+			log.Fatalf("Unable to run consistency demo: %v", err)
+		}
+		for _, p := range passes {
+			fmt.Printf("offset_limit under %s read %d rows in %d pages during %d writes: %d rows repeated, %d rows missed\n",
+				p.Isolation, p.Rows, p.Pages, p.Writes, len(p.Repeated), len(p.Missed))
+		}
+		if err := writeConsistency(outputPath("consistency.json"), passes); err != nil {
+			log.Fatalf("Unable to save consistency results: %v", err)
+		}
+		fmt.Printf("repeated and missed rows saved to %s\n", outputPath("consistency.json"))
+		return
+	}
+
+	if len(args) > 0 && args[0] == "mix" {
+		spec := os.Getenv("WORKLOAD_MIX")
+		if spec == "" {
+			log.Fatal("mix needs WORKLOAD_MIX, e.g. export=1,reader=50,writer=10/s")
+		}
+		mix, err := parseWorkloadMix(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if d := os.Getenv("MIX_DURATION"); d != "" {
+			mixDuration, err = time.ParseDuration(d)
+			if err != nil {
+				log.Fatalf("Invalid MIX_DURATION: %v", err)
+			}
+		}
+		if err := checkOverwrite([]string{outputPath("mix.json")}); err != nil {
+			log.Fatal(err)
+		}
+
+		report, err := runWorkloadMix(ctx, mix)
+		if err != nil {
+			log.Fatalf("Unable to run workload mix: %v", err)
+		}
+		printMixReport(report)
+		if err := writeMixReport(outputPath("mix.json"), report); err != nil {
+			log.Fatalf("Unable to save workload mix results: %v", err)
+		}
+		fmt.Printf("workload mix results saved to %s\n", outputPath("mix.json"))
+		return
+	}
+
+	handlePauseSignals()
+
+	if keyFile := os.Getenv("KEYS_FILE"); keyFile != "" {
+		lookupKeys, err = readKeys(keyFile)
+		if err != nil {
+			log.Fatalf("Unable to read keys: %v", err)
+		}
+	}
+
+	if len(args) > 0 && args[0] == "sweep" {
+		strategy := "range_parallel"
+		if len(args) > 1 {
+			strategy = args[1]
+		}
+		if err := checkOverwrite([]string{outputPath("sweep.json")}); err != nil {
+			log.Fatal(err)
+		}
+
+		report, err := runSweep(ctx, strategy)
+		if err != nil {
+			log.Fatalf("Unable to run sweep: %v", err)
+		}
+		printSweepReport(report)
+		if err := writeSweepReport(outputPath("sweep.json"), report); err != nil {
+			log.Fatalf("Unable to save sweep results: %v", err)
+		}
+		fmt.Printf("sweep results saved to %s\n", outputPath("sweep.json"))
+		return
+	}
+
+	if len(args) > 0 && args[0] == "rpc" {
+		if streamStrategy != "" || progress.enc != nil {
+			log.Fatal("rpc needs stdout, unset STREAM and PROGRESS_FORMAT")
+		}
+		// Keep stdout for the responses, everything else goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		if err := serveRPC(ctx, os.Stdin, out); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Every I/O variant is run like a target of its own
+	if len(ioVariants) > 0 {
+		if len(targets) == 0 {
+			targets = []Target{{DSN: dsn}}
+		}
+		targets = expandIOVariants(targets, ioVariants)
+	}
+
+	// Every run gets its own directory, smoke tests write nothing to keep
+	if !smokeTest {
+		outputDir, err = createRunDirectory(os.Getenv("RUN_NAME"), time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		runDir = outputDir
+	}
+
+	if metricsAddr != "" {
+		if err := serveMetrics(metricsAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(targets) > 0 {
+		if err := runTargets(ctx, targets); err != nil {
+			log.Fatal(err)
+		}
+	} else if _, err := runBenchmark(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Prune the runs the retention policy no longer keeps, now that this one
+	// is among them
+	if retentionEnabled() && !smokeTest {
+		if err := pruneRuns(outputRoot, false, time.Now()); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// runBenchmark runs the enabled strategies against the pool, reports on their
+// results and saves the manifest of the run. It returns an error when the run
+// can not start, or its manifest or report can not be saved.
+func runBenchmark(ctx context.Context) ([]Result, error) {
+	started := time.Now()
+
+	// Generate SQL for what this server supports, every target may differ
+	var detected *ServerCapabilities
+	capabilities, err := detectCapabilities(ctx)
+	if err != nil {
+		fmt.Println("capability detection disabled:", err)
+	} else {
+		detected = &capabilities
+		if writeCompare && !capabilities.Merge {
+			fmt.Printf("PostgreSQL %s has no MERGE, skipping the merge strategy\n", capabilities.Version)
+		}
+	}
+	server = capabilities
+
+	strategies, err := runStrategies()
+	if err != nil {
+		return nil, err
+	}
+
+	// Protect the results of a previous run before anything is written, a
+	// smoke test writes nothing
+	if !smokeTest {
+		if err := checkOverwrite(runOutputs(strategies)); err != nil {
+			return nil, err
+		}
+	}
+
+	fingerprint, err := fingerprintRun(ctx)
+	if err != nil {
+		fmt.Println("fingerprinting disabled:", err)
+	}
+
+	// Refuse to run on, or adapt to, a table that changed since the last run
+	defer useProjection(projection)
+	schema, err := checkSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := readSettings(ctx)
+	if err != nil {
+		fmt.Println("settings snapshot disabled:", err)
+	}
+
+	checkpoints, err := watchCheckpoints(ctx, time.Second)
+	if err != nil {
+		fmt.Println("checkpoint monitoring disabled:", err)
+	}
+
+	var policy *PolicyPlans
+	if sessionRole != "" {
+		policy, err = comparePolicyPlans(ctx)
+		if err != nil {
+			fmt.Println("policy plan comparison disabled:", err)
+		} else if policy.Changed() {
+			fmt.Printf("role %s pages with %s (%.2fms), the session user with %s (%.2fms)\n",
+				policy.Role, policy.Policy.Nodes(), policy.Policy.ExecutionTime,
+				policy.Session.Nodes(), policy.Session.ExecutionTime)
+			for _, f := range policy.AddedFilters() {
+				fmt.Printf("  policy filter %s\n", f)
+			}
+		} else {
+			fmt.Printf("role %s pages with the same plan as the session user\n", policy.Role)
+		}
+	}
+
+	errorChan := make(chan Result, 1)
+
+	// Soft-delete rows while the soft delete scenario runs
+	var deleter *softDeleter
+	if os.Getenv("SCENARIO") == "softdelete" {
+		deleter, err = startSoftDeleter(ctx)
+		if err != nil {
+			stopCheckpoints(checkpoints)
+			return nil, err
+		}
+	}
+
+	hooks := &hookLog{}
+	if err := hooks.run(ctx, "pre-run", runHooks.PreRun, ""); err != nil {
+		stopCheckpoints(checkpoints)
+		if deleter != nil {
+			deleter.Stop()
+		}
+		return nil, err
+	}
+
+	if quotas.enabled() {
+		quota.Start(ctx, quotas)
+	}
+
+	connUsage.reset()
+
+	wg.Add(len(strategies))
+	if flushTable := os.Getenv("CACHE_FLUSH_TABLE"); flushTable == "" {
+		for _, strategy := range strategies {
+			progress.start(strategy.name)
+			go hooks.wrap(strategy)(withConnOwner(ctx, strategy.name), errorChan)
+		}
+	} else {
+		// Run one strategy at a time, each starting from the same cold cache
+		go func() {
+			for _, strategy := range strategies {
+				if err := flushBuffers(ctx, flushTable); err != nil {
+					fmt.Println(err)
+				}
+				progress.start(strategy.name)
+				hooks.wrap(strategy)(withConnOwner(ctx, strategy.name), errorChan)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errorChan)
+	}()
+
+	var results []Result
+	for result := range errorChan {
+		progress.done(result)
+		if smokeTest {
+			results = append(results, result)
+			continue
+		}
+		if result.Err != nil {
+			fmt.Println(result.Err)
+		} else {
+			if !slices.Contains(sinkNames, "file") || result.Load != nil || result.Write != nil {
+				fmt.Printf("%s done in %s\n", result.Type, human.Seconds(result.Duration))
+			} else if result.Type == streamStrategy {
+				fmt.Printf("%s done in %s, streamed to stdout\n", result.Type, human.Seconds(result.Duration))
+			} else {
+				fmt.Printf("%s done in %s, saved to %s\n", result.Type, human.Seconds(result.Duration), outputPath(result.Type+outputFileExt()))
+			}
+		}
+
+		if len(result.Batches) > 0 {
+			var total QueryTimings
+			for _, b := range result.Batches {
+				total.Prepare += b.Prepare
+				total.Query += b.Query
+				total.Write += b.Write
+			}
+			fmt.Printf("  %s batches spent %s preparing, %s on the server and %s writing rows\n",
+				result.Type, human.Duration(total.Prepare), human.Duration(total.Query-total.Prepare-total.Write), human.Duration(total.Write))
+
+			path := outputPath(result.Type + ".batches.csv")
+			if err := writeBatchTimings(path, result.Batches); err != nil {
+				fmt.Println(err)
+			}
+		}
+
+		result.Outliers = findOutliers(result.Batches, anomalyK)
+		for i, b := range result.Outliers {
+			var note string
+			if checkpoints != nil {
+				if c, ok := checkpoints.near(b); ok {
+					result.Outliers[i].NearCheckpoint = true
+					note = fmt.Sprintf(", checkpoint at %s", c.Time.Format("15:04:05"))
+				}
+			}
+			fmt.Printf("  %s outlier batch #%d (%s) at %s took %s%s\n",
+				result.Type, b.Seq, b.Key, b.Start.Format("15:04:05.000"), human.Duration(b.Duration), note)
+		}
+		if sz := result.RowSizes; sz.Rows > 0 {
+			fmt.Printf("  %s wrote %s rows, %s bytes/row (p50 %s, p95 %s, p99 %s)\n",
+				result.Type, human.Int(int64(sz.Rows)), human.Float(sz.Mean, 1),
+				human.Int(int64(sz.P50)), human.Int(int64(sz.P95)), human.Int(int64(sz.P99)))
+		}
+
+		if w := result.Writes; w.Written {
+			var amplification float64
+			if result.RowSizes.Bytes > 0 {
+				amplification = float64(w.Bytes) / float64(result.RowSizes.Bytes)
+			}
+			fmt.Printf("  %s wrote %s bytes in %s writes (%sx row bytes), %s fsyncs p50 %s max %s\n",
+				result.Type, human.Int(w.Bytes), human.Int(int64(w.Writes)), human.Float(amplification, 2), human.Int(int64(len(w.Syncs))),
+				human.Duration(percentile(w.Syncs, 50)), human.Duration(percentile(w.Syncs, 100)))
+		}
+
+		if c := result.Writes.Compression; c != nil && c.Input > 0 {
+			fmt.Printf("  %s compressed %s bytes to %s with %s (%s%%) in %s, %s MB/s\n",
+				result.Type, human.Int(c.Input), human.Int(result.Writes.Bytes), c.Codec,
+				human.Float(100*float64(result.Writes.Bytes)/float64(c.Input), 1), human.Duration(c.Duration),
+				human.Float(float64(c.Input)/1e6/c.Duration.Seconds(), 1))
+		}
+
+		for _, s := range result.Writes.Sinks {
+			var checksum string
+			if s.Checksum != "" {
+				checksum = ", sha256 " + s.Checksum
+			}
+			fmt.Printf("  %s %s sink took %s for %s bytes%s\n", result.Type, s.Name, human.Duration(s.Duration), human.Int(s.Bytes), checksum)
+		}
+
+		if b := result.Blobs; b != nil && b.Values > 0 {
+			// Values that are all empty have no ratio
+			ratio := ""
+			if b.Bytes > 0 {
+				ratio = fmt.Sprintf(" (%.2fx)", float64(b.Encoded)/float64(b.Bytes))
+			}
+			fmt.Printf("  %s exported %d binary values, %d bytes raw, %d bytes as %s%s, %d bytes to files\n",
+				result.Type, b.Values, b.Bytes, b.Encoded, blobFormat, ratio, b.Files)
+		}
+
+		for _, q := range result.Quarantined {
+			fmt.Printf("  %s quarantined aid %d..%d (%d rows) after %d retries: %s\n",
+				result.Type, q.First, q.Last, q.Rows, q.Retries, q.Err)
+		}
+
+		for _, note := range result.Notes {
+			fmt.Printf("  %s %s\n", result.Type, note)
+		}
+
+		if result.Adaptive != nil && result.Err == nil {
+			fmt.Println(describeAdaptive(result))
+		}
+
+		if result.Concurrency != nil && result.Err == nil {
+			fmt.Println(describeConcurrency(result))
+		}
+
+		if result.Throttled > 0 {
+			fmt.Printf("  %s was held back by the quotas for %s\n", result.Type, human.Duration(result.Throttled))
+		}
+
+		if s := result.Snapshot; s != nil {
+			fmt.Printf("  %s %d workers imported snapshot %s in %s and all read txid snapshot %s\n",
+				result.Type, s.Workers, s.ID, human.Duration(s.Setup), s.Snapshot)
+		}
+
+		if s := result.Skip; s != nil {
+			fmt.Printf("  %s read %d of %d key ranges of %d keys and skipped %d empty ones, the pre-pass took %s\n",
+				result.Type, s.Ranges-s.Skipped, s.Ranges, minmaxChunk, s.Skipped, s.Prepass)
+		}
+
+		if v := result.Writes.Validation; v != nil && v.Rows > 0 {
+			fmt.Printf("  %s exported %d rows that break validation rules: %s\n", result.Type, v.Rows, v.Summary())
+			if writeRejects {
+				fmt.Printf("  rejected rows saved to %s\n", outputPath(result.Type+".rejects.csv"))
+			}
+		}
+
+		if p := result.Pooling; p != nil && p.ValueBytes > 0 {
+			fmt.Printf("  %s pooled %d values into %d unique ones, pages kept %d bytes of values instead of %d (%.0f%% less)\n",
+				result.Type, p.Values, p.Unique, p.PooledValueBytes, p.ValueBytes, 100*(1-float64(p.PooledValueBytes)/float64(p.ValueBytes)))
+		}
+
+		if p := result.Prefetch; p != nil && p.Fetch > 0 {
+			fmt.Printf("  %s overlapped %s of %s fetching with writing (%.0f%%), waited %s for pages, verified %d page checksums\n",
+				result.Type, human.Duration(p.Overlap), human.Duration(p.Fetch), 100*ratio(p.Overlap, p.Fetch), human.Duration(p.Waited), p.Verified)
+		}
+
+		if m, ok := fitCostModel(result.Batches); ok {
+			result.CostModel = &m
+			fmt.Printf("  %s batch latency ~ %s + %s per 10k rows of position (R² %.2f)\n",
+				result.Type, m.Base, m.Per10k, m.R2)
+		}
+
+		if p := result.Plan; p != nil {
+			fmt.Printf("  %s plan %s, %d buffers and %d heap fetches per page\n",
+				result.Type, p.Nodes(), p.Buffers(), p.HeapFetches())
+
+			if r := result.RangePlan; r != nil {
+				fmt.Printf("  %s page reads %d buffers and filters out %.0f rows for %.0f rows, a range page %d buffers and %.0f rows for %.0f rows\n",
+					result.Type, p.Buffers(), p.RowsRemoved(), p.Root.ActualRows,
+					r.Buffers(), r.RowsRemoved(), r.Root.ActualRows)
+			}
+		}
+
+		if c, ok := estimateCapacity(result.Batches, capacityConcurrency, think.mean); ok {
+			result.Capacity = &c
+			fmt.Printf("  %s sustains %s pages/sec (%s rows/sec) at %d concurrent clients, mean page %s, p95 %s\n",
+				result.Type, human.Float(c.PagesPerSec, 0), human.Float(c.RowsPerSec, 0), c.Concurrency,
+				human.Duration(c.MeanLatency), human.Duration(c.P95Latency))
+		}
+
+		results = append(results, result)
 	}
+	quota.Stop()
+	hooks.run(ctx, "post-run", runHooks.PostRun, "")
 
-	dataLimit := os.Getenv("DATA_LIMIT")
-	limit, err = strconv.Atoi(dataLimit)
-	if err != nil {
-		fmt.Println("Error converting string to int:", err)
-		return
+	if deleter != nil {
+		deleted, err := deleter.Stop()
+		if err != nil {
+			fmt.Println(err)
+		}
+		if err := checkSoftDeletes(ctx, deleter.started, results); err != nil {
+			fmt.Println(err)
+		}
+		fmt.Printf("soft-deleted %d rows while the strategies ran\n", deleted)
+		for _, result := range results {
+			if c := result.SoftDelete; c != nil && result.Err == nil {
+				fmt.Printf("  %s exported %d rows, %d of %d rows deleted mid-run, %d repeated, %d live rows missed, %d deleted rows exported\n",
+					result.Type, c.Rows, c.Exported, c.DeletedDuring, c.Repeated, c.Missed, c.Stale)
+			}
+		}
 	}
 
-	dataBatch := os.Getenv("DATA_BATCH_SIZE")
-	batchSize, err = strconv.Atoi(dataBatch)
-	if err != nil {
-		fmt.Println("Error converting string to int:", err)
-		return
+	// Strategies release their last connections after sending their results
+	for i := range results {
+		results[i].Connections = connUsage.of(results[i])
 	}
 
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	port := os.Getenv("DB_PORT")
-	db := os.Getenv("DB_NAME")
+	if smokeTest {
+		stopCheckpoints(checkpoints)
+		return results, reportSmokeTest(results)
+	}
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", user, pass, host, port, db)
+	// Bad row estimates are often why a page picks a pathological plan
+	explainRepresentativePages(ctx, results)
+	for i := range results {
+		if e, ok := worstEstimate(results[i]); ok {
+			results[i].Estimate = &e
+			flag := ""
+			if e.Divergence >= estimateThreshold {
+				flag = ", misestimated"
+			}
+			fmt.Printf("%s worst row estimate: %s%s\n", results[i].Type, describeEstimate(e), flag)
+		}
+	}
 
-	// Create a connection pool
-	config, err := pgxpool.ParseConfig(dsn)
-	if err != nil {
-		log.Fatalf("Unable to parse DSN: %v", err)
+	for _, o := range toastOverheads(results) {
+		fmt.Printf("%s detoasting overhead %.2fs (%.2fs with doc, %.2fs without, %.1fx), %d more bytes exported\n",
+			o.Strategy, (o.With - o.Without).Seconds(), o.With.Seconds(), o.Without.Seconds(),
+			ratio(o.With, o.Without), o.Bytes)
 	}
 
-	ctx := context.Background()
-	pool, err = pgxpool.NewWithConfig(ctx, config)
-	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v", err)
+	if tradeoffs := fractionTradeoffs(results); tradeoffs != nil {
+		fmt.Printf("%-24s %10s %12s %10s\n", "strategy", "fraction", "first fetch", "total")
+		for _, t := range tradeoffs {
+			fmt.Printf("%-24s %10s %12s %9.2fs\n", t.Strategy, t.Fraction, t.FirstFetch.Round(time.Microsecond), t.Total.Seconds())
+		}
 	}
 
-	defer pool.Close()
-	errorChan := make(chan Result, 1)
+	for _, o := range driverOverheads(results) {
+		fmt.Printf("%s driver took %.2fs, %+.2fs (%+.1f%%) over pgx native\n",
+			o.Driver, o.Duration.Seconds(), (o.Duration - o.Native).Seconds(), 100*(ratio(o.Duration, o.Native)-1))
+	}
 
-	wg.Add(4)
-	go fetchWithCursor(ctx, errorChan)
-	go fetchWithOffsetLimit(ctx, errorChan)
-	go fetchWithCustomCursor(ctx, errorChan)
-	go fetchWithCopy(ctx, errorChan)
+	stat := pool.Stat()
+	fmt.Printf("pool of %d max connections opened %d, %s of %s acquires waited for a connection, %s acquiring in total\n",
+		stat.MaxConns(), stat.NewConnsCount(), human.Int(stat.EmptyAcquireCount()), human.Int(stat.AcquireCount()), human.Duration(stat.AcquireDuration()))
+	for _, result := range results {
+		if result.Err == nil && result.Connections != nil {
+			fmt.Printf("  %s\n", describeConnUsage(result))
+		}
+	}
 
-	go func() {
-		wg.Wait()
-		close(errorChan)
-	}()
+	if c, ok := compareSkipScan(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareTenants(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareHoldCursor(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareRefcursor(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareScrollCursor(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareReverseKeyset(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareLogicalDecoding(results); ok {
+		fmt.Println(c)
+	}
+	if c, ok := compareRestAPI(results); ok {
+		fmt.Println(c)
+	}
+	for _, line := range compareLoads(results) {
+		fmt.Println(line)
+	}
+	for _, line := range compareWrites(results) {
+		fmt.Println(line)
+	}
+	for _, line := range compareFormats(results) {
+		fmt.Println(line)
+	}
+	for _, line := range comparePrepared(results) {
+		fmt.Println(line)
+	}
+	for _, line := range compareParallel(results) {
+		fmt.Println(line)
+	}
 
-	for result := range errorChan {
-		if result.Err != nil {
-			fmt.Println(result.Err)
-		} else {
-			fmt.Printf("%s done in %s, saved to output/%s.csv\n", result.Type, result.Message, result.Type)
+	recommendations := recommend(results)
+	if len(recommendations) > 0 {
+		fmt.Println("Recommendations:")
+		for _, r := range recommendations {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+
+	manifest := Manifest{
+		Name:            os.Getenv("RUN_NAME"),
+		Notes:           os.Getenv("RUN_NOTES"),
+		Started:         started,
+		Finished:        time.Now(),
+		Limit:           limit,
+		BatchSize:       batchSize,
+		Recommendations: recommendations,
+		Fingerprint:     fingerprint,
+		Settings:        settings,
+		Schema:          schema,
+		Hooks:           hooks.runs,
+		Capabilities:    detected,
+	}
+	for _, result := range results {
+		manifest.Results = append(manifest.Results, newManifestResult(result))
+	}
+	if err := writeManifest(outputPath("manifest.json"), manifest); err != nil {
+		stopCheckpoints(checkpoints)
+		return results, fmt.Errorf("unable to save manifest: %w", err)
+	}
+	progress.emit(ProgressEvent{Event: "finished"})
+
+	var observed []Checkpoint
+	if checkpoints != nil {
+		observed = checkpoints.Stop()
+		for _, c := range observed {
+			kind := "timed"
+			if c.Requested {
+				kind = "requested"
+			}
+			fmt.Printf("%s checkpoint at %s\n", kind, c.Time.Format("15:04:05"))
+		}
+	}
+
+	if tmpl := os.Getenv("REPORT_TEMPLATE"); tmpl != "" {
+		path, err := renderReport(tmpl, Report{
+			Name:        manifest.Name,
+			Notes:       manifest.Notes,
+			Started:     started,
+			Limit:       limit,
+			BatchSize:   batchSize,
+			Results:     results,
+			Checkpoints: observed,
+
+			Recommendations: recommendations,
+			Policy:          policy,
+		})
+		if err != nil {
+			return results, fmt.Errorf("unable to render report: %w", err)
+		}
+		fmt.Printf("report saved to %s\n", path)
+	}
+
+	return results, nil
+}
+
+// stopCheckpoints stops watching checkpoints when the run ends early.
+func stopCheckpoints(w *checkpointWatcher) {
+	if w != nil {
+		w.Stop()
+	}
+}
+
+// runStrategies selects the strategies of a run from the enabled ones by
+// STRATEGIES, STREAM and the output format, or returns why the run can not
+// start.
+func runStrategies() ([]strategy, error) {
+	var strategies []strategy
+	var err error
+	for _, strategy := range registeredStrategies() {
+		if strategy.enabled {
+			strategies = append(strategies, strategy)
+		}
+	}
+
+	if names := os.Getenv("STRATEGIES"); names != "" {
+		strategies, err = selectStrategies(strategies, strings.Split(names, ","))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if outputFormat != "csv" || exportCSV.crlf {
+		// The server encodes COPY output itself, only as CSV with LF line
+		// endings
+		selected := strategies[:0]
+		for _, strategy := range strategies {
+			if strategy.name != streamStrategy && strategy.usesCopy {
+				fmt.Printf("Skipping %s, COPY only writes csv with LF line endings\n", strategy.name)
+				continue
+			}
+			selected = append(selected, strategy)
+		}
+		strategies = selected
+	}
+
+	if streamStrategy != "" {
+		selected := strategies[:0]
+		for _, strategy := range strategies {
+			if strategy.name == streamStrategy {
+				selected = append(selected, strategy)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("unknown strategy to stream: %s", streamStrategy)
+		}
+		if selected[0].usesCopy && (streamFormat != "csv" || exportCSV.crlf) {
+			return nil, fmt.Errorf("the copy strategy can only stream csv with LF line endings")
 		}
+		strategies = selected
 	}
+	return strategies, nil
 }
 
 func fetchWithCursor(ctx context.Context, res chan<- Result) error {
@@ -95,18 +1372,16 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 		Type: "cursor",
 	}
 
-	// Create or open the CSV file
-	file, err := os.Create(fmt.Sprintf("./output/%s.csv", result.Type))
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
 	if err != nil {
-		err = fmt.Errorf("error creating file: %v", err)
 		result.Err = err
 		res <- result
 		return err
 	}
-	defer file.Close()
+	defer out.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush() // Ensure data is written to file
+	sizes := newRowSizeRecorder()
 
 	// Start a transaction
 	tx, err := pool.Begin(ctx)
@@ -116,17 +1391,10 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 		res <- result
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer func() { tx.Rollback(ctx) }()
 
 	// Declare a cursor for a large query
-	cursorQuery := fmt.Sprintf(`
-        DECLARE my_cursor CURSOR FOR 
-        SELECT aid, bid, abalance
-        FROM pgbench_accounts
-		WHERE aid <= %d
-		ORDER BY aid ASC`, limit)
-
-	_, err = tx.Exec(ctx, cursorQuery)
+	_, err = tx.Exec(ctx, declareCursorQuery("my_cursor", 0))
 	if err != nil {
 		err = fmt.Errorf("failed to declare cursor: %w", err)
 		result.Err = err
@@ -134,10 +1402,20 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 		return err
 	}
 
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	var resumeAfter int
 	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
 		// Fetch the next batch of rows
-		fetchQuery := fmt.Sprintf("FETCH %d FROM my_cursor", batchSize)
-		rows, err := tx.Query(ctx, fetchQuery)
+		rows, err := tx.Query(bctx, fetchCursorQuery("my_cursor"))
 		if err != nil {
 			err = fmt.Errorf("failed to fetch data: %w", err)
 			result.Err = err
@@ -145,44 +1423,30 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 			return err
 		}
 
-		// Check if there are no more rows
-		if !rows.Next() {
-			rows.Close()
-			break
-		}
-
-		header := []string{"aid", "bid", "abalance"}
-		if err := writer.Write(header); err != nil {
-			err = fmt.Errorf("error writing record to CSV: %v", err)
-			result.Err = err
-			res <- result
-			return err
-		}
-
 		// Process each row in the batch
+		var count, firstId, lastId int
 		for rows.Next() {
-			var aid, bid, abalance int
-			// var name, email string
-
-			if err := rows.Scan(&aid, &bid, &abalance); err != nil {
+			record, aid, err := scanRecord(rows)
+			if err != nil {
 				err = fmt.Errorf("failed to scan row: %w", err)
 				result.Err = err
 				res <- result
 				return err
 			}
 
-			record := []string{
-				fmt.Sprintf("%d", aid),
-				fmt.Sprintf("%d", bid),
-				fmt.Sprintf("%d", abalance),
-			}
-
-			if err := writer.Write(record); err != nil {
-				err = fmt.Errorf("error writing record to CSV: %v", err)
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
 				result.Err = err
 				res <- result
 				return err
 			}
+			sizes.Add(n)
+
+			if count == 0 {
+				firstId = aid
+			}
+			lastId = aid
+			count++
 		}
 
 		rows.Close()
@@ -193,6 +1457,59 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 			res <- result
 			return err
 		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+		resumeAfter = lastId
+
+		// Hold still while the run is paused, giving the transaction back
+		// if the pause policy says so
+		if control.Paused() && pausePolicy == "release" {
+			if _, err := tx.Exec(ctx, "CLOSE my_cursor"); err != nil {
+				err = fmt.Errorf("failed to close cursor: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			if err := tx.Commit(ctx); err != nil {
+				err = fmt.Errorf("failed to commit transaction: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			result.Paused += control.Wait(ctx)
+
+			tx, err = pool.Begin(ctx)
+			if err != nil {
+				err = fmt.Errorf("failed to begin transaction: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Continue after the last exported row
+			if _, err := tx.Exec(ctx, declareCursorQuery("my_cursor", resumeAfter)); err != nil {
+				err = fmt.Errorf("failed to declare cursor: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+		} else {
+			result.Paused += control.Wait(ctx)
+		}
 	}
 
 	// Close the cursor explicitly
@@ -207,10 +1524,20 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 	// Commit the transaction
 	commit := tx.Commit(ctx)
 
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
 	// Record the end time
 	end := time.Now()
 	duration := end.Sub(start)
 
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
 	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
 	res <- result
 
@@ -218,28 +1545,39 @@ func fetchWithCursor(ctx context.Context, res chan<- Result) error {
 }
 
 func fetchWithCustomCursor(ctx context.Context, res chan<- Result) error {
+	return fetchCustomCursor(ctx, res, "custom_cursor", pgx.QueryExecModeCacheStatement, resultFormat)
+}
+
+// fetchCustomCursor runs the keyset pages of the custom cursor strategy in
+// the given query exec mode, which decides whether the page statement is
+// prepared once per connection or parsed and planned for every page. A
+// result format forces the format of every column and times the scans,
+// nil leaves the format to pgx.
+func fetchCustomCursor(ctx context.Context, res chan<- Result, name string, mode pgx.QueryExecMode, format pgx.QueryResultFormats) error {
 	defer wg.Done()
 	start := time.Now()
 	result := Result{
-		Type: "custom_cursor",
+		Type: name,
+	}
+	options := []any{mode}
+	if format != nil {
+		options = append(options, format)
+		result.Format = &FormatStats{Format: formatName(format)}
 	}
 
-	// Create or open the CSV file
-	file, err := os.Create(fmt.Sprintf("./output/%s.csv", result.Type))
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
 	if err != nil {
-		err = fmt.Errorf("error creating file: %v", err)
 		result.Err = err
 		res <- result
 		return err
 	}
-	defer file.Close()
+	defer out.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush() // Ensure data is written to file
+	sizes := newRowSizeRecorder()
 
-	header := []string{"aid", "bid", "abalance"}
-	if err := writer.Write(header); err != nil {
-		err = fmt.Errorf("error writing record to CSV: %v", err)
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
 		result.Err = err
 		res <- result
 		return err
@@ -247,99 +1585,151 @@ func fetchWithCustomCursor(ctx context.Context, res chan<- Result) error {
 
 	var lastId int
 	for {
-		// Construct the query with limit and offset
-		query := fmt.Sprintf(`
-			SELECT aid, bid, abalance
-			FROM pgbench_accounts
-			WHERE aid > %d AND aid <= %d
-			ORDER BY aid ASC
-			LIMIT %d`, lastId, limit, batchSize)
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		// Process each row in the batch, retrying a failed page after the
+		// last row it delivered
+		firstId := lastId + 1
+		var count int
+		var quarantined bool
+		for attempt := 0; ; attempt++ {
+			// Execute the query after the last seen key
+			args := append(slices.Clone(options), keysetPageArgs(lastId)...)
+			rows, err := pool.Query(bctx, keysetPageQuery(selectList()), args...)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch data: %w", err)
+			} else {
+				for rows.Next() {
+					record, aid, err := result.Format.scan(rows)
+					if err != nil {
+						rows.Close()
+						err = fmt.Errorf("failed to scan row: %w", err)
+						result.Err = err
+						res <- result
+						return err
+					}
+
+					n, err := timings.WriteRow(out, record)
+					if err != nil {
+						rows.Close()
+						result.Err = err
+						res <- result
+						return err
+					}
+					sizes.Add(n)
+
+					lastId = aid
+					count++
+				}
+
+				rows.Close()
+
+				if rows.Err() != nil {
+					err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+				}
+			}
+			if err == nil {
+				break
+			}
 
-		// Execute the query
-		rows, err := pool.Query(ctx, query)
-		if err != nil {
-			err = fmt.Errorf("failed to fetch data: %w", err)
+			if attempt < batchRetries && ctx.Err() == nil {
+				retryBackoff(ctx, attempt)
+				continue
+			}
+			if batchRetries > 0 && ctx.Err() == nil {
+				q, qerr := quarantinePage(ctx, lastId, attempt, err)
+				if qerr == nil {
+					result.Quarantined = append(result.Quarantined, q)
+					lastId = q.Last
+					quarantined = true
+					break
+				}
+				err = errors.Join(err, qerr)
+			}
 			result.Err = err
 			res <- result
 			return err
 		}
 
+		// Go on after a quarantined range that had no rows before it
+		if count == 0 && quarantined {
+			continue
+		}
+
 		// Check if there are no more rows
-		if !rows.Next() {
-			rows.Close()
+		if count == 0 {
 			break
 		}
 
-		// Process each row in the batch
-		for rows.Next() {
-			var aid, bid, abalance int
-
-			if err := rows.Scan(&aid, &bid, &abalance); err != nil {
-				err = fmt.Errorf("failed to scan row: %w", err)
-				result.Err = err
-				res <- result
-				return err
-			}
-
-			// Prepare the record for CSV
-			record := []string{
-				fmt.Sprintf("%d", aid),
-				fmt.Sprintf("%d", bid),
-				fmt.Sprintf("%d", abalance),
-			}
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
 
-			if err := writer.Write(record); err != nil {
-				err = fmt.Errorf("error writing record to CSV: %v", err)
-				result.Err = err
-				res <- result
-				return err
-			}
+			QueryTimings: *timings,
+		})
 
-			lastId = aid
-		}
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
 
-		rows.Close()
+		// Emulate the client thinking before it asks for the next page
+		result.ThinkTime += think.Sleep(ctx)
+	}
 
-		if rows.Err() != nil {
-			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
-			result.Err = err
-			res <- result
-			return err
-		}
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
 	}
 
 	end := time.Now()
 	duration := end.Sub(start)
 
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
 	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+
+	if indexOnlyCompare {
+		if plan, err := explainKeysetPage(ctx, selectList()); err == nil {
+			result.Plan = plan
+		}
+	}
 	res <- result
 
 	return nil
 }
 
 func fetchWithOffsetLimit(ctx context.Context, res chan<- Result) error {
+	return fetchOffsetLimit(ctx, res, "offset_limit", pgx.QueryExecModeCacheStatement)
+}
+
+// fetchOffsetLimit runs the OFFSET pages of the offset strategy in the given
+// query exec mode, see fetchCustomCursor.
+func fetchOffsetLimit(ctx context.Context, res chan<- Result, name string, mode pgx.QueryExecMode) error {
 	defer wg.Done()
 	start := time.Now()
 	result := Result{
-		Type: "offset_limit",
+		Type: name,
 	}
 
-	// Create or open the CSV file
-	file, err := os.Create(fmt.Sprintf("./output/%s.csv", result.Type))
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
 	if err != nil {
-		err = fmt.Errorf("error creating file: %v", err)
 		result.Err = err
 		res <- result
 		return err
 	}
-	defer file.Close()
+	defer out.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush() // Ensure data is written to file
+	sizes := newRowSizeRecorder()
 
-	header := []string{"aid", "bid", "abalance"}
-	if err := writer.Write(header); err != nil {
-		err = fmt.Errorf("error writing record to CSV: %v", err)
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
 		result.Err = err
 		res <- result
 		return err
@@ -348,16 +1738,12 @@ func fetchWithOffsetLimit(ctx context.Context, res chan<- Result) error {
 	// Set the batch size and initialize the offset
 	offset := 0
 	for {
-		// Construct the query with limit and offset
-		query := fmt.Sprintf(`
-			SELECT aid, bid, abalance
-			FROM pgbench_accounts
-			WHERE aid <= %d
-			ORDER BY aid ASC
-			OFFSET %d LIMIT %d`, limit, offset, batchSize)
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
 
-		// Execute the query
-		rows, err := pool.Query(ctx, query)
+		// Execute the query at the offset
+		args := append([]any{mode}, offsetPageArgs(offset)...)
+		rows, err := pool.Query(bctx, offsetPageQuery(), args...)
 		if err != nil {
 			err = fmt.Errorf("failed to fetch data: %w", err)
 			result.Err = err
@@ -365,36 +1751,26 @@ func fetchWithOffsetLimit(ctx context.Context, res chan<- Result) error {
 			return err
 		}
 
-		// Check if there are no more rows
-		if !rows.Next() {
-			rows.Close()
-			break
-		}
-
 		// Process each row in the batch
+		var count int
 		for rows.Next() {
-			var aid, bid, abalance int
-
-			if err := rows.Scan(&aid, &bid, &abalance); err != nil {
+			record, _, err := scanRecord(rows)
+			if err != nil {
 				err = fmt.Errorf("failed to scan row: %w", err)
 				result.Err = err
 				res <- result
 				return err
 			}
 
-			// Prepare the record for CSV
-			record := []string{
-				fmt.Sprintf("%d", aid),
-				fmt.Sprintf("%d", bid),
-				fmt.Sprintf("%d", abalance),
-			}
-
-			if err := writer.Write(record); err != nil {
-				err = fmt.Errorf("error writing record to CSV: %v", err)
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
 				result.Err = err
 				res <- result
 				return err
 			}
+			sizes.Add(n)
+
+			count++
 		}
 
 		rows.Close()
@@ -406,12 +1782,43 @@ func fetchWithOffsetLimit(ctx context.Context, res chan<- Result) error {
 			return err
 		}
 
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("offset %d", offset),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+
+		// Emulate the client thinking before it asks for the next page
+		result.ThinkTime += think.Sleep(ctx)
+
 		// Update the offset for the next batch
 		offset += batchSize
 	}
 
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
 	end := time.Now()
 	duration := end.Sub(start)
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
 	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
 	res <- result
 
@@ -425,18 +1832,14 @@ func fetchWithCopy(ctx context.Context, res chan<- Result) error {
 		Type: "copy",
 	}
 
-	// Create or open the CSV file
-	file, err := os.Create(fmt.Sprintf("./output/%s.csv", result.Type))
+	// COPY encodes the rows itself, so it writes straight to the destination
+	out, err := openDestination(result.Type)
 	if err != nil {
-		err = fmt.Errorf("error creating file: %v", err)
 		result.Err = err
 		res <- result
 		return err
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush() // Ensure data is written to file
+	defer out.Close()
 
 	conn, err := pool.Acquire(context.Background())
 	if err != nil {
@@ -446,8 +1849,9 @@ func fetchWithCopy(ctx context.Context, res chan<- Result) error {
 	}
 	defer conn.Release()
 
-	command := fmt.Sprintf(`COPY (SELECT aid, bid, abalance FROM pgbench_accounts WHERE aid <= %d ORDER BY aid ASC) TO STDOUT WITH (FORMAT csv, HEADER, DELIMITER ',')`, limit)
-	_, err = conn.Conn().PgConn().CopyTo(ctx, file, command)
+	command := copyCommand()
+	counter := &countingWriter{w: out}
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, counter, command)
 	if err != nil {
 		err = fmt.Errorf("failed to init conn: %w", err)
 		result.Err = err
@@ -455,9 +1859,24 @@ func fetchWithCopy(ctx context.Context, res chan<- Result) error {
 		return err
 	}
 
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
 	end := time.Now()
 	duration := end.Sub(start)
 
+	result.Duration = duration
+	result.Writes = out.Stats()
+
+	// COPY writes whole rows itself, so only the mean size is known
+	result.RowSizes = RowSizes{Rows: int(tag.RowsAffected()), Bytes: counter.n - exportCSV.headerSize(projection)}
+	if result.RowSizes.Rows > 0 {
+		result.RowSizes.Mean = float64(result.RowSizes.Bytes) / float64(result.RowSizes.Rows)
+	}
 	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
 	res <- result
 
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Manifest describes a run and is saved next to its output files.
+type Manifest struct {
+	Name      string           `json:"name,omitempty"`
+	Notes     string           `json:"notes,omitempty"`
+	Started   time.Time        `json:"started"`
+	Finished  time.Time        `json:"finished"`
+	Limit     int              `json:"limit"`
+	BatchSize int              `json:"batch_size"`
+	Results   []ManifestResult `json:"results"`
+
+	Fingerprint *Fingerprint `json:"fingerprint,omitempty"`
+	// Settings is a snapshot of the server settings during the run.
+	Settings map[string]string `json:"settings,omitempty"`
+	// Schema is the definition of the benchmark table, versioned across runs.
+	Schema *TableSchema `json:"schema,omitempty"`
+
+	// Capabilities are the server features detected for the run, which the
+	// generated SQL depends on.
+	Capabilities *ServerCapabilities `json:"capabilities,omitempty"`
+
+	// Hooks are the SQL hooks that ran before and after the run and its
+	// strategies.
+	Hooks []HookRun `json:"hooks,omitempty"`
+
+	Recommendations []string `json:"recommendations,omitempty"`
+}
+
+type ManifestResult struct {
+	Type            string  `json:"type"`
+	Seconds         float64 `json:"seconds"`
+	ThinkSeconds    float64 `json:"think_seconds,omitempty"`
+	PauseSeconds    float64 `json:"pause_seconds,omitempty"`
+	ThrottleSeconds float64 `json:"throttle_seconds,omitempty"`
+	Rows            int     `json:"rows"`
+	BytesPerRow     float64 `json:"bytes_per_row"`
+	BytesWritten    int64   `json:"bytes_written,omitempty"`
+	Fsyncs          int     `json:"fsyncs,omitempty"`
+	Batches         int     `json:"batches"`
+	Outliers        int     `json:"outliers"`
+	Violations      int     `json:"violations,omitempty"`
+	ConnSeconds     float64 `json:"conn_seconds,omitempty"`
+	IdleConnSeconds float64 `json:"idle_conn_seconds,omitempty"`
+	PeakConns       int     `json:"peak_conns,omitempty"`
+	WALBytes        int64   `json:"wal_bytes,omitempty"`
+	GrowthBytes     int64   `json:"growth_bytes,omitempty"`
+	Error           string  `json:"error,omitempty"`
+
+	// UncompressedBytes and CompressSeconds are set for compressed exports,
+	// whose compressed size is BytesWritten.
+	UncompressedBytes int64   `json:"uncompressed_bytes,omitempty"`
+	CompressSeconds   float64 `json:"compress_seconds,omitempty"`
+
+	CostModel   *ManifestCostModel `json:"cost_model,omitempty"`
+	Quarantined []QuarantinedRange `json:"quarantined,omitempty"`
+	RowEstimate *ManifestEstimate  `json:"row_estimate,omitempty"`
+}
+
+// ManifestEstimate is the worst row estimate of a strategy's plans, the
+// divergence being how many times the larger of the rows is over the smaller.
+type ManifestEstimate struct {
+	Node        string  `json:"node"`
+	Relation    string  `json:"relation,omitempty"`
+	PlannedRows float64 `json:"planned_rows"`
+	ActualRows  float64 `json:"actual_rows"`
+	Divergence  float64 `json:"divergence"`
+}
+
+type ManifestCostModel struct {
+	BaseMs   float64 `json:"base_ms"`
+	Per10kMs float64 `json:"per_10k_ms"`
+	R2       float64 `json:"r2"`
+}
+
+func newManifestResult(result Result) ManifestResult {
+	r := ManifestResult{
+		Type:            result.Type,
+		Seconds:         result.Duration.Seconds(),
+		ThinkSeconds:    result.ThinkTime.Seconds(),
+		PauseSeconds:    result.Paused.Seconds(),
+		ThrottleSeconds: result.Throttled.Seconds(),
+		Rows:            result.RowSizes.Rows,
+		BytesPerRow:     result.RowSizes.Mean,
+		BytesWritten:    result.Writes.Bytes,
+		Fsyncs:          len(result.Writes.Syncs),
+		Batches:         len(result.Batches),
+		Outliers:        len(result.Outliers),
+		Quarantined:     result.Quarantined,
+	}
+	if v := result.Writes.Validation; v != nil {
+		r.Violations = v.Rows
+	}
+	if c := result.Writes.Compression; c != nil {
+		r.UncompressedBytes = c.Input
+		r.CompressSeconds = c.Duration.Seconds()
+	}
+	if c := result.Connections; c != nil {
+		r.ConnSeconds = c.Held.Seconds()
+		r.IdleConnSeconds = c.Idle.Seconds()
+		r.PeakConns = c.Peak
+	}
+	if w := result.Write; w != nil {
+		r.WALBytes = w.WAL
+		r.GrowthBytes = w.Growth
+	}
+	if result.Err != nil {
+		r.Error = result.Err.Error()
+	}
+	if m := result.CostModel; m != nil {
+		r.CostModel = &ManifestCostModel{
+			BaseMs:   float64(m.Base) / float64(time.Millisecond),
+			Per10kMs: float64(m.Per10k) / float64(time.Millisecond),
+			R2:       m.R2,
+		}
+	}
+	if e := result.Estimate; e != nil {
+		r.RowEstimate = &ManifestEstimate{
+			Node:        e.Node,
+			Relation:    e.Relation,
+			PlannedRows: e.Planned,
+			ActualRows:  e.Actual,
+			Divergence:  e.Divergence,
+		}
+	}
+	return r
+}
+
+func writeManifest(path string, manifest Manifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
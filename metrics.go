@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metricsAddr is the address the Prometheus metrics are served on. Empty
+// disables them.
+var metricsAddr string
+
+// metricsHashRows is how many rows apart the content hash of a strategy is
+// published, so strategies expose comparable hashes at the same row counts.
+var metricsHashRows int64 = 10000
+
+// strategyMetrics is the live progress of one strategy's output.
+type strategyMetrics struct {
+	rows atomic.Int64
+
+	mu       sync.Mutex
+	hash     uint64
+	hashRows int64
+	batches  batchHistogram
+}
+
+var metrics = struct {
+	sync.Mutex
+	strategies map[string]*strategyMetrics
+}{strategies: make(map[string]*strategyMetrics)}
+
+func strategyMetricsFor(name string) *strategyMetrics {
+	metrics.Lock()
+	defer metrics.Unlock()
+	m, ok := metrics.strategies[name]
+	if !ok {
+		m = &strategyMetrics{}
+		metrics.strategies[name] = m
+	}
+	return m
+}
+
+// serveMetrics serves /metrics on addr in the background. Listening errors are
+// returned, so a busy port fails the run before it starts.
+func serveMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to serve metrics: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", writeMetrics)
+	mux.HandleFunc("GET /traces/{id}", writeTrace)
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// writeMetrics writes the metrics in the Prometheus text format, or in the
+// OpenMetrics format with exemplars when the scraper accepts it.
+func writeMetrics(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+
+	metrics.Lock()
+	names := make([]string, 0, len(metrics.strategies))
+	for name := range metrics.strategies {
+		names = append(names, name)
+	}
+	metrics.Unlock()
+	sort.Strings(names)
+
+	// OpenMetrics names counters without their _total suffix
+	rowsWritten := "bench_rows_written_total"
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		rowsWritten = "bench_rows_written"
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	fmt.Fprintf(w, "# HELP %s Rows a strategy has written so far.\n", rowsWritten)
+	fmt.Fprintf(w, "# TYPE %s counter\n", rowsWritten)
+	for _, name := range names {
+		fmt.Fprintf(w, "bench_rows_written_total{strategy=%q} %d\n", name, strategyMetricsFor(name).rows.Load())
+	}
+
+	hashes := make([]uint64, len(names))
+	hashRows := make([]int64, len(names))
+	for i, name := range names {
+		m := strategyMetricsFor(name)
+		m.mu.Lock()
+		hashes[i], hashRows[i] = m.hash, m.hashRows
+		m.mu.Unlock()
+	}
+	fmt.Fprintln(w, "# HELP bench_content_hash FNV-1a hash of the rows a strategy wrote, as of bench_content_hash_rows rows, truncated to 48 bits.")
+	fmt.Fprintln(w, "# TYPE bench_content_hash gauge")
+	for i, name := range names {
+		fmt.Fprintf(w, "bench_content_hash{strategy=%q} %d\n", name, hashes[i])
+	}
+	fmt.Fprintln(w, "# HELP bench_content_hash_rows Rows covered by bench_content_hash.")
+	fmt.Fprintln(w, "# TYPE bench_content_hash_rows gauge")
+	for i, name := range names {
+		fmt.Fprintf(w, "bench_content_hash_rows{strategy=%q} %d\n", name, hashRows[i])
+	}
+
+	writeBatchHistograms(w, names, openMetrics)
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// metricsDestination counts and hashes the rows of the encoded output on its
+// way to the destination. Rows end at newlines outside of quotes, or at every
+// newline for line delimited output, and the header line is not part of the
+// hash.
+type metricsDestination struct {
+	destination
+	metrics  *strategyMetrics
+	hash     hash.Hash64
+	rows     int64
+	inHeader bool
+	lines    bool
+	quote    byte
+	quoted   bool
+	// records are observed by the sink instead, for binary output.
+	records bool
+}
+
+func newMetricsDestination(dst destination, name string) *metricsDestination {
+	m := strategyMetricsFor(name)
+	m.rows.Store(0)
+	m.mu.Lock()
+	m.hash, m.hashRows = 0, 0
+	m.batches = batchHistogram{}
+	m.mu.Unlock()
+	return &metricsDestination{destination: dst, metrics: m, hash: fnv.New64a(),
+		inHeader: exportCSV.header, quote: exportCSV.quote}
+}
+
+func (d *metricsDestination) Write(p []byte) (int, error) {
+	n, err := d.destination.Write(p)
+	if !d.records {
+		d.observe(p[:n])
+	}
+	return n, err
+}
+
+func (d *metricsDestination) observe(p []byte) {
+	start := 0
+	for i, b := range p {
+		switch {
+		case b == d.quote && !d.lines:
+			d.quoted = !d.quoted
+		case b == '\n' && !d.quoted:
+			if d.inHeader {
+				d.inHeader = false
+				start = i + 1
+				continue
+			}
+			d.hash.Write(p[start : i+1])
+			start = i + 1
+			d.rows++
+			d.metrics.rows.Add(1)
+			if d.rows%metricsHashRows == 0 {
+				d.publish()
+			}
+		}
+	}
+	if !d.inHeader {
+		d.hash.Write(p[start:])
+	}
+}
+
+// recordMetrics counts and hashes the rows of binary output, in which rows
+// can not be told apart, as the CSV lines they would be.
+type recordMetrics struct {
+	m    *metricsDestination
+	line bytes.Buffer
+	csv  *csvWriter
+}
+
+// newRecordMetrics takes the counting over from dst when the metrics are
+// served, and returns nil otherwise.
+func newRecordMetrics(dst destination) *recordMetrics {
+	m, ok := dst.(*metricsDestination)
+	if !ok {
+		return nil
+	}
+	m.inHeader = false
+	m.records = true
+	r := &recordMetrics{m: m}
+	r.csv = newCSVWriter(&r.line, exportCSV)
+	return r
+}
+
+func (r *recordMetrics) observe(record []string) {
+	if r == nil {
+		return
+	}
+	r.line.Reset()
+	r.csv.Write(record)
+	r.csv.Flush()
+	r.m.observe(r.line.Bytes())
+}
+
+func (d *metricsDestination) publish() {
+	d.metrics.mu.Lock()
+	d.metrics.hash = d.hash.Sum64() & (1<<48 - 1)
+	d.metrics.hashRows = d.rows
+	d.metrics.mu.Unlock()
+}
+
+// Finalize publishes the hash of all rows before finalizing the destination.
+func (d *metricsDestination) Finalize() error {
+	d.publish()
+	return d.destination.Finalize()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// minmaxChunk is the width in keys of the chunks the skip scan strategy
+// summarizes. Zero disables it.
+var minmaxChunk int
+
+// SkipStats describes how many of the key ranges of a skip scan were empty.
+type SkipStats struct {
+	// Ranges is the number of chunks between the first and the last key.
+	Ranges  int
+	Skipped int
+	Prepass time.Duration
+}
+
+// keyChunk is the summary of the keys in one chunk.
+type keyChunk struct {
+	First int
+	Last  int
+	Rows  int
+}
+
+// summarizeChunks is the pre-pass of the skip scan. It reads the min and max
+// key of every non-empty chunk from the index, in key order.
+func summarizeChunks(ctx context.Context) ([]keyChunk, error) {
+	rows, err := pool.Query(ctx, minmaxSummaryQuery(minmaxChunk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []keyChunk
+	for rows.Next() {
+		var c keyChunk
+		if err := rows.Scan(&c.First, &c.Last, &c.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error occurred while iterating chunks: %w", rows.Err())
+	}
+	return chunks, nil
+}
+
+// fetchWithMinMaxSkip summarizes the table into chunks of minmaxChunk keys
+// and then reads only the key ranges of the non-empty chunks, each with one
+// range query, so sparse and clustered tables are read without visiting the
+// empty stretches of the key space.
+func fetchWithMinMaxSkip(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "minmax_skip",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	prepassStart := time.Now()
+	chunks, err := summarizeChunks(ctx)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	skip := &SkipStats{Prepass: time.Since(prepassStart)}
+	if len(chunks) > 0 {
+		skip.Ranges = chunks[len(chunks)-1].Last/minmaxChunk - chunks[0].First/minmaxChunk + 1
+		skip.Skipped = skip.Ranges - len(chunks)
+	}
+	result.Skip = skip
+
+	for _, chunk := range chunks {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := pool.Query(bctx, keyRangeQuery(), chunk.First, chunk.Last)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, err := writeAccountRows(bctx, rows, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", chunk.First, chunk.Last),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareSkipScan compares the batches of the skip scan with those of keyset
+// pagination over the same rows, when both ran.
+func compareSkipScan(results []Result) (string, bool) {
+	var skip, keyset *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "minmax_skip":
+			skip = r
+		case r.Type == "custom_cursor":
+			keyset = r
+		}
+	}
+	if skip == nil || keyset == nil {
+		return "", false
+	}
+	return fmt.Sprintf("minmax_skip read %d rows in %d batches (%.2fs), custom_cursor in %d batches (%.2fs)",
+		skip.RowSizes.Rows, len(skip.Batches), skip.Duration.Seconds(),
+		len(keyset.Batches), keyset.Duration.Seconds()), true
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mixDuration is how long the readers and writers of a workload mix run when
+// there is no export to wait for.
+var mixDuration = 30 * time.Second
+
+// MixComponent is one kind of client of a workload mix: export runs Count
+// COPY exports of the table at once, reader runs Count paginated readers and
+// writer updates Count rows per second.
+type MixComponent struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// parseWorkloadMix parses WORKLOAD_MIX, e.g. export=1,reader=50,writer=10/s.
+func parseWorkloadMix(spec string) ([]MixComponent, error) {
+	var mix []MixComponent
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		kind, count, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid workload mix component %q, want kind=count", part)
+		}
+		if kind != "export" && kind != "reader" && kind != "writer" {
+			return nil, fmt.Errorf("unknown workload mix component %q, want export, reader or writer", kind)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("workload mix component %q given twice", kind)
+		}
+		seen[kind] = true
+
+		if kind == "writer" {
+			count = strings.TrimSuffix(count, "/s")
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid count of workload mix component %q: %q", kind, count)
+		}
+		mix = append(mix, MixComponent{Kind: kind, Count: n})
+	}
+	return mix, nil
+}
+
+// MixStats is what one component of a workload mix did in one phase.
+type MixStats struct {
+	Kind     string        `json:"kind"`
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"duration_ns"`
+	// Ops are the completed exports, pages or writes.
+	Ops  int           `json:"ops"`
+	Rows int64         `json:"rows"`
+	P50  time.Duration `json:"p50_ns"`
+	P95  time.Duration `json:"p95_ns"`
+	P99  time.Duration `json:"p99_ns"`
+	Err  string        `json:"error,omitempty"`
+	// Missed are the writes a writer did not issue on schedule because as
+	// many as it issues in a second were still running.
+	Missed int `json:"missed,omitempty"`
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *MixStats) record(latency time.Duration, rows int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, latency)
+	s.Ops++
+	s.Rows += rows
+}
+
+func (s *MixStats) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err == "" {
+		s.Err = err.Error()
+	}
+}
+
+// Rate is the number of operations per second over the phase.
+func (s *MixStats) Rate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Ops) / s.Duration.Seconds()
+}
+
+// MixInterference compares a component running alongside the others with
+// the same component running alone.
+type MixInterference struct {
+	Kind string `json:"kind"`
+	// Latency is the p95 latency in the mix over the p95 latency alone,
+	// above 1 when the mix slows the component down.
+	Latency float64 `json:"p95_ratio"`
+	// Throughput is the rate in the mix over the rate alone, below 1 when
+	// the component falls behind in the mix.
+	Throughput float64 `json:"throughput_ratio"`
+}
+
+// MixReport is the outcome of a workload mix run, written to mix.json.
+type MixReport struct {
+	Mix          []MixComponent    `json:"mix"`
+	Alone        []*MixStats       `json:"alone"`
+	Mixed        []*MixStats       `json:"mixed"`
+	Interference []MixInterference `json:"interference"`
+}
+
+// runWorkloadMix runs every component of the mix alone and then all of them
+// at once, and compares the two. A phase with exports lasts until the exports
+// complete, otherwise it lasts mixDuration.
+func runWorkloadMix(ctx context.Context, mix []MixComponent) (MixReport, error) {
+	report := MixReport{Mix: mix}
+	for _, component := range mix {
+		fmt.Printf("running %s alone\n", describeMixComponent(component))
+		stats := runMixPhase(ctx, []MixComponent{component})
+		report.Alone = append(report.Alone, stats[0])
+	}
+
+	fmt.Println("running the mix")
+	report.Mixed = runMixPhase(ctx, mix)
+
+	for i, mixed := range report.Mixed {
+		alone := report.Alone[i]
+		interference := MixInterference{Kind: mixed.Kind}
+		if alone.P95 > 0 {
+			interference.Latency = float64(mixed.P95) / float64(alone.P95)
+		}
+		if alone.Rate() > 0 {
+			interference.Throughput = mixed.Rate() / alone.Rate()
+		}
+		report.Interference = append(report.Interference, interference)
+	}
+
+	for _, stats := range append(report.Alone, report.Mixed...) {
+		if stats.Err != "" {
+			return report, fmt.Errorf("%s failed: %s", stats.Kind, stats.Err)
+		}
+	}
+	return report, nil
+}
+
+func describeMixComponent(c MixComponent) string {
+	switch c.Kind {
+	case "export":
+		return fmt.Sprintf("%d export(s)", c.Count)
+	case "reader":
+		return fmt.Sprintf("%d reader(s)", c.Count)
+	}
+	return fmt.Sprintf("writer at %d/s", c.Count)
+}
+
+// runMixPhase runs the components at once until their exports complete, or
+// for mixDuration without exports.
+func runMixPhase(ctx context.Context, mix []MixComponent) []*MixStats {
+	pctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var exports, clients sync.WaitGroup
+	hasExport := false
+	stats := make([]*MixStats, len(mix))
+	start := time.Now()
+	for i, component := range mix {
+		s := &MixStats{Kind: component.Kind, Count: component.Count}
+		stats[i] = s
+		switch component.Kind {
+		case "export":
+			hasExport = true
+			for n := 0; n < component.Count; n++ {
+				exports.Add(1)
+				go func() {
+					defer exports.Done()
+					mixExport(pctx, s)
+				}()
+			}
+		case "reader":
+			for n := 0; n < component.Count; n++ {
+				clients.Add(1)
+				go func() {
+					defer clients.Done()
+					mixReader(pctx, s)
+				}()
+			}
+		case "writer":
+			clients.Add(1)
+			go func() {
+				defer clients.Done()
+				mixWriter(pctx, s, component.Count)
+			}()
+		}
+	}
+
+	if hasExport {
+		exports.Wait()
+	} else {
+		select {
+		case <-ctx.Done():
+		case <-time.After(mixDuration):
+		}
+	}
+	stop()
+	clients.Wait()
+
+	duration := time.Since(start)
+	for _, s := range stats {
+		s.Duration = duration
+		s.P50 = percentile(s.latencies, 50)
+		s.P95 = percentile(s.latencies, 95)
+		s.P99 = percentile(s.latencies, 99)
+	}
+	return stats
+}
+
+// mixExport copies the whole table to nowhere, the way a bulk export
+// strategy reads it.
+func mixExport(ctx context.Context, s *MixStats) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		s.fail(fmt.Errorf("failed to acquire connection: %w", err))
+		return
+	}
+	defer conn.Release()
+
+	start := time.Now()
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, io.Discard, copyCommand())
+	if err != nil {
+		s.fail(fmt.Errorf("failed to copy data: %w", err))
+		return
+	}
+	s.record(time.Since(start), tag.RowsAffected())
+}
+
+// mixReader fetches keyset pages from random keys, the way clients of a
+// paginated API do, pausing for THINK_TIME between pages.
+func mixReader(ctx context.Context, s *MixStats) {
+	query := keysetPageQuery(selectList())
+	for ctx.Err() == nil {
+		start := time.Now()
+		rows, err := pool.Query(ctx, query, keysetPageArgs(rand.IntN(limit))...)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.fail(fmt.Errorf("failed to fetch data: %w", err))
+			}
+			return
+		}
+		var n int64
+		for rows.Next() {
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			if ctx.Err() == nil {
+				s.fail(fmt.Errorf("failed to fetch data: %w", err))
+			}
+			return
+		}
+		s.record(time.Since(start), n)
+
+		think.Sleep(ctx)
+	}
+}
+
+// mixWriter updates random rows to their own values at rate rows per second,
+// leaving the data as it was while still creating row versions and WAL. The
+// writes are issued open loop, each on schedule whether the previous ones
+// completed or not, with up to a second's worth of them running at once. A
+// write due while that many are running is counted as missed, and the
+// latency of a write is measured from when it was due.
+func mixWriter(ctx context.Context, s *MixStats, rate int) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := mixUpdateQuery()
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	var writes sync.WaitGroup
+	defer writes.Wait()
+	running := make(chan struct{}, rate)
+	for {
+		var due time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case due = <-ticker.C:
+		}
+
+		select {
+		case running <- struct{}{}:
+		default:
+			s.mu.Lock()
+			s.Missed++
+			s.mu.Unlock()
+			continue
+		}
+		writes.Add(1)
+		go func() {
+			defer writes.Done()
+			defer func() { <-running }()
+
+			tag, err := pool.Exec(ctx, query, rand.IntN(limit)+1)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.fail(fmt.Errorf("failed to update row: %w", err))
+					cancel()
+				}
+				return
+			}
+			s.record(time.Since(due), tag.RowsAffected())
+		}()
+	}
+}
+
+// printMixReport prints every component alone and in the mix with how much
+// the mix slowed it down.
+func printMixReport(report MixReport) {
+	for i, mixed := range report.Mixed {
+		alone := report.Alone[i]
+		for _, phase := range []struct {
+			name  string
+			stats *MixStats
+		}{{"alone", alone}, {"in the mix", mixed}} {
+			fmt.Printf("%s %s: %s ops in %s (%s/s), %s rows, p50 %s, p95 %s, p99 %s\n",
+				describeMixComponent(report.Mix[i]), phase.name, human.Int(int64(phase.stats.Ops)),
+				human.Seconds(phase.stats.Duration), human.Float(phase.stats.Rate(), 1), human.Int(phase.stats.Rows),
+				human.Duration(phase.stats.P50), human.Duration(phase.stats.P95), human.Duration(phase.stats.P99))
+		}
+		if mixed.Kind == "writer" && (alone.Missed > 0 || mixed.Missed > 0) {
+			fmt.Printf("%s missed %s writes alone and %s in the mix, due while %d were still running\n",
+				describeMixComponent(report.Mix[i]), human.Int(int64(alone.Missed)), human.Int(int64(mixed.Missed)), mixed.Count)
+		}
+		interference := report.Interference[i]
+		fmt.Printf("%s in the mix: p95 latency x%s, throughput x%s of running alone\n",
+			mixed.Kind, human.Float(interference.Latency, 2), human.Float(interference.Throughput, 2))
+	}
+}
+
+func writeMixReport(path string, report MixReport) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding workload mix results: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing workload mix results: %w", err)
+	}
+	return nil
+}
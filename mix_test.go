@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseWorkloadMix(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []MixComponent
+		wantErr bool
+	}{
+		{spec: "export=1", want: []MixComponent{{"export", 1}}},
+		{spec: "export=1,reader=50,writer=10/s", want: []MixComponent{{"export", 1}, {"reader", 50}, {"writer", 10}}},
+		{spec: " reader=2 , writer=3", want: []MixComponent{{"reader", 2}, {"writer", 3}}},
+		{spec: "reader", wantErr: true},
+		{spec: "loader=1", wantErr: true},
+		{spec: "reader=1,reader=2", wantErr: true},
+		{spec: "reader=0", wantErr: true},
+		{spec: "reader=-1", wantErr: true},
+		{spec: "reader=many", wantErr: true},
+		{spec: "reader=5/s", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseWorkloadMix(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWorkloadMix(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseWorkloadMix(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sessionsTable records the backends of the connections of every run, so the
+// next run can find the ones a crashed run left behind.
+const sessionsTable = "bench_sessions"
+
+// sessionHeartbeat is how often a run marks its sessions as alive. Sessions
+// whose heartbeat is three times as old belong to a run that is gone.
+const sessionHeartbeat = 10 * time.Second
+
+// partialStaleAge is how long a .partial file has to go unmodified before it
+// is taken to be left by a crashed run rather than being written right now.
+const partialStaleAge = time.Hour
+
+// cleanOrphans enables the startup cleanup of partial files.
+var cleanOrphans = true
+
+// cleanOrphanSessions enables the session bookkeeping, which creates the
+// sessions table, and the termination of orphaned sessions. It is opt-in as
+// it writes to and terminates backends on a server that may be shared.
+var cleanOrphanSessions bool
+
+// runID tells the sessions of this run from those of other runs.
+var runID = fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+
+// sessionBookkeeping is set once the sessions table is known to exist.
+var sessionBookkeeping struct {
+	sync.Mutex
+	enabled bool
+}
+
+// cleanOrphanedSessions terminates the backends that crashed runs left
+// behind, which is where their WITH HOLD cursors and prepared statements
+// live. A backend normally ends with its client, but outlives it behind a
+// pooler in session mode or when the client host died without closing the
+// connection. It connects on its own, before the pool of the run exists.
+// Only backends idle for as long as their heartbeat is stale are terminated,
+// so a live run whose heartbeat was late loses no session it is using.
+func cleanOrphanedSessions(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("unable to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		run_id text NOT NULL,
+		pid int NOT NULL,
+		backend_start timestamptz NOT NULL,
+		heartbeat timestamptz NOT NULL,
+		PRIMARY KEY (run_id, pid)
+	)`, sessionsTable))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", sessionsTable, err)
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(`
+		SELECT s.pid, s.run_id
+		FROM %s s
+		JOIN pg_stat_activity a ON a.pid = s.pid AND a.backend_start = s.backend_start
+		WHERE s.heartbeat < now() - $1::interval
+		AND a.state = 'idle' AND a.state_change < now() - $1::interval`, sessionsTable), (3 * sessionHeartbeat).String())
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned sessions: %w", err)
+	}
+	pids, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (int, error) {
+		var pid int
+		var run string
+		err := row.Scan(&pid, &run)
+		return pid, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned sessions: %w", err)
+	}
+
+	var terminated int
+	for _, pid := range pids {
+		var ok bool
+		if err := conn.QueryRow(ctx, "SELECT pg_terminate_backend($1)", pid).Scan(&ok); err != nil {
+			fmt.Printf("unable to terminate orphaned session %d: %v\n", pid, err)
+			continue
+		}
+		if ok {
+			terminated++
+		}
+	}
+	if terminated > 0 {
+		fmt.Printf("terminated %d orphaned sessions of crashed runs, dropping their cursors and prepared statements\n", terminated)
+	}
+
+	_, err = conn.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE heartbeat < now() - $1::interval", sessionsTable), (3 * sessionHeartbeat).String())
+	if err != nil {
+		return fmt.Errorf("failed to clean %s: %w", sessionsTable, err)
+	}
+
+	sessionBookkeeping.Lock()
+	sessionBookkeeping.enabled = true
+	sessionBookkeeping.Unlock()
+	return nil
+}
+
+// registerSession records the backend of a new connection of the run. The
+// bookkeeping is best effort, a server without the sessions table, such as
+// another target, still gets its connections.
+func registerSession(ctx context.Context, conn *pgx.Conn) {
+	sessionBookkeeping.Lock()
+	enabled := sessionBookkeeping.enabled
+	sessionBookkeeping.Unlock()
+	if !enabled {
+		return
+	}
+
+	conn.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (run_id, pid, backend_start, heartbeat)
+		SELECT $1, pid, backend_start, now() FROM pg_stat_activity WHERE pid = pg_backend_pid()
+		ON CONFLICT (run_id, pid) DO UPDATE SET backend_start = excluded.backend_start, heartbeat = now()`, sessionsTable), runID)
+}
+
+// keepSessionsAlive updates the heartbeat of the sessions of the run until
+// ctx is done, and forgets them when the returned function is called. The
+// heartbeat has a connection of its own, one from the pool could wait behind
+// strategies holding every connection until the heartbeat looks stale.
+func keepSessionsAlive(ctx context.Context, dsn string) func() {
+	sessionBookkeeping.Lock()
+	enabled := sessionBookkeeping.enabled
+	sessionBookkeeping.Unlock()
+	if !enabled {
+		return func() {}
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		fmt.Println("session heartbeat disabled:", err)
+		return func() {}
+	}
+
+	hctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(sessionHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hctx.Done():
+				return
+			case <-ticker.C:
+			}
+			conn.Exec(hctx, fmt.Sprintf("UPDATE %s SET heartbeat = now() WHERE run_id = $1", sessionsTable), runID)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		conn.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE run_id = $1", sessionsTable), runID)
+		conn.Close(ctx)
+	}
+}
+
+// cleanPartialFiles removes the .partial files under dir that crashed runs
+// left behind. Files modified within partialStaleAge may still be written by
+// a concurrent run and are kept.
+func cleanPartialFiles(dir string) error {
+	var removed int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), partialSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < partialStaleAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clean partial files: %w", err)
+	}
+	if removed > 0 {
+		fmt.Printf("removed %d partial files of crashed runs from %s\n", removed, dir)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const partialSuffix = ".partial"
+
+// syncBytes makes output files fsync every time this many bytes have been
+// written since the last sync. Zero only syncs once on finalize.
+var syncBytes int64
+
+// outputDir is the directory the files of a run are written to. It starts out
+// as OUTPUT_DIR and becomes the run's own directory once the run starts.
+var outputDir = "./output"
+
+// outputRoot is OUTPUT_DIR, which the run directories are created in.
+var outputRoot = "./output"
+
+// latestLink names the link to the directory of the most recent run.
+const latestLink = "latest"
+
+// createRunDirectory creates the directory of a run under the output
+// directory, named after the run or else its start time, and points the
+// latest link at it.
+func createRunDirectory(name string, started time.Time) (string, error) {
+	if name == "" {
+		name = started.Format("20060102-150405")
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." || name == latestLink {
+		return "", fmt.Errorf("RUN_NAME %q cannot name a directory", name)
+	}
+
+	dir := outputPath(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating run directory: %v", err)
+	}
+
+	// The link is a convenience, platforms without symlinks go without it
+	link := outputPath(latestLink)
+	if previous, err := os.Readlink(link); err == nil {
+		previousRunDir = outputPath(previous)
+	}
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		os.Remove(link)
+	}
+	if err := os.Symlink(name, link); err != nil && !os.IsExist(err) {
+		fmt.Println("not linking the latest run:", err)
+	}
+	return dir, nil
+}
+
+// outputPath returns the path of a file in the output directory.
+func outputPath(name string) string {
+	return filepath.Join(outputDir, name)
+}
+
+// forceOverwrite allows a run to replace the output files of a previous run.
+var forceOverwrite bool
+
+// checkOverwrite returns an error naming the paths that already hold a non
+// empty file, unless overwriting was forced.
+func checkOverwrite(paths []string) error {
+	if forceOverwrite {
+		return nil
+	}
+
+	var existing []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("error checking output: %v", err)
+		}
+		if info.Size() > 0 {
+			existing = append(existing, path)
+		}
+	}
+
+	if len(existing) > 0 {
+		return fmt.Errorf("refusing to overwrite the output of a previous run, set FORCE=true to replace it: %s", strings.Join(existing, ", "))
+	}
+	return nil
+}
+
+// runOutputs lists the files a run of the strategies writes, which
+// checkOverwrite protects.
+func runOutputs(strategies []strategy) []string {
+	outputs := []string{outputPath("manifest.json")}
+	for _, strategy := range strategies {
+		if streamStrategy == "" && slices.Contains(sinkNames, "file") {
+			outputs = append(outputs, outputPath(strategy.name+outputFileExt()))
+			if parallelShards {
+				for worker := 0; worker < strategy.workers; worker++ {
+					outputs = append(outputs, outputPath(fmt.Sprintf("%s_shard%d%s", strategy.name, worker, outputFileExt())))
+				}
+			}
+		}
+		if slices.Contains(sinkNames, "checksum") {
+			outputs = append(outputs, outputPath(strategy.name+outputFileExt()+".sha256"))
+		}
+		outputs = append(outputs, outputPath(strategy.name+".batches.csv"))
+	}
+	return outputs
+}
+
+// WriteStats describes how a destination was written to storage.
+type WriteStats struct {
+	Bytes   int64
+	Writes  int
+	Syncs   []time.Duration
+	Written bool
+	// Sinks times every sink when the output goes to more than one.
+	Sinks []SinkTiming
+	// Validation counts the rows that broke VALIDATION_RULES.
+	Validation *ValidationStats
+	// Compression is set when OUTPUT_COMPRESSION compressed the file.
+	Compression *CompressionStats
+}
+
+// outputFile is written under a .partial name and only renamed to its final
+// name once the strategy has completed, so a failed strategy never leaves a
+// file that looks like a finished export.
+type outputFile struct {
+	*os.File
+	path      string
+	finalized bool
+
+	stats    WriteStats
+	unsynced int64
+
+	// compressor compresses the writes into the file, and fileTime is the
+	// time spent writing compressed bytes to the file.
+	compressor io.WriteCloser
+	fileTime   time.Duration
+}
+
+// outputExt is the file extension of the exports in OUTPUT_FORMAT.
+func outputExt() string {
+	switch outputFormat {
+	case "jsonl":
+		return ".jsonl"
+	case "parquet":
+		return ".parquet"
+	case "arrow":
+		if arrowIPC == "stream" {
+			return ".arrows"
+		}
+		return ".arrow"
+	}
+	return ".csv"
+}
+
+// outputFileExt is the file extension of the exports on disk, including the
+// extension of OUTPUT_COMPRESSION.
+func outputFileExt() string {
+	return outputExt() + compressionExt()
+}
+
+func createOutput(name string) (*outputFile, error) {
+	path := outputPath(name + outputFileExt())
+
+	// Drop the export of a previous run so it is not mistaken for this one
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error removing previous output: %v", err)
+	}
+
+	file, err := os.Create(path + partialSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &outputFile{File: file, path: path, stats: WriteStats{Written: true}}
+	f.compressor, err = newCompressor(writerFunc(f.writeFile))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if f.compressor != nil {
+		f.stats.Compression = &CompressionStats{Codec: outputCompression}
+	}
+	return f, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.compressor == nil {
+		return f.writeFile(p)
+	}
+
+	start, fileTime := time.Now(), f.fileTime
+	n, err := f.compressor.Write(p)
+	f.stats.Compression.Input += int64(n)
+	f.stats.Compression.Duration += time.Since(start) - (f.fileTime - fileTime)
+	return n, err
+}
+
+// writeFile writes to the file, syncing it every FSYNC_BYTES.
+func (f *outputFile) writeFile(p []byte) (int, error) {
+	start := time.Now()
+	defer func() { f.fileTime += time.Since(start) }()
+
+	n, err := f.File.Write(p)
+	f.stats.Bytes += int64(n)
+	f.stats.Writes++
+	f.unsynced += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	if syncBytes > 0 && f.unsynced >= syncBytes {
+		if err := f.sync(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (f *outputFile) sync() error {
+	start := time.Now()
+	if err := f.File.Sync(); err != nil {
+		return fmt.Errorf("error syncing file: %v", err)
+	}
+	f.stats.Syncs = append(f.stats.Syncs, time.Since(start))
+	f.unsynced = 0
+	return nil
+}
+
+func (f *outputFile) Stats() WriteStats {
+	return f.stats
+}
+
+// Finalize flushes the compressor, syncs and closes the file and moves it to
+// its final name.
+func (f *outputFile) Finalize() error {
+	if f.compressor != nil {
+		start, fileTime := time.Now(), f.fileTime
+		if err := f.compressor.Close(); err != nil {
+			return fmt.Errorf("error compressing file: %v", err)
+		}
+		f.stats.Compression.Duration += time.Since(start) - (f.fileTime - fileTime)
+	}
+	if err := f.sync(); err != nil {
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return fmt.Errorf("error closing file: %v", err)
+	}
+	f.finalized = true
+
+	if err := os.Rename(f.File.Name(), f.path); err != nil {
+		return fmt.Errorf("error renaming file: %v", err)
+	}
+	return nil
+}
+
+// Close closes a file that was not finalized, leaving its .partial suffix.
+func (f *outputFile) Close() error {
+	if f.finalized {
+		return nil
+	}
+	if f.compressor != nil {
+		f.compressor.Close()
+	}
+	return f.File.Close()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
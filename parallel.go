@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// parallelWorkers is the number of concurrent workers of the parallel
+// strategies. Zero disables them.
+var parallelWorkers int
+
+// parallelShards makes every worker of a parallel strategy write its own
+// output file instead of sharing one.
+var parallelShards bool
+
+// parallelExport is the state the workers of a parallel strategy share. Each
+// worker writes a whole page at a time while holding the lock, so rows of a
+// page stay together in the output.
+type parallelExport struct {
+	mu     sync.Mutex
+	out    rowSink
+	sizes  *rowSizeRecorder
+	result *Result
+
+	pooling PoolStats
+
+	// shards are the outputs of the workers with PARALLEL_SHARDS, which
+	// they write without the lock.
+	shards     []rowSink
+	shardSizes []*rowSizeRecorder
+}
+
+// openParallelExport opens the shared output of a parallel strategy, or one
+// output for each of its workers named <strategy>_shard<worker> with
+// PARALLEL_SHARDS.
+func openParallelExport(result *Result, workers int) (*parallelExport, error) {
+	export := &parallelExport{sizes: newRowSizeRecorder(), result: result}
+	if !parallelShards {
+		out, err := openSink(result.Type)
+		if err != nil {
+			return nil, err
+		}
+		export.out = out
+		if err := out.WriteHeader(projection); err != nil {
+			out.Close()
+			return nil, err
+		}
+		return export, nil
+	}
+
+	for worker := 0; worker < workers; worker++ {
+		out, err := openSink(fmt.Sprintf("%s_shard%d", result.Type, worker))
+		if err != nil {
+			export.Close()
+			return nil, err
+		}
+		export.shards = append(export.shards, out)
+		export.shardSizes = append(export.shardSizes, newRowSizeRecorder())
+		if err := out.WriteHeader(projection); err != nil {
+			export.Close()
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// writePage writes the rows of one page and records it as a batch.
+func (p *parallelExport) writePage(ctx context.Context, worker int, rows []pageRow, timings *QueryTimings, batch Batch) error {
+	out, sizes := p.out, p.sizes
+	if p.shards != nil {
+		out, sizes = p.shards[worker], p.shardSizes[worker]
+	} else {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+	}
+
+	for _, row := range rows {
+		n, err := timings.WriteRow(out, row.record)
+		if err != nil {
+			return err
+		}
+		sizes.Add(n)
+	}
+
+	if p.shards != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+	}
+	batch.Seq = len(p.result.Batches) + 1
+	batch.Duration = time.Since(batch.Start)
+	batch.QueryTimings = *timings
+	p.result.addBatch(ctx, batch)
+	return nil
+}
+
+// Finalize moves every finished output into place.
+func (p *parallelExport) Finalize() error {
+	if p.shards == nil {
+		return p.out.Finalize()
+	}
+	for _, out := range p.shards {
+		if err := out.Finalize(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parallelExport) Close() error {
+	if p.shards == nil {
+		return p.out.Close()
+	}
+	for _, out := range p.shards {
+		out.Close()
+	}
+	return nil
+}
+
+// summarize sets the row sizes and write statistics of the result from every
+// output of the export.
+func (p *parallelExport) summarize(result *Result) {
+	if p.shards == nil {
+		result.RowSizes = p.sizes.Summary()
+		result.Writes = p.out.Stats()
+		return
+	}
+
+	for i, out := range p.shards {
+		p.sizes.merge(p.shardSizes[i])
+		stats := out.Stats()
+		result.Writes.Bytes += stats.Bytes
+		result.Writes.Writes += stats.Writes
+		result.Writes.Syncs = append(result.Writes.Syncs, stats.Syncs...)
+		result.Writes.Written = result.Writes.Written || stats.Written
+	}
+	result.RowSizes = p.sizes.Summary()
+}
+
+// pageRow is a row of a page read into memory, as its record and key.
+type pageRow struct {
+	record []string
+	key    int
+}
+
+// querier runs queries on the pool, a connection or a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// readPage runs a page query on q and reads its rows into memory, pooling
+// their values with VALUE_POOLING.
+func readPage(ctx context.Context, q querier, query string, args ...any) ([]pageRow, PoolStats, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, PoolStats{}, fmt.Errorf("failed to fetch data: %w", err)
+	}
+	defer rows.Close()
+
+	var values *valuePool
+	if valuePooling {
+		values = newValuePool()
+	}
+
+	var page []pageRow
+	for rows.Next() {
+		record, key, err := scanRecord(rows)
+		if err != nil {
+			return nil, PoolStats{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if values != nil {
+			record = values.add(record)
+		}
+		page = append(page, pageRow{record: record, key: key})
+	}
+	if rows.Err() != nil {
+		return nil, PoolStats{}, fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+	}
+	if values != nil {
+		return page, values.stats, nil
+	}
+	return page, PoolStats{}, nil
+}
+
+// partitionWorker describes how a worker of a parallel strategy pages
+// through its share of the rows.
+type partitionWorker struct {
+	// after is the key the worker's first page starts after.
+	after int
+	// pageQuery returns the page after the given key and its arguments.
+	pageQuery func(lastId int) (string, []any)
+	label     string
+	// conn runs the worker's queries, the pool when nil.
+	conn querier
+}
+
+// runPartitions runs the workers until each has read its share or one of
+// them failed, and returns the first error.
+func runPartitions(ctx context.Context, export *parallelExport, workers []partitionWorker) error {
+	var wait sync.WaitGroup
+	errs := make(chan error, len(workers))
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for i, w := range workers {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			var conn querier = pool
+			if w.conn != nil {
+				conn = w.conn
+			}
+
+			lastId := w.after
+			for wctx.Err() == nil {
+				batchStart := time.Now()
+				bctx, timings := traceQueries(wctx)
+
+				query, args := w.pageQuery(lastId)
+				page, pooled, err := readPage(bctx, conn, query, args...)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+
+				// Check if there are no more rows
+				if len(page) == 0 {
+					return
+				}
+
+				firstId := page[0].key
+				lastId = page[len(page)-1].key
+				err = export.writePage(ctx, i, page, timings, Batch{
+					Start: batchStart,
+					Key:   fmt.Sprintf("%s %s %d..%d", w.label, keyColumn, firstId, lastId),
+					Rows:  len(page),
+				})
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+
+				// Hold still while the run is paused
+				paused := control.Wait(ctx)
+				export.mu.Lock()
+				export.result.Paused += paused
+				export.pooling.add(pooled)
+				export.mu.Unlock()
+			}
+		}()
+	}
+
+	wait.Wait()
+	close(errs)
+	return <-errs
+}
+
+// fetchWithHashParallel splits the rows between parallelWorkers workers by
+// aid modulo the worker count, which needs no knowledge of the key range.
+// Every worker pages through its share with keyset pagination.
+func fetchWithHashParallel(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "hash_parallel",
+	}
+
+	// Open the sink the rows are written to
+	export, err := openParallelExport(&result, parallelWorkers)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer export.Close()
+
+	// A hash page walks the index of every worker's rows, a range page only
+	// its own, which the plans of one page of each show
+	plan, err := explain(ctx, hashPageQuery(parallelWorkers), 0, limit/2)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.Plan = plan
+	result.RangePlan, err = explain(ctx, keysetPageQuery(selectList()), keysetPageArgs(limit/2)...)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	workers := make([]partitionWorker, parallelWorkers)
+	for worker := range workers {
+		workers[worker] = partitionWorker{
+			pageQuery: func(lastId int) (string, []any) { return hashPageQuery(parallelWorkers), []any{worker, lastId} },
+			label:     fmt.Sprintf("worker %d", worker),
+		}
+	}
+	if err := runPartitions(ctx, export, workers); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	return finishParallel(export, start, result, res)
+}
+
+// fetchWithRangeParallel splits the key range into parallelWorkers
+// partitions of equal width and lets a worker keyset paginate through each.
+// Unlike hash partitioning every page only walks its own part of the index,
+// but keys that are not evenly spread give the workers unequal shares.
+func fetchWithRangeParallel(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "range_parallel",
+	}
+
+	// Open the sink the rows are written to
+	export, err := openParallelExport(&result, parallelWorkers)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer export.Close()
+
+	// Split the keys that are there, not the keys that could be
+	var first, last *int
+	if err := pool.QueryRow(ctx, keyBoundsQuery()).Scan(&first, &last); err != nil {
+		err = fmt.Errorf("failed to read key range: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	if first != nil {
+		width := (*last-*first)/parallelWorkers + 1
+		var workers []partitionWorker
+		for worker := 0; worker < parallelWorkers; worker++ {
+			after := *first - 1 + worker*width
+			end := min(after+width, *last)
+			if after >= end {
+				break
+			}
+			workers = append(workers, partitionWorker{
+				after:     after,
+				pageQuery: func(lastId int) (string, []any) { return rangePageQuery(), []any{lastId, end} },
+				label:     fmt.Sprintf("worker %d", worker),
+			})
+		}
+		if err := runPartitions(ctx, export, workers); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+	}
+
+	return finishParallel(export, start, result, res)
+}
+
+// finishParallel finalizes the outputs of a parallel strategy and sends its
+// result.
+func finishParallel(export *parallelExport, start time.Time, result Result, res chan<- Result) error {
+	// Move the finished file into place
+	if err := export.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	export.summarize(&result)
+	if valuePooling {
+		result.Pooling = &export.pooling
+	}
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareParallel compares the parallel strategies with the single keyset
+// loop of custom_cursor.
+func compareParallel(results []Result) []string {
+	var keyset *Result
+	for i := range results {
+		if results[i].Type == "custom_cursor" && results[i].Err == nil {
+			keyset = &results[i]
+		}
+	}
+	if keyset == nil || keyset.Duration == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, r := range results {
+		if r.Err != nil || (r.Type != "hash_parallel" && r.Type != "range_parallel" && r.Type != "snapshot_parallel") || r.Duration == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s with %d workers took %.2fs, %.1fx the speed of custom_cursor (%.2fs)",
+			r.Type, parallelWorkers, r.Duration.Seconds(), keyset.Duration.Seconds()/r.Duration.Seconds(), keyset.Duration.Seconds()))
+	}
+	return lines
+}
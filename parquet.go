@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// parquetRowGroupRows is the number of rows of a Parquet row group, which
+// the writer buffers in memory before it writes the group out.
+var parquetRowGroupRows int64 = 100000
+
+// parquetCompression is the codec of the Parquet pages.
+var parquetCompression compress.Codec = &parquet.Snappy
+
+var parquetCodecs = map[string]compress.Codec{
+	"none":   &parquet.Uncompressed,
+	"snappy": &parquet.Snappy,
+	"gzip":   &parquet.Gzip,
+	"zstd":   &parquet.Zstd,
+	"lz4":    &parquet.Lz4Raw,
+	"brotli": &parquet.Brotli,
+}
+
+// loadParquet reads the PARQUET_* variables.
+func loadParquet() error {
+	if r := os.Getenv("PARQUET_ROW_GROUP_ROWS"); r != "" {
+		rows, err := strconv.ParseInt(r, 10, 64)
+		if err != nil || rows <= 0 {
+			return fmt.Errorf("PARQUET_ROW_GROUP_ROWS must be a positive number: %s", r)
+		}
+		parquetRowGroupRows = rows
+	}
+	if c := os.Getenv("PARQUET_COMPRESSION"); c != "" {
+		codec, ok := parquetCodecs[c]
+		if !ok {
+			return fmt.Errorf("PARQUET_COMPRESSION must be none, snappy, gzip, zstd, lz4 or brotli, got %s", c)
+		}
+		parquetCompression = codec
+	}
+	return nil
+}
+
+// parquetSink writes the rows to a Parquet file with one string column per
+// header column. Values are kept as the strings the CSV would hold.
+type parquetSink struct {
+	dst destination
+	w   *parquet.Writer
+	// columns are the leaf column indexes of the header columns, which the
+	// schema orders by name.
+	columns []int
+	row     parquet.Row
+	metrics *recordMetrics
+}
+
+func newParquetSink(dst destination) *parquetSink {
+	return &parquetSink{dst: dst, metrics: newRecordMetrics(dst)}
+}
+
+func (s *parquetSink) WriteHeader(columns []string) error {
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column] = parquet.String()
+	}
+	schema := parquet.NewSchema(benchTable, group)
+
+	s.columns = make([]int, len(columns))
+	for i, column := range columns {
+		leaf, ok := schema.Lookup(column)
+		if !ok {
+			return fmt.Errorf("error creating Parquet schema: no column %s", column)
+		}
+		s.columns[i] = leaf.ColumnIndex
+	}
+	s.w = parquet.NewWriter(s.dst, schema,
+		parquet.Compression(parquetCompression),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupRows))
+	return nil
+}
+
+func (s *parquetSink) WriteRow(record []string) (int, error) {
+	// The writer takes the values of a row in the order of the schema
+	if len(s.row) != len(record) {
+		s.row = make(parquet.Row, len(record))
+	}
+	row := s.row
+	size := 0
+	for i, value := range record {
+		row[s.columns[i]] = parquet.ByteArrayValue([]byte(value)).Level(0, 0, s.columns[i])
+		size += len(value)
+	}
+
+	if _, err := s.w.WriteRows([]parquet.Row{row}); err != nil {
+		return 0, fmt.Errorf("error writing record to Parquet: %v", err)
+	}
+
+	s.metrics.observe(record)
+	return size, nil
+}
+
+func (s *parquetSink) Finalize() error {
+	if s.w == nil {
+		if err := s.WriteHeader(nil); err != nil {
+			return err
+		}
+	}
+	if err := s.w.Close(); err != nil {
+		return fmt.Errorf("error writing record to Parquet: %v", err)
+	}
+	return s.dst.Finalize()
+}
+
+func (s *parquetSink) Close() error {
+	return s.dst.Close()
+}
+
+func (s *parquetSink) Stats() WriteStats {
+	return s.dst.Stats()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Strategy is a strategy kept in a file of its own and added with Register
+// from an init function, without changes to registeredStrategies or main.
+// The run opens and finalizes its sink, times it and reports on it; the
+// strategy only reads the rows and writes them to the sink.
+//
+// A Strategy may also have an Enabled() bool method, to only run with some
+// configurations, and a Doc() strategyDoc method for `explain`.
+type Strategy interface {
+	Name() string
+	Run(ctx context.Context, config Config, sink Sink) (Metrics, error)
+}
+
+// Config is what a Strategy gets to know of the run.
+type Config struct {
+	Pool *pgxpool.Pool
+	// Table and Key are quoted for SQL. Columns are the exported columns,
+	// which the sink has written the header of.
+	Table   string
+	Key     string
+	Columns []string
+
+	Limit     int
+	BatchSize int
+
+	// Batch records a finished batch for the reports, numbering it, and
+	// then holds the strategy while the run is paused or thinking.
+	Batch func(Batch)
+}
+
+// Sink takes the rows of a Strategy as CSV records of the columns, see
+// scanRecord, and returns their encoded size.
+type Sink interface {
+	WriteRow(record []string) (int, error)
+}
+
+// Metrics are what a Strategy measured beyond its batches.
+type Metrics struct {
+	// Notes are printed with the results of the strategy, one per line.
+	Notes []string
+}
+
+// plugins are the strategies added with Register, in order.
+var plugins []Strategy
+
+// Register adds a strategy to the registry. Names must be unique, also
+// against the built in strategies.
+func Register(s Strategy) {
+	if slices.ContainsFunc(builtinStrategies(), func(b strategy) bool { return b.name == s.Name() }) {
+		panic(fmt.Sprintf("strategy %s is a built in strategy", s.Name()))
+	}
+	for _, p := range plugins {
+		if p.Name() == s.Name() {
+			panic(fmt.Sprintf("strategy %s registered twice", s.Name()))
+		}
+	}
+	plugins = append(plugins, s)
+}
+
+// pluginStrategy adapts a registered Strategy to the strategies of a run.
+func pluginStrategy(p Strategy) strategy {
+	s := strategy{
+		name:    p.Name(),
+		run:     runPlugin(p),
+		enabled: true,
+		doc: strategyDoc{
+			Summary: "Registered with Register.",
+			SQL:     func() []string { return nil },
+		},
+	}
+	if e, ok := p.(interface{ Enabled() bool }); ok {
+		s.enabled = e.Enabled()
+	}
+	if d, ok := p.(interface{ Doc() strategyDoc }); ok {
+		s.doc = d.Doc()
+	}
+	return s
+}
+
+// recordingSink records the size of every row a Strategy writes.
+type recordingSink struct {
+	out   rowSink
+	sizes *rowSizeRecorder
+}
+
+func (s recordingSink) WriteRow(record []string) (int, error) {
+	n, err := s.out.WriteRow(record)
+	if err == nil {
+		s.sizes.Add(n)
+	}
+	return n, err
+}
+
+// runPlugin runs a Strategy like the built in strategies run themselves.
+func runPlugin(p Strategy) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: p.Name(),
+		}
+
+		// Open the sink the rows are written to
+		out, err := openSink(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		sizes := newRowSizeRecorder()
+
+		if err := out.WriteHeader(projection); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		config := Config{
+			Pool:      pool,
+			Table:     tableName(),
+			Key:       keyName(),
+			Columns:   projection,
+			Limit:     limit,
+			BatchSize: batchSize,
+			Batch: func(b Batch) {
+				b.Seq = len(result.Batches) + 1
+				result.addBatch(ctx, b)
+				result.Paused += control.Wait(ctx)
+				result.ThinkTime += think.Sleep(ctx)
+			},
+		}
+		metrics, err := p.Run(ctx, config, recordingSink{out: out, sizes: sizes})
+		if err != nil {
+			result.Err = fmt.Errorf("%s: %w", p.Name(), err)
+			res <- result
+			return err
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.RowSizes = sizes.Summary()
+		result.Writes = out.Stats()
+		result.Notes = metrics.Notes
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolSettings override the pgxpool defaults, so the pool configuration can be
+// varied between runs like any other setting. Zero values keep the defaults.
+var poolSettings struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+}
+
+// loadPoolSettings reads the POOL_* variables.
+func loadPoolSettings() error {
+	counts := []struct {
+		env   string
+		value *int32
+	}{
+		{"POOL_MAX_CONNS", &poolSettings.MaxConns},
+		{"POOL_MIN_CONNS", &poolSettings.MinConns},
+	}
+	for _, c := range counts {
+		if v := os.Getenv(c.env); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s must be a non-negative number: %s", c.env, v)
+			}
+			*c.value = int32(n)
+		}
+	}
+
+	durations := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"POOL_MAX_CONN_LIFETIME", &poolSettings.MaxConnLifetime},
+		{"POOL_MAX_CONN_IDLE_TIME", &poolSettings.MaxConnIdleTime},
+		{"POOL_HEALTH_CHECK_PERIOD", &poolSettings.HealthCheckPeriod},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.env); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("error parsing %s: %v", d.env, err)
+			}
+			*d.value = parsed
+		}
+	}
+
+	if poolSettings.MaxConns > 0 && poolSettings.MinConns > poolSettings.MaxConns {
+		return fmt.Errorf("POOL_MIN_CONNS (%d) is more than POOL_MAX_CONNS (%d)", poolSettings.MinConns, poolSettings.MaxConns)
+	}
+	return nil
+}
+
+// sslModes are the sslmode values pgx accepts.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// buildDSN returns DATABASE_URL when it is set, and otherwise builds the
+// connection URL from the DB_* variables. DB_SSLMODE defaults to disable, and
+// the certificate paths are passed on as the libpq parameters of the same name.
+func buildDSN() (string, error) {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn, nil
+	}
+
+	query := url.Values{}
+	sslmode := os.Getenv("DB_SSLMODE")
+	if sslmode == "" {
+		sslmode = "disable"
+	}
+	if !slices.Contains(sslModes, sslmode) {
+		return "", fmt.Errorf("unknown DB_SSLMODE %s", sslmode)
+	}
+	query.Set("sslmode", sslmode)
+
+	certificates := []struct{ env, param string }{
+		{"DB_SSLROOTCERT", "sslrootcert"},
+		{"DB_SSLCERT", "sslcert"},
+		{"DB_SSLKEY", "sslkey"},
+	}
+	for _, c := range certificates {
+		if path := os.Getenv(c.env); path != "" {
+			query.Set(c.param, path)
+		}
+	}
+	if (os.Getenv("DB_SSLCERT") == "") != (os.Getenv("DB_SSLKEY") == "") {
+		return "", fmt.Errorf("DB_SSLCERT and DB_SSLKEY must be set together")
+	}
+
+	host := os.Getenv("DB_HOST")
+	if port := os.Getenv("DB_PORT"); port != "" {
+		host += ":" + port
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(os.Getenv("DB_USER"), os.Getenv("DB_PASS")),
+		Host:     host,
+		Path:     "/" + os.Getenv("DB_NAME"),
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+// newPool creates a connection pool for the DSN with the pool settings, the
+// query tracer and the session settings every connection of a run uses.
+func newPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse DSN: %v", err)
+	}
+	if poolSettings.MaxConns > 0 {
+		config.MaxConns = poolSettings.MaxConns
+	}
+	if poolSettings.MinConns > 0 {
+		config.MinConns = poolSettings.MinConns
+	}
+	if poolSettings.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = poolSettings.MaxConnLifetime
+	}
+	if poolSettings.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = poolSettings.MaxConnIdleTime
+	}
+	if poolSettings.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = poolSettings.HealthCheckPeriod
+	}
+	config.ConnConfig.Tracer = queryTracer{}
+	configureSession(config)
+
+	// Record every connection, so the next run can clean up after this one
+	afterConnect := config.AfterConnect
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		registerSession(ctx, conn)
+		return nil
+	}
+
+	p, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %v", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"time"
+)
+
+// PrefetchStats describes how much of the page fetching a prefetching
+// strategy hid behind writing.
+type PrefetchStats struct {
+	// Fetch is the total time the page queries ran, Overlap the part of it
+	// that ran while the previous page was being written, and Waited the
+	// time writing had to wait for a page.
+	Fetch   time.Duration
+	Overlap time.Duration
+	Waited  time.Duration
+	// Verified is the number of pages whose checksum the write stage
+	// matched against the one the fetch stage computed.
+	Verified int
+}
+
+// prefetchedPage is a keyset page read into memory by a background query.
+// after is the key the page was queried after and checksum the CRC of its
+// rows as they were scanned, which the write stage checks the page against.
+type prefetchedPage struct {
+	after    int
+	checksum uint32
+	rows     []pageRow
+	start    time.Time
+	end      time.Time
+	timings  *QueryTimings
+	pooling  PoolStats
+	err      error
+}
+
+// prefetchPage reads the keyset page after lastId and delivers it on the
+// returned channel.
+func prefetchPage(ctx context.Context, lastId int) <-chan prefetchedPage {
+	next := make(chan prefetchedPage, 1)
+	go func() {
+		bctx, timings := traceQueries(ctx)
+		page := prefetchedPage{after: lastId, start: time.Now(), timings: timings}
+		defer func() {
+			page.end = time.Now()
+			next <- page
+		}()
+
+		rows, err := pool.Query(bctx, keysetPageQuery(selectList()), keysetPageArgs(lastId)...)
+		if err != nil {
+			page.err = fmt.Errorf("failed to fetch data: %w", err)
+			return
+		}
+		defer rows.Close()
+
+		var values *valuePool
+		if valuePooling {
+			values = newValuePool()
+			defer func() { page.pooling = values.stats }()
+		}
+
+		for rows.Next() {
+			record, key, err := scanRecord(rows)
+			if err != nil {
+				page.err = fmt.Errorf("failed to scan row: %w", err)
+				return
+			}
+			page.checksum = rowChecksum(page.checksum, key, record)
+			if values != nil {
+				record = values.add(record)
+			}
+			page.rows = append(page.rows, pageRow{record: record, key: key})
+		}
+		if rows.Err() != nil {
+			page.err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+		}
+	}()
+	return next
+}
+
+// rowChecksum extends the CRC of a page with the key and values of a row.
+// Every value is prefixed by its length, so that moving bytes across values
+// changes the checksum.
+func rowChecksum(crc uint32, key int, record []string) uint32 {
+	var buf []byte
+	buf = strconv.AppendInt(buf, int64(key), 10)
+	for _, value := range record {
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, int64(len(value)), 10)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return crc32.Update(crc, crc32.IEEETable, buf)
+}
+
+// overlap returns how long the intervals [aStart, aEnd] and [bStart, bEnd]
+// ran at the same time.
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	start, end := aStart, aEnd
+	if bStart.After(start) {
+		start = bStart
+	}
+	if bEnd.Before(end) {
+		end = bEnd
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// fetchWithPrefetch pages like the custom cursor strategy, but as soon as a
+// page has been read it queries the next page on another connection while the
+// current one is written, double buffering the export.
+func fetchWithPrefetch(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "custom_cursor_prefetch",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	sizes := newRowSizeRecorder()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	stats := &PrefetchStats{}
+	var pooling PoolStats
+	next := prefetchPage(ctx, 0)
+	var writeStart, writeEnd time.Time
+	var lastId int
+	for {
+		batchStart := time.Now()
+		page := <-next
+		stats.Waited += time.Since(batchStart)
+		stats.Fetch += page.end.Sub(page.start)
+
+		// The page's query overlapped the previous write for as long as both ran
+		stats.Overlap += overlap(writeStart, writeEnd, page.start, page.end)
+
+		if page.err != nil {
+			result.Err = page.err
+			res <- result
+			return page.err
+		}
+		pooling.add(page.pooling)
+
+		// The pages have to arrive in the order they were requested
+		if page.after != lastId {
+			err := fmt.Errorf("page after aid %d arrived when the page after aid %d was expected", page.after, lastId)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if len(page.rows) == 0 {
+			break
+		}
+
+		// Start on the next page before writing this one
+		lastId = page.rows[len(page.rows)-1].key
+		next = prefetchPage(ctx, lastId)
+
+		writeStart = time.Now()
+		var checksum uint32
+		for _, row := range page.rows {
+			checksum = rowChecksum(checksum, row.key, row.record)
+			n, err := page.timings.WriteRow(out, row.record)
+			if err != nil {
+				// Let the prefetch finish before its connection goes away
+				<-next
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+		}
+		writeEnd = time.Now()
+
+		// Catch rows corrupted or reordered between fetching and writing
+		if checksum != page.checksum {
+			<-next
+			err := fmt.Errorf("page aid %d..%d was changed between fetch and write: checksum %08x fetched, %08x written",
+				page.rows[0].key, lastId, page.checksum, checksum)
+			result.Err = err
+			res <- result
+			return err
+		}
+		stats.Verified++
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", page.rows[0].key, lastId),
+			Rows:     len(page.rows),
+			Duration: writeEnd.Sub(batchStart),
+
+			QueryTimings: *page.timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Prefetch = stats
+	if valuePooling {
+		result.Pooling = &pooling
+	}
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
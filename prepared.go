@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// prepareCompare adds the unprepared variants of the keyset and offset
+// strategies.
+var prepareCompare bool
+
+// fetchWithCustomCursorUnprepared pages like custom_cursor, but sends every
+// page as an unnamed statement that the server parses and plans again.
+func fetchWithCustomCursorUnprepared(ctx context.Context, res chan<- Result) error {
+	return fetchCustomCursor(ctx, res, "custom_cursor_unprepared", pgx.QueryExecModeExec, resultFormat)
+}
+
+// fetchWithOffsetLimitUnprepared pages like offset_limit, but sends every
+// page as an unnamed statement that the server parses and plans again.
+func fetchWithOffsetLimitUnprepared(ctx context.Context, res chan<- Result) error {
+	return fetchOffsetLimit(ctx, res, "offset_limit_unprepared", pgx.QueryExecModeExec)
+}
+
+// comparePrepared compares the keyset and offset strategies, which prepare
+// their page statement once per connection, with their unprepared variants.
+func comparePrepared(results []Result) []string {
+	byType := map[string]*Result{}
+	for i := range results {
+		if r := &results[i]; r.Err == nil && len(r.Batches) > 0 {
+			byType[r.Type] = r
+		}
+	}
+
+	var lines []string
+	for _, name := range []string{"custom_cursor", "offset_limit"} {
+		prepared, unprepared := byType[name], byType[name+"_unprepared"]
+		if prepared == nil || unprepared == nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s took %.2fs prepared, %.2fs unprepared (%+.1f%%), median page %.2fms and %.2fms",
+			name, prepared.Duration.Seconds(), unprepared.Duration.Seconds(),
+			100*(ratio(unprepared.Duration, prepared.Duration)-1),
+			float64(median(batchDurations(prepared.Batches)))/float64(time.Millisecond),
+			float64(median(batchDurations(unprepared.Batches)))/float64(time.Millisecond)))
+	}
+	return lines
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named benchmark configuration in the config file.
+type Profile struct {
+	Database struct {
+		// URL is a complete connection string used instead of the fields.
+		URL      string `yaml:"url"`
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Name     string `yaml:"name"`
+
+		SSLMode     string `yaml:"sslmode"`
+		SSLRootCert string `yaml:"sslrootcert"`
+		SSLCert     string `yaml:"sslcert"`
+		SSLKey      string `yaml:"sslkey"`
+	} `yaml:"database"`
+	Limit      int      `yaml:"limit"`
+	BatchSize  int      `yaml:"batch_size"`
+	Strategies []string `yaml:"strategies"`
+	Scenario   string   `yaml:"scenario"`
+	// Validation lists VALIDATION_RULES, one rule per entry.
+	Validation []string `yaml:"validation"`
+	// Env sets any other environment variable of the configuration.
+	Env map[string]string `yaml:"env"`
+}
+
+type configFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// loadProfile reads the named profile from the config file at path.
+func loadProfile(path, name string) (Profile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var config configFile
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return Profile{}, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		var names []string
+		for n := range config.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("no profile %q in %s, it has %s", name, path, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// apply sets the environment variables of the profile's settings, so the
+// profile takes the place of the .env file and the environment.
+func (p Profile) apply() error {
+	values := map[string]string{
+		"DATABASE_URL": p.Database.URL,
+		"DB_HOST":      p.Database.Host,
+		"DB_PORT":      p.Database.Port,
+		"DB_USER":      p.Database.User,
+		"DB_PASS":      p.Database.Password,
+		"DB_NAME":      p.Database.Name,
+		"SCENARIO":     p.Scenario,
+
+		"DB_SSLMODE":     p.Database.SSLMode,
+		"DB_SSLROOTCERT": p.Database.SSLRootCert,
+		"DB_SSLCERT":     p.Database.SSLCert,
+		"DB_SSLKEY":      p.Database.SSLKey,
+	}
+	if p.Limit > 0 {
+		values["DATA_LIMIT"] = strconv.Itoa(p.Limit)
+	}
+	if p.BatchSize > 0 {
+		values["DATA_BATCH_SIZE"] = strconv.Itoa(p.BatchSize)
+	}
+	if len(p.Strategies) > 0 {
+		values["STRATEGIES"] = strings.Join(p.Strategies, ",")
+	}
+	if len(p.Validation) > 0 {
+		values["VALIDATION_RULES"] = strings.Join(p.Validation, ";")
+	}
+	for key, value := range p.Env {
+		values[key] = value
+	}
+
+	for key, value := range values {
+		if value == "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("error applying profile: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// progress emits machine readable progress events when PROGRESS_FORMAT is
+// jsonl. It is disabled when its writer is nil.
+var progress progressWriter
+
+// ProgressEvent is one line of the jsonl progress stream.
+type ProgressEvent struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Strategy string    `json:"strategy,omitempty"`
+
+	Seq        int     `json:"seq,omitempty"`
+	Key        string  `json:"key,omitempty"`
+	Rows       int     `json:"rows,omitempty"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type progressWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (p *progressWriter) open(w io.Writer) {
+	p.enc = json.NewEncoder(w)
+}
+
+func (p *progressWriter) emit(event ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.enc == nil {
+		return
+	}
+	event.Time = time.Now()
+	// Progress is best effort and must never fail a strategy
+	_ = p.enc.Encode(event)
+}
+
+func (p *progressWriter) start(strategy string) {
+	p.emit(ProgressEvent{Event: "start", Strategy: strategy})
+}
+
+func (p *progressWriter) batch(strategy string, b Batch) {
+	p.emit(ProgressEvent{
+		Event:      "batch",
+		Strategy:   strategy,
+		Seq:        b.Seq,
+		Key:        b.Key,
+		Rows:       b.Rows,
+		DurationMs: float64(b.Duration) / float64(time.Millisecond),
+	})
+}
+
+func (p *progressWriter) done(result Result) {
+	event := ProgressEvent{
+		Event:      "done",
+		Strategy:   result.Type,
+		Rows:       result.RowSizes.Rows,
+		DurationMs: float64(result.Duration) / float64(time.Millisecond),
+	}
+	if result.Err != nil {
+		event.Event = "error"
+		event.Error = result.Err.Error()
+	}
+	p.emit(event)
+}
+
+// addBatch records a finished batch and reports it on the progress stream.
+// Batches over the deadline are explained first.
+func (r *Result) addBatch(ctx context.Context, b Batch) {
+	if batchDeadline > 0 && b.Duration > batchDeadline {
+		explainSlowBatch(ctx, r.Type, &b)
+	}
+	if metricsAddr != "" {
+		observeBatch(r.Type, &b)
+	}
+	r.Batches = append(r.Batches, b)
+	progress.batch(r.Type, b)
+	endArrowBatch(r.Type)
+
+	// Hold back while over the quotas, after the batch was timed
+	r.Throttled += quota.Wait(ctx, b.Rows)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// batchRetries is how often a failing page of the custom cursor strategy is
+// retried before its key range is quarantined and the export goes on after
+// it. Zero fails the strategy on the first error, as before.
+var batchRetries int
+
+// retryDelay is the backoff before the first retry of a page, doubled for
+// every further retry.
+const retryDelay = 100 * time.Millisecond
+
+// QuarantinedRange is a key range left out of an export because reading it
+// kept failing, for example on a corrupt TOAST value.
+type QuarantinedRange struct {
+	First   int    `json:"first"`
+	Last    int    `json:"last"`
+	Rows    int    `json:"rows"`
+	Retries int    `json:"retries"`
+	Err     string `json:"error"`
+}
+
+// retryBackoff waits before the given retry of a page.
+func retryBackoff(ctx context.Context, retry int) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(retryDelay << retry):
+	}
+}
+
+// quarantinePage finds the key range of the page after lastId that failed
+// with cause. Only the keys are read, which stay readable when the values of
+// a row do not, so the range covers the rows the failing page would have
+// returned.
+func quarantinePage(ctx context.Context, lastId, retries int, cause error) (QuarantinedRange, error) {
+	rows, err := pool.Query(ctx, keysetPageQuery(keyName()), keysetPageArgs(lastId)...)
+	if err != nil {
+		return QuarantinedRange{}, fmt.Errorf("failed to read the keys of the failing page: %w", err)
+	}
+	defer rows.Close()
+
+	q := QuarantinedRange{
+		Retries: retries,
+		Err:     cause.Error(),
+	}
+	for rows.Next() {
+		var key int
+		if err := rows.Scan(&key); err != nil {
+			return QuarantinedRange{}, fmt.Errorf("failed to scan key: %w", err)
+		}
+		if q.Rows == 0 {
+			q.First = key
+		}
+		q.Last = key
+		q.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return QuarantinedRange{}, fmt.Errorf("failed to read the keys of the failing page: %w", err)
+	}
+	if q.Rows == 0 {
+		return QuarantinedRange{}, fmt.Errorf("no keys after %d to quarantine", lastId)
+	}
+	return q, nil
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The SQL of every strategy is built here so that `explain` shows exactly what
+// the strategies run.
+
+// declareCursorQuery declares a cursor over the accounts after the given key.
+func declareCursorQuery(cursor string, after int) string {
+	return fmt.Sprintf(`
+		DECLARE %s CURSOR FOR
+		SELECT %s
+		FROM %s
+		WHERE %s > %d AND %s <= %d
+		ORDER BY %s ASC`, cursor, selectList(), tableName(), keyName(), after, keyName(), limit, keyName())
+}
+
+// declareHoldCursorQuery declares a cursor over the accounts that outlives its
+// transaction.
+func declareHoldCursorQuery(cursor string) string {
+	return fmt.Sprintf(`
+		DECLARE %s CURSOR WITH HOLD FOR
+		SELECT %s
+		FROM %s
+		WHERE %s <= %d
+		ORDER BY %s ASC`, cursor, selectList(), tableName(), keyName(), limit, keyName())
+}
+
+// declareScrollCursorQuery declares a cursor over the accounts that can also
+// fetch backward.
+func declareScrollCursorQuery(cursor string) string {
+	return fmt.Sprintf(`
+		DECLARE %s SCROLL CURSOR FOR
+		SELECT %s
+		FROM %s
+		WHERE %s <= %d
+		ORDER BY %s ASC`, cursor, selectList(), tableName(), keyName(), limit, keyName())
+}
+
+// defaultRefcursorFunction is the temporary function the refcursor strategy
+// creates when REFCURSOR_FUNCTION is not set.
+const defaultRefcursorFunction = "pg_temp.bench_open_accounts"
+
+// createRefcursorFunctionQuery creates the default function of the refcursor
+// strategy, which opens a cursor over the accounts and returns it.
+func createRefcursorFunctionQuery() string {
+	return fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %s() RETURNS refcursor AS $$
+		DECLARE
+			c refcursor;
+		BEGIN
+			OPEN c FOR
+			SELECT %s
+			FROM %s
+			WHERE %s <= %d
+			ORDER BY %s ASC;
+			RETURN c;
+		END
+		$$ LANGUAGE plpgsql`, defaultRefcursorFunction, selectList(), tableName(), keyName(), limit, keyName())
+}
+
+func callRefcursorQuery(function string) string {
+	return fmt.Sprintf("SELECT %s()", function)
+}
+
+func fetchCursorQuery(cursor string) string {
+	return fetchCursorSizeQuery(cursor, batchSize)
+}
+
+// fetchCursorSizeQuery fetches size rows, for strategies that vary the
+// batch size.
+func fetchCursorSizeQuery(cursor string, size int) string {
+	return fmt.Sprintf("FETCH %d FROM %s", size, cursor)
+}
+
+// keysetPageQuery is the keyset page query of the custom cursor strategy for
+// the given projection. The last key of the previous page is bound to $1 and
+// the last key to export to $2, see keysetPageArgs, so every page runs the
+// same statement.
+func keysetPageQuery(columns string) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s > $1 AND %s <= $2
+		ORDER BY %s ASC
+		LIMIT %d`, columns, tableName(), keyName(), keyName(), keyName(), batchSize)
+}
+
+// reverseKeysetPageQuery pages backwards, latest key first. The first key of
+// the previous page is bound to $1, the key above the limit for the first page.
+func reverseKeysetPageQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s < $1
+		ORDER BY %s DESC
+		LIMIT %d`, selectList(), tableName(), keyName(), keyName(), batchSize)
+}
+
+// keysetPageArgs are the arguments of keysetPageQuery for the page after
+// lastId.
+func keysetPageArgs(lastId int) []any {
+	return []any{lastId, limit}
+}
+
+// offsetPageQuery is the OFFSET page query of the offset strategy. The last
+// key to export is bound to $1 and the offset to $2, see offsetPageArgs.
+func offsetPageQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s <= $1
+		ORDER BY %s ASC
+		OFFSET $2 LIMIT %d`, selectList(), tableName(), keyName(), keyName(), batchSize)
+}
+
+// offsetPageArgs are the arguments of offsetPageQuery for the page at offset.
+func offsetPageArgs(offset int) []any {
+	return []any{limit, offset}
+}
+
+func copyCommand() string {
+	return fmt.Sprintf(`COPY (SELECT %s FROM %s WHERE %s <= %d ORDER BY %s ASC) TO STDOUT WITH (%s)`,
+		selectList(), tableName(), keyName(), limit, keyName(), exportCSV.copyOptions())
+}
+
+func cursorTupleFractionQuery(fraction float64) string {
+	return fmt.Sprintf("SET LOCAL cursor_tuple_fraction = %g", fraction)
+}
+
+func moveCursorQuery(cursor string, skip int) string {
+	return fmt.Sprintf("MOVE FORWARD %d FROM %s", skip, cursor)
+}
+
+func fetchForwardQuery(cursor string) string {
+	return fmt.Sprintf("FETCH FORWARD %d FROM %s", batchSize, cursor)
+}
+
+func fetchBackwardQuery(cursor string) string {
+	return fmt.Sprintf("FETCH BACKWARD %d FROM %s", batchSize, cursor)
+}
+
+func keyArrayQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s = ANY($1)
+		ORDER BY %s ASC`, selectList(), tableName(), keyName(), keyName())
+}
+
+func keyPointQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s = $1`, selectList(), tableName(), keyName())
+}
+
+func keyJoinQuery() string {
+	columns := make([]string, len(projection))
+	for i, column := range projection {
+		columns[i] = "a." + quoteIdent(column)
+	}
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s a
+		JOIN lookup_keys k ON k.aid = a.%s
+		ORDER BY a.%s ASC`, strings.Join(columns, ", "), tableName(), keyName(), keyName())
+}
+
+// keyTablePageQuery pages through a key type scenario table. Keys are bound
+// as text and cast to the key type, the first page has no lower bound.
+func keyTablePageQuery(table, keyType string, first bool) string {
+	if first {
+		return fmt.Sprintf(`
+		SELECT id::text, abalance
+		FROM %s
+		ORDER BY id ASC
+		LIMIT %d`, table, batchSize)
+	}
+	return fmt.Sprintf(`
+		SELECT id::text, abalance
+		FROM %s
+		WHERE id > ($1::text)::%s
+		ORDER BY id ASC
+		LIMIT %d`, table, keyType, batchSize)
+}
+
+// keysetMultiPageQuery pages by a sort specification such as KEYSET_ORDER,
+// binding the sort key values of the previous page's last row. Pages fetched
+// with ties, see keysetPageOrder, hold every row tied with their last row.
+func keysetMultiPageQuery(order []sortKey, first bool) string {
+	order, withTies := keysetPageOrder(order)
+	where := fmt.Sprintf("aid <= %d", limit)
+	if !first {
+		where += " AND " + seekPredicate(order)
+	}
+	fetch := fmt.Sprintf("LIMIT %d", batchSize)
+	if withTies {
+		fetch = fmt.Sprintf("FETCH FIRST %d ROWS WITH TIES", batchSize)
+	}
+	return fmt.Sprintf(`
+		SELECT aid, bid, abalance
+		FROM pgbench_accounts
+		WHERE %s
+		ORDER BY %s
+		%s`, where, orderByClause(order), fetch)
+}
+
+// blobPageQuery pages through the blob scenario table by id.
+func blobPageQuery(first bool) string {
+	where := ""
+	if !first {
+		where = "WHERE id > $1"
+	}
+	return fmt.Sprintf(`
+		SELECT id, payload, lo
+		FROM %s
+		%s
+		ORDER BY id ASC
+		LIMIT %d`, blobTable, where, batchSize)
+}
+
+// toastPageQuery pages through the TOAST scenario table by id.
+func toastPageQuery(columns []string, first bool) string {
+	where := ""
+	if !first {
+		where = "WHERE id > $1"
+	}
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		%s
+		ORDER BY id ASC
+		LIMIT %d`, strings.Join(columns, ", "), toastTable, where, batchSize)
+}
+
+// tenantPageQuery is the keyset page of the tenant bound to $1, after the id
+// bound to $2 unless it is the first page.
+func tenantPageQuery(first bool) string {
+	where := "tenant_id = $1"
+	if !first {
+		where += " AND id > $2"
+	}
+	return fmt.Sprintf(`
+		SELECT tenant_id, id, balance, created_at
+		FROM %s
+		WHERE %s
+		ORDER BY id ASC
+		LIMIT %d`, tenantTable, where, batchSize)
+}
+
+// tenantOffsetQuery is the OFFSET page of the tenant bound to $1, with the
+// offset bound to $2.
+func tenantOffsetQuery() string {
+	return fmt.Sprintf(`
+		SELECT tenant_id, id, balance, created_at
+		FROM %s
+		WHERE tenant_id = $1
+		ORDER BY id ASC
+		OFFSET $2 LIMIT %d`, tenantTable, batchSize)
+}
+
+func largestTenantQuery() string {
+	return fmt.Sprintf("SELECT tenant_id FROM %s GROUP BY tenant_id ORDER BY count(*) DESC, tenant_id LIMIT 1", tenantTable)
+}
+
+// softPageQuery is the keyset page of the live rows of the soft delete
+// scenario after the id bound to $1.
+func softPageQuery() string {
+	return fmt.Sprintf(`
+		SELECT id, balance
+		FROM %s
+		WHERE deleted_at IS NULL AND id > $1
+		ORDER BY id ASC
+		LIMIT %d`, softDeleteTable, batchSize)
+}
+
+// softOffsetQuery is the OFFSET page of the live rows of the soft delete
+// scenario, with the offset bound to $1.
+func softOffsetQuery() string {
+	return fmt.Sprintf(`
+		SELECT id, balance
+		FROM %s
+		WHERE deleted_at IS NULL
+		ORDER BY id ASC
+		OFFSET $1 LIMIT %d`, softDeleteTable, batchSize)
+}
+
+// declareSoftCursorQuery declares a cursor over the live rows of the soft
+// delete scenario.
+func declareSoftCursorQuery(cursor string) string {
+	return fmt.Sprintf(`
+		DECLARE %s CURSOR FOR
+		SELECT id, balance
+		FROM %s
+		WHERE deleted_at IS NULL
+		ORDER BY id ASC`, cursor, softDeleteTable)
+}
+
+// softDeleteQuery soft-deletes the row bound to $1 unless it already is.
+func softDeleteQuery() string {
+	return fmt.Sprintf("UPDATE %s SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", softDeleteTable)
+}
+
+func toastCopyCommand(columns []string) string {
+	return fmt.Sprintf(`COPY (SELECT %s FROM %s ORDER BY id ASC) TO STDOUT WITH (%s)`,
+		strings.Join(columns, ", "), toastTable, exportCSV.copyOptions())
+}
+
+// mixUpdateQuery updates the row with the key bound to $1 to its own values,
+// the write of the workload mix writers.
+func mixUpdateQuery() string {
+	return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = $1", tableName(), keyName(), keyName(), keyName())
+}
+
+// hashPageQuery is the keyset page of one worker of the hash partitioned
+// strategy, which owns the rows whose aid modulo the worker count is its id.
+// The worker is bound to $1 and the key the page starts after to $2.
+func hashPageQuery(workers int) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s %% %d = $1 AND %s > $2 AND %s <= %d
+		ORDER BY %s ASC
+		LIMIT %d`, selectList(), tableName(), keyName(), workers, keyName(), keyName(), limit, keyName(), batchSize)
+}
+
+// rangePageQuery is the keyset page of one worker of the range partitioned
+// strategy, after the key bound to $1 and up to the worker's last key bound
+// to $2.
+func rangePageQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s > $1 AND %s <= $2
+		ORDER BY %s ASC
+		LIMIT %d`, selectList(), tableName(), keyName(), keyName(), keyName(), batchSize)
+}
+
+// exportSnapshotQuery exports the snapshot of the transaction and returns
+// its id along with the txid snapshot it stands for.
+func exportSnapshotQuery() string {
+	return "SELECT pg_export_snapshot(), txid_current_snapshot()::text"
+}
+
+// importSnapshotQuery makes the transaction use an exported snapshot. It has
+// to be its first statement.
+func importSnapshotQuery(id string) string {
+	return fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", id)
+}
+
+func currentSnapshotQuery() string {
+	return "SELECT txid_current_snapshot()::text"
+}
+
+// activeBackendsQuery counts the client backends other than this one that
+// are running a statement.
+func activeBackendsQuery() string {
+	return `
+		SELECT count(*)
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend' AND state = 'active' AND pid <> pg_backend_pid()`
+}
+
+// replicationLagQuery returns the replay lag of the slowest standby in
+// seconds, zero without standbys.
+func replicationLagQuery() string {
+	return "SELECT COALESCE(EXTRACT(epoch FROM max(replay_lag)), 0)::float8 FROM pg_stat_replication"
+}
+
+// createLoadTableQueries create the empty target table of a load strategy
+// with the given columns of the benchmark table.
+func createLoadTableQueries(table string, columns []string) []string {
+	return []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(table)),
+		fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s WITH NO DATA", quoteIdent(table), quoteColumns(columns), tableName()),
+	}
+}
+
+// insertRowsQuery inserts rows rows of the columns with one statement, bound
+// row after row.
+func insertRowsQuery(table string, columns []string, rows int) string {
+	var values strings.Builder
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			values.WriteString(", ")
+		}
+		values.WriteByte('(')
+		for column := range columns {
+			if column > 0 {
+				values.WriteString(", ")
+			}
+			fmt.Fprintf(&values, "$%d", row*len(columns)+column+1)
+		}
+		values.WriteByte(')')
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdent(table), quoteColumns(columns), values.String())
+}
+
+func copyFromCommand(table string, columns []string) string {
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (%s)", quoteIdent(table), quoteColumns(columns), exportCSV.copyOptions())
+}
+
+func loadColumnsQuery(table string, columns []string) string {
+	return fmt.Sprintf("SELECT %s FROM %s LIMIT 0", quoteColumns(columns), quoteIdent(table))
+}
+
+// createWriteTableQueries create the table a write strategy modifies as a
+// copy of the benchmark table, with its indexes and constraints, holding the
+// rows up to the limit. It is vacuumed afterwards, see vacuumQuery, so the
+// writes start from a table without dead rows.
+func createWriteTableQueries(table string) []string {
+	return []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(table)),
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", quoteIdent(table), tableName()),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE %s <= %d", quoteIdent(table), tableName(), keyName(), limit),
+	}
+}
+
+func vacuumQuery(table string) string {
+	return fmt.Sprintf("VACUUM ANALYZE %s", quoteIdent(table))
+}
+
+// writeColumns are the columns a write strategy sets, every exported column
+// but the key, or the key alone when nothing else is exported.
+func writeColumns() []string {
+	var columns []string
+	for _, column := range projection {
+		if column != keyColumn {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return []string{keyColumn}
+	}
+	return columns
+}
+
+// updatePageQuery updates the page of the table after the key bound to $1
+// and up to the last key to export bound to $2, see keysetPageArgs, setting
+// the columns to themselves so every row gets a new version, and returns the
+// updated keys.
+func updatePageQuery(table string) string {
+	set := make([]string, 0, len(projection))
+	for _, column := range writeColumns() {
+		set = append(set, fmt.Sprintf("%s = %s", quoteIdent(column), quoteIdent(column)))
+	}
+	return fmt.Sprintf(`
+		UPDATE %s
+		SET %s
+		WHERE %s IN (
+			SELECT %s
+			FROM %s
+			WHERE %s > $1 AND %s <= $2
+			ORDER BY %s ASC
+			LIMIT %d)
+		RETURNING %s`, quoteIdent(table), strings.Join(set, ", "), keyName(),
+		keyName(), quoteIdent(table), keyName(), keyName(), keyName(), batchSize, keyName())
+}
+
+// upsertPageQuery inserts the page of the benchmark table after the key bound
+// to $1 into the table, updating the rows that already exist, and returns
+// the written keys.
+func upsertPageQuery(table string) string {
+	set := make([]string, 0, len(projection))
+	for _, column := range writeColumns() {
+		set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdent(column), quoteIdent(column)))
+	}
+	return fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT %s
+		FROM %s
+		WHERE %s > $1 AND %s <= $2
+		ORDER BY %s ASC
+		LIMIT %d
+		ON CONFLICT (%s) DO UPDATE SET %s
+		RETURNING %s`, quoteIdent(table), selectList(), selectList(), tableName(),
+		keyName(), keyName(), keyName(), batchSize, keyName(), strings.Join(set, ", "), keyName())
+}
+
+// mergePageQuery merges the page of the benchmark table after the key bound
+// to $1 into the table, updating the rows that already exist. With returning
+// it returns the written keys, which MERGE only can from PostgreSQL 17.
+func mergePageQuery(table string, returning bool) string {
+	set := make([]string, 0, len(projection))
+	for _, column := range writeColumns() {
+		set = append(set, fmt.Sprintf("%s = p.%s", quoteIdent(column), quoteIdent(column)))
+	}
+	values := make([]string, len(projection))
+	for i, column := range projection {
+		values[i] = "p." + quoteIdent(column)
+	}
+	query := fmt.Sprintf(`
+		MERGE INTO %s AS w
+		USING (
+			SELECT %s
+			FROM %s
+			WHERE %s > $1 AND %s <= $2
+			ORDER BY %s ASC
+			LIMIT %d) AS p
+		ON w.%s = p.%s
+		WHEN MATCHED THEN UPDATE SET %s
+		WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)`, quoteIdent(table), selectList(), tableName(),
+		keyName(), keyName(), keyName(), batchSize, keyName(), keyName(), strings.Join(set, ", "),
+		selectList(), strings.Join(values, ", "))
+	if returning {
+		query += fmt.Sprintf("\n\t\tRETURNING w.%s", keyName())
+	}
+	return query
+}
+
+// deleteChunkQuery deletes the rows of the table with keys from $1 to $2.
+func deleteChunkQuery(table string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s BETWEEN $1 AND $2", quoteIdent(table), keyName())
+}
+
+// createDecodingSlotQuery creates a temporary logical replication slot named
+// $1 with the output plugin $2 and returns where its changes start.
+func createDecodingSlotQuery() string {
+	return "SELECT lsn::text FROM pg_create_logical_replication_slot($1, $2, true)"
+}
+
+func dropDecodingSlotQuery() string {
+	return "SELECT pg_drop_replication_slot($1)"
+}
+
+// createPublicationQueries publish the changes of the table to pgoutput.
+func createPublicationQueries(table string) []string {
+	return []string{
+		fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", quoteIdent(table)),
+		fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", quoteIdent(table), quoteIdent(table)),
+	}
+}
+
+// decodingChangesQuery consumes the changes of slot $1 up to the LSN $2 and
+// about $3 changes, rounded up to whole transactions, in the plugin's own
+// format. pgoutput reads the publication of the table. wal2json matches
+// add-tables against schema qualified names, so the table is matched in any
+// schema, the one it was created in being the search path's.
+func decodingChangesQuery(plugin, table string) string {
+	var options string
+	switch plugin {
+	case "pgoutput":
+		options = fmt.Sprintf(", 'proto_version', '1', 'publication_names', '%s'", table)
+	case "wal2json":
+		options = fmt.Sprintf(", 'add-tables', '*.%s'", table)
+	}
+	return fmt.Sprintf("SELECT lsn::text, xid::text, data FROM pg_logical_slot_get_binary_changes($1, $2::pg_lsn, $3%s)", options)
+}
+
+func currentWALQuery() string {
+	return "SELECT pg_current_wal_insert_lsn()::text"
+}
+
+// walPositionQuery returns the current WAL insert position and the size of
+// the table with its indexes and TOAST.
+func walPositionQuery() string {
+	return "SELECT pg_current_wal_insert_lsn()::text, pg_total_relation_size($1::regclass)"
+}
+
+// walSinceQuery returns the WAL bytes written since the position bound to
+// $1 and the size of the table bound to $2.
+func walSinceQuery() string {
+	return "SELECT pg_wal_lsn_diff(pg_current_wal_insert_lsn(), $1::pg_lsn)::bigint, pg_total_relation_size($2::regclass)"
+}
+
+// keyBoundsQuery returns the lowest and highest key up to the limit.
+func keyBoundsQuery() string {
+	return fmt.Sprintf("SELECT min(%s), max(%s) FROM %s WHERE %s <= %d", keyName(), keyName(), tableName(), keyName(), limit)
+}
+
+// ctidRangeQuery reads the rows in the heap blocks from the tid bound to $1
+// up to but not including the one bound to $2, see ctidRangeArgs, which
+// Postgres 14 and later answer with a TID range scan.
+// Rows past the key limit are filtered out so the rows match the other
+// strategies.
+func ctidRangeQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE ctid >= $1::tid AND ctid < $2::tid AND %s <= %d`, selectList(), tableName(), keyName(), limit)
+}
+
+// ctidRangeArgs binds the heap blocks from first up to but not including end
+// to ctidRangeQuery.
+func ctidRangeArgs(first, end int) []any {
+	return []any{fmt.Sprintf("(%d,0)", first), fmt.Sprintf("(%d,0)", end)}
+}
+
+// relationBlocksQuery returns the number of heap blocks of the table named
+// by $1.
+func relationBlocksQuery() string {
+	return "SELECT (pg_relation_size($1::regclass) / current_setting('block_size')::int)::int"
+}
+
+// minmaxSummaryQuery summarizes the accounts into chunks of the given width in
+// keys, returning the first and last key and the row count of every non-empty
+// chunk.
+func minmaxSummaryQuery(chunk int) string {
+	return fmt.Sprintf(`
+		SELECT min(%s), max(%s), count(*)
+		FROM %s
+		WHERE %s <= %d
+		GROUP BY %s / %d
+		ORDER BY min(%s) ASC`, keyName(), keyName(), tableName(), keyName(), limit, keyName(), chunk, keyName())
+}
+
+// keyRangeQuery reads the accounts of one key range of the skip scan, the
+// keys from $1 to $2.
+func keyRangeQuery() string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s BETWEEN $1 AND $2
+		ORDER BY %s ASC`, selectList(), tableName(), keyName(), keyName())
+}
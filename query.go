@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// customQuery is the SELECT read from QUERY_FILE. The query strategies page
+// through it by the key column, binding $1 to the key the page starts after
+// and $2 to the page size, so the query can push the range into itself.
+var customQuery string
+
+// loadQuery reads QUERY_FILE.
+func loadQuery() error {
+	path := os.Getenv("QUERY_FILE")
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading query file: %v", err)
+	}
+	customQuery = strings.TrimRight(strings.TrimSpace(string(content)), ";")
+	if customQuery == "" {
+		return fmt.Errorf("query file %s is empty", path)
+	}
+	return nil
+}
+
+// queryPageQuery wraps the custom query into a page of its rows ordered by
+// the key, bounded like the other strategies by the key limit. $1 and $2 are
+// the custom query's own, see queryPageArgs, and the page is cut from its
+// rows by the offset bound to $3 and the page size bound to $4. $2 is
+// referenced outside too, so its type is known when the query does not use it.
+func queryPageQuery() string {
+	return fmt.Sprintf(`
+		SELECT *
+		FROM (%s) query
+		WHERE %s > $1 AND %s <= %d
+		ORDER BY %s ASC
+		OFFSET $3 LIMIT LEAST($4::bigint, $2::bigint)`, customQuery, keyName(), keyName(), limit, keyName())
+}
+
+// queryPageArgs are the arguments of queryPageQuery. The custom query is
+// given the key to start after and the page size, nil for no limit, and the
+// page is cut at offset with size rows, nil for every row.
+func queryPageArgs(after int, pageSize any, offset int, size any) []any {
+	return []any{after, pageSize, offset, size}
+}
+
+// queryCountQuery counts the rows of the custom query within the key limit,
+// which a paged strategy must have read all of. Bind queryPageArgs(0, nil,
+// 0, nil).
+func queryCountQuery() string {
+	return fmt.Sprintf("SELECT count(*) FROM (%s) page", queryPageQuery())
+}
+
+func declareQueryCursorQuery(cursor string) string {
+	return fmt.Sprintf("DECLARE %s CURSOR FOR %s", cursor, strings.TrimSpace(queryPageQuery()))
+}
+
+// queryWriter writes the rows of the custom query, taking the header from the
+// columns of the first result.
+type queryWriter struct {
+	out    rowSink
+	sizes  *rowSizeRecorder
+	header bool
+	key    int
+}
+
+// writeRows writes the rows and returns their number and first and last key.
+func (w *queryWriter) writeRows(ctx context.Context, rows pgx.Rows) (count, firstId, lastId int, err error) {
+	defer rows.Close()
+
+	if !w.header {
+		var columns []string
+		w.key = -1
+		for i, field := range rows.FieldDescriptions() {
+			if field.Name == keyColumn {
+				w.key = i
+			}
+			columns = append(columns, field.Name)
+		}
+		if w.key < 0 {
+			return 0, 0, 0, fmt.Errorf("query has no key column %s", keyColumn)
+		}
+		if err := w.out.WriteHeader(columns); err != nil {
+			return 0, 0, 0, err
+		}
+		w.header = true
+	}
+
+	timings := timingsFrom(ctx)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return count, firstId, lastId, fmt.Errorf("failed to scan row: %w", err)
+		}
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = formatValue(v)
+		}
+		key, err := integerKey(values[w.key])
+		if err != nil {
+			return count, firstId, lastId, err
+		}
+
+		n, err := timings.WriteRow(w.out, record)
+		if err != nil {
+			return count, firstId, lastId, err
+		}
+		w.sizes.Add(n)
+
+		if count == 0 {
+			firstId = key
+		}
+		lastId = key
+		count++
+	}
+
+	if rows.Err() != nil {
+		return count, firstId, lastId, fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+	}
+	return count, firstId, lastId, nil
+}
+
+// fetchQueryWithCursor fetches the custom query in batches from a cursor.
+func fetchQueryWithCursor(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "query_cursor",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	w := &queryWriter{out: out, sizes: newRowSizeRecorder()}
+
+	// Start a transaction
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The cursor covers the whole range, a NULL limit reads every row
+	_, err = tx.Exec(ctx, declareQueryCursorQuery("query_cursor"), queryPageArgs(0, nil, 0, nil)...)
+	if err != nil {
+		err = fmt.Errorf("failed to declare cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := tx.Query(bctx, fetchCursorQuery("query_cursor"))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, firstId, lastId, err := w.writeRows(bctx, rows)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("%s %d..%d", keyColumn, firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	err = finishQuery(out, w, start, &result)
+	res <- result
+	return err
+}
+
+// fetchQueryWithKeyset pages the custom query after the last key of the
+// previous page.
+func fetchQueryWithKeyset(ctx context.Context, res chan<- Result) error {
+	return fetchQueryPages(ctx, res, "query_keyset", func(page, lastId int) (string, []any) {
+		return queryPageQuery(), queryPageArgs(lastId, batchSize, 0, batchSize)
+	})
+}
+
+// fetchQueryWithOffset pages the custom query with OFFSET. The custom query
+// reads every row and the page is cut from them, as a client paging a query
+// it can not change would.
+func fetchQueryWithOffset(ctx context.Context, res chan<- Result) error {
+	return fetchQueryPages(ctx, res, "query_offset", func(page, lastId int) (string, []any) {
+		return queryPageQuery(), queryPageArgs(0, nil, page*batchSize, batchSize)
+	})
+}
+
+// fetchQueryPages runs one query per page of the custom query, built from
+// the page number and the last key of the previous page.
+func fetchQueryPages(ctx context.Context, res chan<- Result, name string, pageQuery func(page, lastId int) (string, []any)) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: name,
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	w := &queryWriter{out: out, sizes: newRowSizeRecorder()}
+
+	var lastId int
+	for page := 0; ; page++ {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		query, args := pageQuery(page, lastId)
+		rows, err := pool.Query(bctx, query, args...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, firstId, last, err := w.writeRows(bctx, rows)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+		lastId = last
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("%s %d..%d", keyColumn, firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	if err := finishQuery(out, w, start, &result); err != nil {
+		res <- result
+		return err
+	}
+
+	// Pages that stop short, such as a LIMIT in the custom query cutting
+	// off later pages, lose rows without failing
+	var want int
+	if err := pool.QueryRow(ctx, queryCountQuery(), queryPageArgs(0, nil, 0, nil)...).Scan(&want); err != nil {
+		result.Err = fmt.Errorf("failed to count query rows: %w", err)
+	} else if result.RowSizes.Rows != want {
+		result.Err = fmt.Errorf("%s read %d of the query's %d rows", name, result.RowSizes.Rows, want)
+	}
+	res <- result
+	return result.Err
+}
+
+// finishQuery finalizes the output of a query strategy and completes its
+// result, which the caller sends.
+func finishQuery(out rowSink, w *queryWriter, start time.Time, result *Result) error {
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		return err
+	}
+
+	duration := time.Since(start)
+
+	result.Duration = duration
+	result.RowSizes = w.sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaLimits protect a shared server from the benchmark. Zero values leave
+// a limit off.
+type quotaLimits struct {
+	// RowsPerSec caps the rows all strategies together read per second.
+	RowsPerSec float64
+	// MaxActive is the number of other active client backends above which
+	// the server is taken to be busy, standing in for its CPU load.
+	MaxActive int
+	// MaxReplicationLag is the replay lag of the slowest standby above which
+	// the run holds back.
+	MaxReplicationLag time.Duration
+}
+
+func (l quotaLimits) enabled() bool {
+	return l.RowsPerSec > 0 || l.MaxActive > 0 || l.MaxReplicationLag > 0
+}
+
+// quotaInterval is how often the server load is sampled.
+const quotaInterval = time.Second
+
+var quotas quotaLimits
+
+// loadQuotas reads the QUOTA_* variables.
+func loadQuotas() error {
+	if r := os.Getenv("QUOTA_ROWS_PER_SEC"); r != "" {
+		rate, err := strconv.ParseFloat(r, 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("QUOTA_ROWS_PER_SEC must be a non-negative number: %s", r)
+		}
+		quotas.RowsPerSec = rate
+	}
+	if a := os.Getenv("QUOTA_MAX_ACTIVE"); a != "" {
+		active, err := strconv.Atoi(a)
+		if err != nil || active < 0 {
+			return fmt.Errorf("QUOTA_MAX_ACTIVE must be a non-negative number: %s", a)
+		}
+		quotas.MaxActive = active
+	}
+	if l := os.Getenv("QUOTA_MAX_REPLICATION_LAG"); l != "" {
+		lag, err := time.ParseDuration(l)
+		if err != nil {
+			return fmt.Errorf("error parsing QUOTA_MAX_REPLICATION_LAG: %v", err)
+		}
+		quotas.MaxReplicationLag = lag
+	}
+	return nil
+}
+
+// quota throttles the strategies between batches while they read faster
+// than the row quota or while the server is over its load limits.
+var quota = &quotaControl{}
+
+type quotaControl struct {
+	mu     sync.Mutex
+	limits quotaLimits
+	// next is when the rows read so far have been paid for at the row quota.
+	next time.Time
+	// overloaded is closed and replaced once the server is back under its
+	// limits, nil while it is under them.
+	overloaded chan struct{}
+	stop       context.CancelFunc
+	done       chan struct{}
+}
+
+// Start enables the limits and samples the server load until Stop.
+func (q *quotaControl) Start(ctx context.Context, limits quotaLimits) {
+	q.mu.Lock()
+	q.limits = limits
+	q.next = time.Time{}
+	q.mu.Unlock()
+	if limits.MaxActive == 0 && limits.MaxReplicationLag == 0 {
+		return
+	}
+
+	mctx, cancel := context.WithCancel(ctx)
+	q.stop = cancel
+	q.done = make(chan struct{})
+	go func() {
+		defer close(q.done)
+		ticker := time.NewTicker(quotaInterval)
+		defer ticker.Stop()
+		for {
+			reason, err := q.sample(mctx)
+			if err != nil {
+				if mctx.Err() != nil {
+					return
+				}
+				fmt.Println("quota monitoring stopped:", err)
+				q.set("")
+				return
+			}
+			q.set(reason)
+
+			select {
+			case <-mctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops sampling and releases every strategy held by the limits.
+func (q *quotaControl) Stop() {
+	if q.stop != nil {
+		q.stop()
+		<-q.done
+		q.stop = nil
+	}
+	q.set("")
+	q.mu.Lock()
+	q.limits = quotaLimits{}
+	q.mu.Unlock()
+}
+
+// sample returns why the server is over its limits, or "" if it is not.
+func (q *quotaControl) sample(ctx context.Context) (string, error) {
+	var reasons []string
+	if q.limits.MaxActive > 0 {
+		var active int
+		if err := pool.QueryRow(ctx, activeBackendsQuery()).Scan(&active); err != nil {
+			return "", fmt.Errorf("failed to count active backends: %w", err)
+		}
+		if active > q.limits.MaxActive {
+			reasons = append(reasons, fmt.Sprintf("%d active backends", active))
+		}
+	}
+	if q.limits.MaxReplicationLag > 0 {
+		var seconds float64
+		if err := pool.QueryRow(ctx, replicationLagQuery()).Scan(&seconds); err != nil {
+			return "", fmt.Errorf("failed to read replication lag: %w", err)
+		}
+		if lag := time.Duration(seconds * float64(time.Second)); lag > q.limits.MaxReplicationLag {
+			reasons = append(reasons, fmt.Sprintf("replication lag %s", lag.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(reasons, ", "), nil
+}
+
+// set holds the strategies while reason is set and releases them once it is
+// cleared, announcing both.
+func (q *quotaControl) set(reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case reason != "" && q.overloaded == nil:
+		q.overloaded = make(chan struct{})
+		fmt.Printf("throttling, server over its limits: %s\n", reason)
+	case reason == "" && q.overloaded != nil:
+		close(q.overloaded)
+		q.overloaded = nil
+		fmt.Println("server back under its limits, resuming")
+	}
+}
+
+// Wait charges the rows of a batch against the row quota and blocks until
+// they are paid for and the server is under its load limits. It returns how
+// long it waited.
+func (q *quotaControl) Wait(ctx context.Context, rows int) time.Duration {
+	q.mu.Lock()
+	var delay time.Duration
+	if q.limits.RowsPerSec > 0 {
+		now := time.Now()
+		if q.next.Before(now) {
+			q.next = now
+		}
+		q.next = q.next.Add(time.Duration(float64(rows) / q.limits.RowsPerSec * float64(time.Second)))
+		delay = q.next.Sub(now)
+	}
+	overloaded := q.overloaded
+	q.mu.Unlock()
+
+	if delay <= 0 && overloaded == nil {
+		return 0
+	}
+
+	start := time.Now()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	if overloaded != nil {
+		select {
+		case <-ctx.Done():
+		case <-overloaded:
+		}
+	}
+	return time.Since(start)
+}
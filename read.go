@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// readBufferSize is the read buffer of both readers, so they differ in
+// parsing only.
+const readBufferSize = 1 << 20
+
+// ReadTiming is how long a reader took to parse a file.
+type ReadTiming struct {
+	Reader   string
+	Rows     int
+	Fields   int
+	Duration time.Duration
+}
+
+// csvReaders are the readers `read` compares, encoding/csv and a reader that
+// splits the lines in its buffer without copying them.
+var csvReaders = []struct {
+	name string
+	read func(io.Reader) (rows, fields int, err error)
+}{
+	{"encoding/csv", readWithEncodingCSV},
+	{"fast", readWithFastCSV},
+}
+
+// runRead times parsing the CSV exports back, the files given or every
+// export of the latest run, with each reader.
+func runRead(paths []string) error {
+	if len(paths) == 0 {
+		exports, err := latestExports()
+		if err != nil {
+			return err
+		}
+		paths = exports
+	}
+
+	readers := csvReaders
+	if exportCSV.quote != '"' {
+		fmt.Printf("encoding/csv only reads fields quoted with \", reading with the %s reader only\n", csvReaders[1].name)
+		readers = csvReaders[1:]
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		var timings []ReadTiming
+		for _, reader := range readers {
+			t, err := timeRead(path, reader.name, reader.read)
+			if err != nil {
+				return fmt.Errorf("%s with %s: %w", path, reader.name, err)
+			}
+			timings = append(timings, t)
+		}
+
+		for _, t := range timings {
+			fmt.Printf("%s read with %s in %s, %s rows at %s MB/s\n",
+				path, t.Reader, human.Duration(t.Duration), human.Int(int64(t.Rows)),
+				human.Float(float64(info.Size())/1e6/t.Duration.Seconds(), 1))
+		}
+		for _, t := range timings[1:] {
+			if t.Rows != timings[0].Rows || t.Fields != timings[0].Fields {
+				return fmt.Errorf("%s: %s read %d rows and %d fields, %s %d and %d",
+					path, t.Reader, t.Rows, t.Fields, timings[0].Reader, timings[0].Rows, timings[0].Fields)
+			}
+		}
+	}
+	return nil
+}
+
+// latestExports are the CSV exports of the latest run, compressed or not,
+// without the batch timings and rejected rows.
+func latestExports() ([]string, error) {
+	var paths []string
+	for _, ext := range append([]string{""}, compressedExts...) {
+		matches, err := filepath.Glob(filepath.Join(outputPath(latestLink), "*.csv"+ext))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return strings.HasSuffix(path, ".batches.csv") || strings.HasSuffix(path, ".rejects.csv")
+	})
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no CSV exports in %s", outputPath(latestLink))
+	}
+	return paths, nil
+}
+
+// timeRead times reading the file with a reader. The time of a compressed
+// file includes decompressing it.
+func timeRead(path, name string, read func(io.Reader) (int, int, error)) (ReadTiming, error) {
+	file, err := openDecompressed(path)
+	if err != nil {
+		return ReadTiming{}, err
+	}
+	defer file.Close()
+
+	start := time.Now()
+	rows, fields, err := read(bufio.NewReaderSize(file, readBufferSize))
+	return ReadTiming{Reader: name, Rows: rows, Fields: fields, Duration: time.Since(start)}, err
+}
+
+// readWithEncodingCSV reads the records with encoding/csv as most consumers
+// would, reusing the record.
+func readWithEncodingCSV(r io.Reader) (int, int, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = rune(exportCSV.delimiter)
+	reader.ReuseRecord = true
+	var rows, fields int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, fields, nil
+		}
+		if err != nil {
+			return rows, fields, err
+		}
+		rows++
+		fields += len(record)
+	}
+}
+
+// readWithFastCSV reads the records with fastCSVReader.
+func readWithFastCSV(r io.Reader) (int, int, error) {
+	reader := &fastCSVReader{r: r.(*bufio.Reader), delimiter: exportCSV.delimiter, quote: exportCSV.quote}
+	var rows, fields int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, fields, nil
+		}
+		if err != nil {
+			return rows, fields, err
+		}
+		rows++
+		fields += len(record)
+	}
+}
+
+// fastCSVReader reads RFC 4180 CSV, in the delimiter and quote of the
+// exports, as byte slices into its buffer, valid until the next Read. Lines
+// without quotes, most lines of an export, are split in place; only quoted
+// fields are copied to be unescaped.
+type fastCSVReader struct {
+	r         *bufio.Reader
+	delimiter byte
+	quote     byte
+	line      []byte
+	joined    []byte
+	fields    [][]byte
+	quoted    []byte
+}
+
+func (f *fastCSVReader) Read() ([][]byte, error) {
+	line, err := f.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	// A quoted field may hold line breaks, read on until the quotes pair up.
+	// The line is copied first, reading on reuses the buffer it is in
+	if bytes.Count(line, []byte{f.quote})%2 != 0 {
+		f.joined = append(f.joined[:0], line...)
+		for bytes.Count(f.joined, []byte{f.quote})%2 != 0 {
+			more, err := f.readLine()
+			if err == io.EOF {
+				return nil, errors.New("unterminated quoted field")
+			}
+			if err != nil {
+				return nil, err
+			}
+			f.joined = append(f.joined, more...)
+		}
+		line = f.joined
+	}
+	line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+
+	f.fields = f.fields[:0]
+	if bytes.IndexByte(line, f.quote) < 0 {
+		for {
+			i := bytes.IndexByte(line, f.delimiter)
+			if i < 0 {
+				f.fields = append(f.fields, line)
+				return f.fields, nil
+			}
+			f.fields = append(f.fields, line[:i])
+			line = line[i+1:]
+		}
+	}
+	return f.splitQuoted(line)
+}
+
+// readLine returns the next line with its line break, copying it only when
+// it is longer than the buffer.
+func (f *fastCSVReader) readLine() ([]byte, error) {
+	line, err := f.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		f.line = append(f.line[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = f.r.ReadSlice('\n')
+			f.line = append(f.line, line...)
+		}
+		line = f.line
+	}
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return line, err
+}
+
+// splitQuoted splits a line with quoted fields, unescaping doubled quotes.
+func (f *fastCSVReader) splitQuoted(line []byte) ([][]byte, error) {
+	f.quoted = f.quoted[:0]
+	var bounds [][2]int
+	for {
+		start := len(f.quoted)
+		if len(line) > 0 && line[0] == f.quote {
+			line = line[1:]
+			for {
+				i := bytes.IndexByte(line, f.quote)
+				if i < 0 {
+					return nil, errors.New("unterminated quoted field")
+				}
+				f.quoted = append(f.quoted, line[:i]...)
+				line = line[i+1:]
+				if len(line) > 0 && line[0] == f.quote {
+					f.quoted = append(f.quoted, f.quote)
+					line = line[1:]
+					continue
+				}
+				break
+			}
+			if len(line) > 0 && line[0] != f.delimiter {
+				return nil, fmt.Errorf("extraneous %c in field", f.quote)
+			}
+		} else {
+			i := bytes.IndexByte(line, f.delimiter)
+			if i < 0 {
+				i = len(line)
+			}
+			f.quoted = append(f.quoted, line[:i]...)
+			line = line[i:]
+		}
+		bounds = append(bounds, [2]int{start, len(f.quoted)})
+
+		if len(line) == 0 {
+			break
+		}
+		line = line[1:]
+	}
+
+	// Slice once all fields are copied, appending may have moved the buffer
+	for _, b := range bounds {
+		f.fields = append(f.fields, f.quoted[b[0]:b[1]])
+	}
+	return f.fields, nil
+}
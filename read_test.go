@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFastCSVReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		delimiter byte
+		quote     byte
+		bufSize   int
+		want      [][]string
+		wantErr   bool
+	}{
+		{name: "plain", input: "a,b,c\n1,2,3\n", want: [][]string{{"a", "b", "c"}, {"1", "2", "3"}}},
+		{name: "no final line break", input: "a,b\n1,2", want: [][]string{{"a", "b"}, {"1", "2"}}},
+		{name: "crlf", input: "a,b\r\n1,2\r\n", want: [][]string{{"a", "b"}, {"1", "2"}}},
+		{name: "empty fields", input: ",,\n", want: [][]string{{"", "", ""}}},
+		{name: "quoted", input: "\"a,b\",\"say \"\"hi\"\"\",c\n", want: [][]string{{"a,b", `say "hi"`, "c"}}},
+		{name: "quoted empty", input: "\"\",x\n", want: [][]string{{"", "x"}}},
+		{name: "line break in quotes", input: "1,\"a\nb\"\n2,c\n", want: [][]string{{"1", "a\nb"}, {"2", "c"}}},
+		{name: "tab and single quote", input: "'a\tb'\t'it''s'\n", delimiter: '\t', quote: '\'', want: [][]string{{"a\tb", "it's"}}},
+		{name: "longer than the buffer", input: strings.Repeat("x", 40) + "," + strings.Repeat("y", 40) + "\n", bufSize: 16,
+			want: [][]string{{strings.Repeat("x", 40), strings.Repeat("y", 40)}}},
+		{name: "unterminated", input: "\"a,b\n", wantErr: true},
+		{name: "extraneous quote", input: "\"a\"b,c\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delimiter, quote, bufSize := tt.delimiter, tt.quote, tt.bufSize
+			if delimiter == 0 {
+				delimiter = ','
+			}
+			if quote == 0 {
+				quote = '"'
+			}
+			if bufSize == 0 {
+				bufSize = 4096
+			}
+			r := &fastCSVReader{r: bufio.NewReaderSize(strings.NewReader(tt.input), bufSize), delimiter: delimiter, quote: quote}
+
+			var got [][]string
+			for {
+				record, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if !tt.wantErr {
+						t.Fatalf("Read: %v", err)
+					}
+					return
+				}
+				fields := make([]string, len(record))
+				for i, field := range record {
+					fields[i] = string(field)
+				}
+				got = append(got, fields)
+			}
+			if tt.wantErr {
+				t.Fatalf("Read returned %q, want an error", got)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("Read = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
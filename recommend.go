@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// fullExports are the strategies that read the whole table, so their total
+// durations are comparable.
+var fullExports = []string{"cursor", "offset_limit", "custom_cursor", "copy"}
+
+// recommend derives plain language advice from the results of a run.
+func recommend(results []Result) []string {
+	byType := make(map[string]Result)
+	for _, result := range results {
+		if result.Err == nil {
+			byType[result.Type] = result
+		}
+	}
+
+	var recommendations []string
+
+	if offset, ok := byType["offset_limit"]; ok {
+		if keyset, ok := byType["custom_cursor"]; ok {
+			if r, ok := recommendPagination(offset, keyset); ok {
+				recommendations = append(recommendations, r)
+			}
+		}
+	}
+
+	var fastest, slowest Result
+	var compared int
+	for _, name := range fullExports {
+		result, ok := byType[name]
+		if !ok {
+			continue
+		}
+		if compared == 0 || result.Duration < fastest.Duration {
+			fastest = result
+		}
+		if compared == 0 || result.Duration > slowest.Duration {
+			slowest = result
+		}
+		compared++
+	}
+	if compared > 1 && fastest.Duration > 0 {
+		recommendations = append(recommendations, fmt.Sprintf(
+			"%s is best for full exports, %.1f× faster than %s (%.2fs vs %.2fs).",
+			fastest.Type, ratio(slowest.Duration, fastest.Duration), slowest.Type,
+			fastest.Duration.Seconds(), slowest.Duration.Seconds()))
+	}
+
+	if cursor, ok := byType["cursor"]; ok && cursor.Duration > time.Minute {
+		recommendations = append(recommendations, fmt.Sprintf(
+			"cursor kept one transaction open for %.0fs, holding back vacuum for the whole export; prefer keyset pagination on busy tables.",
+			cursor.Duration.Seconds()))
+	}
+
+	if indexOnly, ok := byType["custom_cursor_index_only"]; ok && indexOnly.Plan != nil {
+		if fetches := indexOnly.Plan.HeapFetches(); fetches > 0 {
+			recommendations = append(recommendations, fmt.Sprintf(
+				"index only pages still made %d heap fetches; VACUUM the table so the visibility map lets them skip the heap.",
+				fetches))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(byType)) {
+		if e := byType[name].Estimate; e != nil && e.Divergence >= estimateThreshold && e.Relation != "" {
+			recommendations = append(recommendations, fmt.Sprintf(
+				"%s pages ran on a %.0f× row misestimate by the %s of %s; ANALYZE the table, or raise its statistics target, before trusting their plan.",
+				name, e.Divergence, e.Node, e.Relation))
+		}
+	}
+
+	var outliers, nearCheckpoint int
+	for _, result := range byType {
+		for _, b := range result.Outliers {
+			outliers++
+			if b.NearCheckpoint {
+				nearCheckpoint++
+			}
+		}
+	}
+	if nearCheckpoint > 0 && nearCheckpoint*2 >= outliers {
+		recommendations = append(recommendations, fmt.Sprintf(
+			"%d of %d outlier batches coincided with checkpoints; spread checkpoints out before drawing conclusions from tail latencies.",
+			nearCheckpoint, outliers))
+	}
+
+	return recommendations
+}
+
+// slowdownThreshold is how many times slower than keyset pagination OFFSET
+// pages must be before the pagination rule calls it out.
+const slowdownThreshold = 2
+
+// recommendPagination finds the page from which OFFSET pages are consistently
+// slower than keyset pages and how much slower they are from there on. Pages
+// are compared in windows of a tenth of the run so single slow pages do not
+// move the crossover.
+func recommendPagination(offset, keyset Result) (string, bool) {
+	pages := min(len(offset.Batches), len(keyset.Batches))
+	if pages < 10 {
+		return "", false
+	}
+
+	window := pages / 10
+	for from := 0; from+window <= pages; from += window {
+		if ratio(windowMedian(offset.Batches[from:from+window]), windowMedian(keyset.Batches[from:from+window])) < slowdownThreshold {
+			continue
+		}
+
+		r := ratio(windowMedian(offset.Batches[from:pages]), windowMedian(keyset.Batches[from:pages]))
+		if from == 0 {
+			return fmt.Sprintf("keyset pagination is %.0f× faster than OFFSET at every page for %d-row pages.", r, batchSize), true
+		}
+		return fmt.Sprintf("for %d rows and %d-row pages, keyset pagination is %.0f× faster than OFFSET beyond page %d.",
+			limit, batchSize, r, from), true
+	}
+
+	return fmt.Sprintf("OFFSET kept up with keyset pagination over all %d pages; at this table size either works.", pages), true
+}
+
+func windowMedian(batches []Batch) time.Duration {
+	durations := make([]time.Duration, len(batches))
+	for i, b := range batches {
+		durations[i] = b.Duration
+	}
+	return median(durations)
+}
+
+func ratio(a, b time.Duration) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// refcursorFunction names the function the refcursor strategy calls. It has
+// to take no arguments and return a refcursor over the exported columns in
+// key order. The default is a temporary function created for the run.
+var refcursorFunction string
+
+// refcursorEnabled enables the refcursor strategy.
+var refcursorEnabled bool
+
+// openRefcursor opens the cursor of the refcursor strategy in tx, creating
+// the default function first when REFCURSOR_FUNCTION is not set, and returns
+// the name the function gave it.
+func openRefcursor(ctx context.Context, tx pgx.Tx) (string, error) {
+	call := refcursorFunction
+	if call == "" {
+		if _, err := tx.Exec(ctx, createRefcursorFunctionQuery()); err != nil {
+			return "", fmt.Errorf("failed to create function: %w", err)
+		}
+		call = defaultRefcursorFunction
+	}
+
+	var name string
+	if err := tx.QueryRow(ctx, callRefcursorQuery(call)).Scan(&name); err != nil {
+		return "", fmt.Errorf("failed to call %s: %w", call, err)
+	}
+	return name, nil
+}
+
+// fetchWithRefcursor calls a server-side function that opens a cursor and
+// returns it as a refcursor, the way APIs built around stored functions
+// hand out results, and fetches the cursor in batches like the cursor
+// strategy.
+func fetchWithRefcursor(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "refcursor",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	// The cursor only lives as long as the transaction it was opened in
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	openStart := time.Now()
+	name, err := openRefcursor(ctx, tx)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	result.Open = time.Since(openStart)
+	cursor := pgx.Identifier{name}.Sanitize()
+
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := tx.Query(bctx, fetchCursorQuery(cursor))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Process each row in the batch
+		var count, firstId, lastId int
+		for rows.Next() {
+			record, aid, err := scanRecord(rows)
+			if err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				firstId = aid
+			}
+			lastId = aid
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("%s %d..%d", keyColumn, firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareRefcursor compares the function returned cursor with the cursor
+// the client declares and with client driven keyset pagination.
+func compareRefcursor(results []Result) (string, bool) {
+	var refcursor, cursor, keyset *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "refcursor":
+			refcursor = r
+		case r.Type == "cursor":
+			cursor = r
+		case r.Type == "custom_cursor":
+			keyset = r
+		}
+	}
+	if refcursor == nil || (cursor == nil && keyset == nil) {
+		return "", false
+	}
+
+	line := fmt.Sprintf("refcursor took %.2fs, %s of it in the function opening the cursor", refcursor.Duration.Seconds(), refcursor.Open)
+	if cursor != nil {
+		line += fmt.Sprintf(", cursor %.2fs", cursor.Duration.Seconds())
+	}
+	if keyset != nil {
+		line += fmt.Sprintf(", custom_cursor %.2fs", keyset.Duration.Seconds())
+	}
+	return line, true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Report is the data model exposed to user supplied report templates.
+type Report struct {
+	Name        string
+	Notes       string
+	Started     time.Time
+	Limit       int
+	BatchSize   int
+	Results     []Result
+	Checkpoints []Checkpoint
+
+	Recommendations []string
+	// Policy compares page plans with and without DB_ROLE when it is set.
+	Policy *PolicyPlans
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// renderReport executes the template at path against the report and writes the
+// output next to the CSV files. Templates ending in .html or .htm are parsed
+// with html/template so values are escaped, everything else uses text/template.
+func renderReport(path string, report Report) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading template: %w", err)
+	}
+
+	name := filepath.Base(path)
+	ext := filepath.Ext(strings.TrimSuffix(name, ".tmpl"))
+
+	var tmpl executor
+	switch ext {
+	case ".html", ".htm":
+		tmpl, err = htmltemplate.New(name).Parse(string(content))
+	default:
+		tmpl, err = template.New(name).Parse(string(content))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error parsing template: %w", err)
+	}
+
+	if ext == "" {
+		ext = ".txt"
+	}
+	output := outputPath("report" + ext)
+
+	file, err := os.Create(output)
+	if err != nil {
+		return "", fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, report); err != nil {
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+
+	return output, nil
+}
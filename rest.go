@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// restAPI is the REST endpoint the rest_api strategy paginates, which serves
+// the rows of the benchmark table as JSON objects keyed by column. It is
+// disabled without API_URL.
+var restAPI struct {
+	URL string
+	// Pagination is page, for page and limit parameters, or cursor, for a
+	// cursor token the previous page returned.
+	Pagination  string
+	PageParam   string
+	LimitParam  string
+	CursorParam string
+	// NextField names the field of the response holding the next cursor,
+	// ItemsField the field holding the rows.
+	NextField  string
+	ItemsField string
+	Token      string
+	// Timeout bounds every page request, response body included.
+	Timeout time.Duration
+}
+
+// loadRestAPI reads the API_* variables.
+func loadRestAPI() error {
+	restAPI.URL = os.Getenv("API_URL")
+	restAPI.Pagination = envOr("API_PAGINATION", "page")
+	restAPI.PageParam = envOr("API_PAGE_PARAM", "page")
+	restAPI.LimitParam = envOr("API_LIMIT_PARAM", "limit")
+	restAPI.CursorParam = envOr("API_CURSOR_PARAM", "cursor")
+	restAPI.NextField = envOr("API_NEXT_FIELD", "next_cursor")
+	restAPI.ItemsField = envOr("API_ITEMS_FIELD", "data")
+	restAPI.Token = os.Getenv("API_TOKEN")
+
+	restAPI.Timeout = 30 * time.Second
+	if t := os.Getenv("API_TIMEOUT"); t != "" {
+		timeout, err := time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("API_TIMEOUT must be a positive duration: %s", t)
+		}
+		restAPI.Timeout = timeout
+	}
+
+	if restAPI.Pagination != "page" && restAPI.Pagination != "cursor" {
+		return fmt.Errorf("API_PAGINATION must be page or cursor, got %s", restAPI.Pagination)
+	}
+	if restAPI.URL != "" {
+		if _, err := url.Parse(restAPI.URL); err != nil {
+			return fmt.Errorf("invalid API_URL: %v", err)
+		}
+	}
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// restPageURL is the URL of a page, counted from 1, or of the page after the
+// cursor token, empty for the first page.
+func restPageURL(page int, cursor string) string {
+	u, _ := url.Parse(restAPI.URL)
+	query := u.Query()
+	query.Set(restAPI.LimitParam, strconv.Itoa(batchSize))
+	if restAPI.Pagination == "page" {
+		query.Set(restAPI.PageParam, strconv.Itoa(page))
+	} else if cursor != "" {
+		query.Set(restAPI.CursorParam, cursor)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// restPage is one page of the REST API.
+type restPage struct {
+	Items []map[string]any
+	Next  string
+}
+
+// fetchRestPage requests a page and decodes its rows, keeping numbers as
+// they were sent.
+func fetchRestPage(ctx context.Context, client *http.Client, pageURL string) (restPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return restPage{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if restAPI.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+restAPI.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return restPage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return restPage{}, fmt.Errorf("GET %s: %s: %s", pageURL, resp.Status, body)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return restPage{}, fmt.Errorf("error decoding page: %v", err)
+	}
+
+	items, ok := body[restAPI.ItemsField]
+	if !ok {
+		return restPage{}, fmt.Errorf("page has no %s field", restAPI.ItemsField)
+	}
+	var page restPage
+	itemDecoder := json.NewDecoder(bytes.NewReader(items))
+	itemDecoder.UseNumber()
+	if err := itemDecoder.Decode(&page.Items); err != nil {
+		return restPage{}, fmt.Errorf("error decoding %s of page: %v", restAPI.ItemsField, err)
+	}
+	if next, ok := body[restAPI.NextField]; ok {
+		// A null or missing cursor ends the pagination
+		_ = json.Unmarshal(next, &page.Next)
+	}
+	return page, nil
+}
+
+// restRecord is the CSV record of a row of the API in the order of the
+// projection, with nested values as JSON, and its key.
+func restRecord(item map[string]any) ([]string, int, error) {
+	record := make([]string, len(projection))
+	for i, column := range projection {
+		switch v := item[column].(type) {
+		case map[string]any, []any:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, 0, err
+			}
+			record[i] = string(encoded)
+		case bool:
+			record[i] = map[bool]string{true: "t", false: "f"}[v]
+		default:
+			record[i] = formatValue(v)
+		}
+	}
+
+	number, ok := item[keyColumn].(json.Number)
+	if !ok {
+		return nil, 0, fmt.Errorf("key column %s must be a number, got %T", keyColumn, item[keyColumn])
+	}
+	key, err := number.Int64()
+	if err != nil {
+		return nil, 0, fmt.Errorf("key column %s must be an integer: %v", keyColumn, err)
+	}
+	return record, int(key), nil
+}
+
+// fetchWithRestAPI paginates the REST API and writes its rows through the
+// same sinks as the database strategies, up to the key limit.
+func fetchWithRestAPI(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "rest_api",
+	}
+
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	sizes := newRowSizeRecorder()
+
+	if err := out.WriteHeader(projection); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	client := &http.Client{Timeout: restAPI.Timeout}
+	var cursor string
+	for page := 1; ; page++ {
+		batchStart := time.Now()
+		_, timings := traceQueries(ctx)
+
+		pageURL := restPageURL(page, cursor)
+		p, err := fetchRestPage(ctx, client, pageURL)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch page: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+		timings.Queries++
+		timings.Query += time.Since(batchStart)
+
+		var count, firstId, lastId int
+		done := len(p.Items) == 0
+		for _, item := range p.Items {
+			record, key, err := restRecord(item)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			// Stop at the same key as the database strategies
+			if key > limit {
+				done = true
+				break
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				firstId = key
+			}
+			lastId = key
+			count++
+		}
+
+		if count > 0 {
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+				Rows:     count,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+		}
+
+		if restAPI.Pagination == "cursor" {
+			done = done || p.Next == ""
+			cursor = p.Next
+		} else {
+			done = done || len(p.Items) < batchSize
+		}
+		if done {
+			break
+		}
+
+		result.Paused += control.Wait(ctx)
+		result.ThinkTime += think.Sleep(ctx)
+	}
+
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareRestAPI compares going through the API with querying the database
+// directly page by page.
+func compareRestAPI(results []Result) (string, bool) {
+	var api, keyset *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "rest_api":
+			api = r
+		case r.Type == "custom_cursor":
+			keyset = r
+		}
+	}
+	if api == nil || keyset == nil {
+		return "", false
+	}
+	return fmt.Sprintf("rest_api took %s for %s rows, %+.1f%% over querying the database with custom_cursor",
+		human.Seconds(api.Duration), human.Int(int64(api.RowSizes.Rows)), 100*(ratio(api.Duration, keyset.Duration)-1)), true
+}
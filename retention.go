@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// retainRuns and retainDays are the retention policy of the run directories
+// in the output directory. A run is kept while it is one of the latest
+// retainRuns runs or started within the last retainDays days, zero leaves a
+// limit out and no limit at all keeps every run.
+var retainRuns, retainDays int
+
+// loadRetention reads RETAIN_RUNS and RETAIN_DAYS.
+func loadRetention() error {
+	for _, setting := range []struct {
+		env   string
+		value *int
+	}{
+		{"RETAIN_RUNS", &retainRuns},
+		{"RETAIN_DAYS", &retainDays},
+	} {
+		s := os.Getenv(setting.env)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive number: %s", setting.env, s)
+		}
+		*setting.value = n
+	}
+	return nil
+}
+
+// retentionEnabled reports whether a retention limit is set.
+func retentionEnabled() bool {
+	return retainRuns > 0 || retainDays > 0
+}
+
+// storedRun is a finished run in the output directory.
+type storedRun struct {
+	name    string
+	started time.Time
+}
+
+// listRuns returns the finished runs in the output directory root, newest
+// first. Directories without a manifest belong to runs that are still going
+// or crashed, and experiments are kept whole, so neither is listed.
+func listRuns(root string) ([]storedRun, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("error listing runs: %v", err)
+	}
+
+	var runs []storedRun
+	for _, entry := range entries {
+		// The latest link is not a directory of its own
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if isExperimentDir(dir) {
+			continue
+		}
+		if started, ok := runStarted(dir); ok {
+			runs = append(runs, storedRun{name: entry.Name(), started: started})
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].started.After(runs[j].started) })
+	return runs, nil
+}
+
+// isExperimentDir reports whether dir holds the runs of an experiment, which
+// have a latest link of their own and end up with experiment.json.
+func isExperimentDir(dir string) bool {
+	for _, name := range []string{"experiment.json", latestLink} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runStarted returns when the run in dir started, from its manifest or, for
+// a run against several targets, the earliest manifest of its targets.
+func runStarted(dir string) (time.Time, bool) {
+	if manifest, err := readManifest(filepath.Join(dir, "manifest.json")); err == nil {
+		return manifest.Started, true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var started time.Time
+	var found bool
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(dir, entry.Name(), "manifest.json"))
+		if err != nil {
+			continue
+		}
+		if !found || manifest.Started.Before(started) {
+			started, found = manifest.Started, true
+		}
+	}
+	return started, found
+}
+
+// expiredRuns returns the runs, newest first, that the retention policy no
+// longer keeps at now.
+func expiredRuns(runs []storedRun, now time.Time) []storedRun {
+	if !retentionEnabled() {
+		return nil
+	}
+
+	var expired []storedRun
+	for i, run := range runs {
+		recent := retainRuns > 0 && i < retainRuns
+		young := retainDays > 0 && now.Sub(run.started) < time.Duration(retainDays)*24*time.Hour
+		if !recent && !young {
+			expired = append(expired, run)
+		}
+	}
+	return expired
+}
+
+// pruneRuns removes the runs in the output directory root that the retention
+// policy no longer keeps, or only lists them with dryRun. The run the latest
+// link points at is always kept, the next run compares its schema to it.
+func pruneRuns(root string, dryRun bool, now time.Time) error {
+	runs, err := listRuns(root)
+	if err != nil {
+		return err
+	}
+	latest, _ := os.Readlink(filepath.Join(root, latestLink))
+
+	var removed int
+	for _, run := range expiredRuns(runs, now) {
+		if run.name == latest {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would remove run %s of %s\n", run.name, run.started.Format(time.DateTime))
+			removed++
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, run.name)); err != nil {
+			return fmt.Errorf("error removing run %s: %v", run.name, err)
+		}
+		fmt.Printf("removed run %s of %s\n", run.name, run.started.Format(time.DateTime))
+		removed++
+	}
+	if removed > 0 {
+		fmt.Printf("%d of %d runs kept\n", len(runs)-removed, len(runs))
+	}
+	return nil
+}
+
+// runGC applies the retention policy to the output directory.
+func runGC(args []string) error {
+	dryRun := len(args) == 1 && args[0] == "-dry-run"
+	if len(args) > 1 || len(args) == 1 && !dryRun {
+		return fmt.Errorf("usage: gc [-dry-run]")
+	}
+	if !retentionEnabled() {
+		return fmt.Errorf("set RETAIN_RUNS or RETAIN_DAYS to the runs to keep")
+	}
+	return pruneRuns(outputRoot, dryRun, time.Now())
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiredRuns(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var runs []storedRun
+	for _, days := range []int{0, 1, 3, 7, 30} {
+		runs = append(runs, storedRun{name: "run" + strconv.Itoa(days), started: now.AddDate(0, 0, -days)})
+	}
+
+	tests := []struct {
+		name       string
+		runs, days int
+		want       []int
+	}{
+		{"no limit", 0, 0, nil},
+		{"runs", 2, 0, []int{2, 3, 4}},
+		{"days", 0, 5, []int{3, 4}},
+		{"either keeps", 4, 2, []int{4}},
+		{"either keeps by days", 1, 5, []int{3, 4}},
+		{"more than there are", 10, 0, nil},
+	}
+	for _, tt := range tests {
+		retainRuns, retainDays = tt.runs, tt.days
+		var got []int
+		for _, run := range expiredRuns(runs, now) {
+			got = append(got, slices.IndexFunc(runs, func(r storedRun) bool { return r.name == run.name }))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expiredRuns = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	retainRuns, retainDays = 0, 0
+}
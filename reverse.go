@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reverseCompare adds custom_cursor_desc, which pages the keys from the limit
+// down, like a latest first feed.
+var reverseCompare bool
+
+// fetchWithReverseKeyset pages like the custom cursor strategy in descending
+// key order and explains a page to check the index is read backwards.
+func fetchWithReverseKeyset(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "custom_cursor_desc",
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	sizes := newRowSizeRecorder()
+
+	if err := out.WriteHeader(projection); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	firstId := limit + 1
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := pool.Query(bctx, reverseKeysetPageQuery(), firstId)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var count, pageFirst int
+		for rows.Next() {
+			record, aid, err := scanRecord(rows)
+			if err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				pageFirst = aid
+			}
+			firstId = aid
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", pageFirst, firstId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+
+		// Emulate the client thinking before it asks for the next page
+		result.ThinkTime += think.Sleep(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+
+	if plan, err := explain(ctx, reverseKeysetPageQuery(), limit/2); err == nil {
+		result.Plan = plan
+	}
+	res <- result
+
+	return nil
+}
+
+// compareReverseKeyset compares the pages of the descending keyset strategy
+// with the ascending ones and flags a plan that sorts instead of reading the
+// index backwards.
+func compareReverseKeyset(results []Result) (string, bool) {
+	var desc, asc *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil || len(r.Batches) == 0:
+		case r.Type == "custom_cursor_desc":
+			desc = r
+		case r.Type == "custom_cursor":
+			asc = r
+		}
+	}
+	if desc == nil {
+		return "", false
+	}
+
+	line := fmt.Sprintf("custom_cursor_desc median page %s", median(batchDurations(desc.Batches)))
+	if asc != nil {
+		descMedian, ascMedian := median(batchDurations(desc.Batches)), median(batchDurations(asc.Batches))
+		line += fmt.Sprintf(", %+.1f%% over custom_cursor ascending %s", 100*(ratio(descMedian, ascMedian)-1), ascMedian)
+	}
+	if p := desc.Plan; p != nil {
+		switch p.IndexOrder() {
+		case "Backward":
+			line += fmt.Sprintf(", reading the index backwards (%s)", p.Nodes())
+		case "Forward":
+			line += fmt.Sprintf(", reading a descending index (%s)", p.Nodes())
+		default:
+			line += fmt.Sprintf(", WITHOUT reading an index in order (%s), add an index on %s or one in descending order", p.Nodes(), keyColumn)
+		}
+	}
+	return line, true
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sessionRole is the role every connection switches to with SET ROLE, so the
+// run is subject to its row level security policies.
+var sessionRole string
+
+// rowSecurity is the row_security setting of every connection, on or off.
+var rowSecurity string
+
+// configureSession makes new connections take on sessionRole, rowSecurity
+// and the session settings.
+func configureSession(config *pgxpool.Config) {
+	if sessionRole == "" && rowSecurity == "" && len(sessionSettings) == 0 {
+		return
+	}
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		return applySession(ctx, conn)
+	}
+}
+
+func applySession(ctx context.Context, conn *pgx.Conn) error {
+	if sessionRole != "" {
+		if _, err := conn.Exec(ctx, "SET ROLE "+pgx.Identifier{sessionRole}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to set role: %w", err)
+		}
+	}
+	if rowSecurity != "" {
+		if _, err := conn.Exec(ctx, "SET row_security = "+rowSecurity); err != nil {
+			return fmt.Errorf("failed to set row_security: %w", err)
+		}
+	}
+	for _, s := range sessionSettings {
+		if _, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", s.Name, s.Value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// PolicyPlans compares the plan of a keyset page under the configured role
+// with its plan as the session user, showing what the policies change.
+type PolicyPlans struct {
+	Role    string
+	Policy  *Plan
+	Session *Plan
+}
+
+// Changed reports whether the policies changed the shape of the plan or its
+// filters.
+func (p *PolicyPlans) Changed() bool {
+	return p.Policy.Nodes() != p.Session.Nodes() || !slices.Equal(p.Policy.Filters(), p.Session.Filters())
+}
+
+// AddedFilters returns the filters only the plan under the role has, which
+// are usually the policy conditions.
+func (p *PolicyPlans) AddedFilters() []string {
+	var added []string
+	for _, f := range p.Policy.Filters() {
+		if !slices.Contains(p.Session.Filters(), f) {
+			added = append(added, f)
+		}
+	}
+	return added
+}
+
+// comparePolicyPlans explains the keyset page halfway through the key range
+// on one connection, first with the role and then with it reset.
+func comparePolicyPlans(ctx context.Context) (*PolicyPlans, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	query, args := keysetPageQuery(selectList()), keysetPageArgs(limit/2)
+
+	policy, err := explainOn(ctx, conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, "RESET ROLE; RESET row_security"); err != nil {
+		return nil, fmt.Errorf("failed to reset role: %w", err)
+	}
+	session, err := explainOn(ctx, conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hand the connection back to the pool the way strategies expect it
+	if err := applySession(ctx, conn.Conn()); err != nil {
+		return nil, err
+	}
+
+	return &PolicyPlans{Role: sessionRole, Policy: policy, Session: session}, nil
+}
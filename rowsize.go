@@ -0,0 +1,71 @@
+package main
+
+import "sort"
+
+// RowSizes summarizes the encoded size of the rows a strategy exported.
+type RowSizes struct {
+	Rows  int
+	Bytes int64
+	Mean  float64
+	P50   int
+	P95   int
+	P99   int
+}
+
+// rowSizeRecorder keeps a histogram of encoded row sizes, which stays small
+// because rows of a table tend to cluster around a handful of sizes.
+type rowSizeRecorder struct {
+	counts map[int]int
+	rows   int
+	bytes  int64
+}
+
+func newRowSizeRecorder() *rowSizeRecorder {
+	return &rowSizeRecorder{counts: make(map[int]int)}
+}
+
+func (r *rowSizeRecorder) Add(size int) {
+	r.counts[size]++
+	r.rows++
+	r.bytes += int64(size)
+}
+
+// merge adds the sizes recorded by other.
+func (r *rowSizeRecorder) merge(other *rowSizeRecorder) {
+	for size, count := range other.counts {
+		r.counts[size] += count
+	}
+	r.rows += other.rows
+	r.bytes += other.bytes
+}
+
+func (r *rowSizeRecorder) Summary() RowSizes {
+	s := RowSizes{Rows: r.rows, Bytes: r.bytes}
+	if r.rows == 0 {
+		return s
+	}
+	s.Mean = float64(r.bytes) / float64(r.rows)
+
+	sizes := make([]int, 0, len(r.counts))
+	for size := range r.counts {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	rank := func(p float64) int {
+		target := int(p / 100 * float64(r.rows))
+		var seen int
+		for _, size := range sizes {
+			seen += r.counts[size]
+			if seen > target {
+				return size
+			}
+		}
+		return sizes[len(sizes)-1]
+	}
+	s.P50 = rank(50)
+	s.P95 = rank(95)
+	s.P99 = rank(99)
+
+	return s
+}
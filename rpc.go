@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The rpc subcommand serves JSON-RPC 2.0 over stdio, one request per line on
+// stdin and one response per line on stdout, so other languages can drive
+// runs without parsing the human output, which goes to stderr meanwhile.
+// Requests are handled one at a time, since runs share the pool and the
+// output directory.
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcRunFailed      = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RPCStrategy is a strategy in the result of the strategies method.
+type RPCStrategy struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Summary string `json:"summary"`
+}
+
+// RPCRunParams are the parameters of the run method. Empty strategies run
+// every enabled strategy, as without STRATEGIES.
+type RPCRunParams struct {
+	Strategies []string `json:"strategies,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+}
+
+// RPCRunResult is the result of the run method, the results as saved in the
+// manifest of the run's directory.
+type RPCRunResult struct {
+	Directory string           `json:"directory"`
+	Results   []ManifestResult `json:"results"`
+}
+
+// rpcConn writes the responses and the progress notifications, which the
+// strategies send concurrently, one line at a time.
+type rpcConn struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (c *rpcConn) send(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.out.Write(append(line, '\n'))
+	return err
+}
+
+// Write wraps every line of the progress stream into a progress
+// notification.
+func (c *rpcConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.out, `{"jsonrpc":"2.0","method":"progress","params":%s}`+"\n", bytes.TrimSpace(p))
+	return len(p), err
+}
+
+// serveRPC handles the requests on in until it is closed or a shutdown
+// request arrives.
+func serveRPC(ctx context.Context, in io.Reader, out io.Writer) error {
+	if len(targets) > 0 || len(ioVariants) > 0 {
+		return fmt.Errorf("rpc runs against a single database, unset TARGETS and IO_SETTINGS")
+	}
+
+	conn := &rpcConn{out: out}
+	progress.open(conn)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := conn.send(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{Code: rpcParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, rerr := handleRPC(ctx, req)
+
+		// Notifications get no response
+		if req.ID == nil {
+			if req.Method == "shutdown" {
+				return nil
+			}
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr}
+		if err := conn.send(resp); err != nil {
+			return err
+		}
+		if req.Method == "shutdown" {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// handleRPC runs the method of a request.
+func handleRPC(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "expected a JSON-RPC 2.0 request with a method"}
+	}
+
+	switch req.Method {
+	case "strategies":
+		var list []RPCStrategy
+		for _, s := range registeredStrategies() {
+			list = append(list, RPCStrategy{Name: s.name, Enabled: s.enabled, Summary: s.doc.Summary})
+		}
+		return list, nil
+
+	case "run":
+		var params RPCRunParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		if err := validateRunParams(ctx, params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		result, err := rpcRun(ctx, params)
+		if err != nil {
+			return nil, &rpcError{Code: rpcRunFailed, Message: err.Error()}
+		}
+		return result, nil
+
+	case "shutdown":
+		return true, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method}
+}
+
+// rpcRun runs the benchmark into a run directory of its own, like a run from
+// the command line.
+func rpcRun(ctx context.Context, params RPCRunParams) (*RPCRunResult, error) {
+	base := outputDir
+	defer func() { outputDir = base }()
+
+	// runBenchmark reads these from the environment
+	for env, value := range map[string]string{
+		"STRATEGIES": strings.Join(params.Strategies, ","),
+		"RUN_NAME":   params.Name,
+		"RUN_NOTES":  params.Notes,
+	} {
+		previous, ok := os.LookupEnv(env)
+		os.Setenv(env, value)
+		if ok {
+			defer os.Setenv(env, previous)
+		} else {
+			defer os.Unsetenv(env)
+		}
+	}
+
+	// A run that would overwrite a previous one must not end the server
+	started := time.Now()
+	name := params.Name
+	if name == "" {
+		name = started.Format("20060102-150405.000")
+	}
+	if err := checkOverwrite([]string{filepath.Join(outputPath(name), "manifest.json")}); err != nil {
+		return nil, err
+	}
+
+	dir, err := createRunDirectory(name, started)
+	if err != nil {
+		return nil, err
+	}
+	outputDir, runDir = dir, dir
+
+	results, err := runBenchmark(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &RPCRunResult{Directory: dir, Results: []ManifestResult{}}
+	for _, r := range results {
+		result.Results = append(result.Results, newManifestResult(r))
+	}
+	return result, nil
+}
+
+// validateRunParams checks the params of the run method before anything
+// runs, so a mistake in them is an invalid params error rather than a failed
+// run.
+func validateRunParams(ctx context.Context, params RPCRunParams) error {
+	if strings.ContainsAny(params.Name, `/\`) {
+		return fmt.Errorf("name %q must not contain path separators", params.Name)
+	}
+	if len(params.Strategies) == 0 {
+		return nil
+	}
+
+	// Which strategies are enabled depends on the server
+	if c, err := detectCapabilities(ctx); err == nil {
+		server = c
+	}
+	var enabled []strategy
+	for _, s := range registeredStrategies() {
+		if s.enabled {
+			enabled = append(enabled, s)
+		}
+	}
+	_, err := selectStrategies(enabled, params.Strategies)
+	return err
+}
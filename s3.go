@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores the exports given an s3://bucket/key location. There is no SDK
+// behind it, only the few requests the exports need, signed with AWS
+// Signature Version 4 from the usual AWS_* variables. S3_ENDPOINT points it
+// at an S3 compatible store such as MinIO instead of AWS.
+
+const (
+	// s3PartSize is the size of the parts of a multipart upload.
+	s3PartSize = 8 << 20
+	// s3MinPartSize is the least S3 takes for every part but the last.
+	s3MinPartSize = 5 << 20
+	// s3Timeout bounds every request, an upload of a part included.
+	s3Timeout = 5 * time.Minute
+)
+
+type s3Client struct {
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+	http      *http.Client
+}
+
+// newS3Client reads the credentials and region of the AWS_* variables and the
+// endpoint of S3_ENDPOINT, https://s3.<region>.amazonaws.com by default.
+// Objects are addressed by path, endpoint/bucket/key, which S3 compatible
+// stores accept too.
+func newS3Client() (*s3Client, error) {
+	c := &s3Client{
+		region:    envOr("AWS_REGION", envOr("AWS_DEFAULT_REGION", "us-east-1")),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		http:      &http.Client{Timeout: s3Timeout},
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, errors.New("S3 output needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	c.endpoint = strings.TrimSuffix(envOr("S3_ENDPOINT", "https://s3."+c.region+".amazonaws.com"), "/")
+	return c, nil
+}
+
+// isS3URL reports whether the output location is in S3 rather than local.
+func isS3URL(s string) bool {
+	return strings.HasPrefix(s, "s3://")
+}
+
+// parseS3URL splits s3://bucket/key into the bucket and the key.
+func parseS3URL(s string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(s, "s3://"), "/")
+	if !isS3URL(s) || bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 location %q, want s3://bucket/key", s)
+	}
+	return bucket, key, nil
+}
+
+// s3Part is an uploaded part of a multipart upload.
+type s3Part struct {
+	Number int    `xml:"PartNumber" json:"number"`
+	ETag   string `xml:"ETag" json:"etag"`
+}
+
+// do sends a signed request for the object and returns the response of a
+// successful one, which the caller closes.
+func (c *s3Client) do(ctx context.Context, method, bucket, key string, query map[string]string, body []byte, header http.Header) (*http.Response, error) {
+	target := c.endpoint + "/" + s3Escape(bucket, false) + "/" + s3Escape(key, false)
+	if q := s3Query(query); q != "" {
+		target += "?" + q
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	hash := sha256.Sum256(body)
+	c.sign(req, hex.EncodeToString(hash[:]), time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp, fmt.Errorf("%s s3://%s/%s: %s: %s", method, bucket, key, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// sign signs the request with AWS Signature Version 4, covering the host and
+// every header set on the request.
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{date, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, and
+// slashes unless encodeSlash is set, the way Signature Version 4 does.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query encodes the query sorted by name, the canonical form of Signature
+// Version 4, so the URL and the signature agree.
+func s3Query(query map[string]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = s3Escape(name, true) + "=" + s3Escape(query[name], true)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// exists reports whether there is an object at the key.
+func (c *s3Client) exists(ctx context.Context, bucket, key string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+func (c *s3Client) put(ctx context.Context, bucket, key string, body []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, body, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *s3Client) createUpload(ctx context.Context, bucket, key string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, map[string]string{"uploads": ""}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("error decoding multipart upload of s3://%s/%s: %w", bucket, key, err)
+	}
+	return created.UploadID, nil
+}
+
+func (c *s3Client) uploadPart(ctx context.Context, bucket, key, uploadID string, number int, body []byte) (s3Part, error) {
+	query := map[string]string{"partNumber": fmt.Sprint(number), "uploadId": uploadID}
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, query, body, nil)
+	if err != nil {
+		return s3Part{}, err
+	}
+	resp.Body.Close()
+	return s3Part{Number: number, ETag: resp.Header.Get("ETag")}, nil
+}
+
+func (c *s3Client) completeUpload(ctx context.Context, bucket, key, uploadID string, parts []s3Part) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/xml"}}
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, map[string]string{"uploadId": uploadID}, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A failed completion may still answer 200 with an error document
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var failed struct {
+		XMLName xml.Name `xml:"Error"`
+		Message string   `xml:"Message"`
+	}
+	if xml.Unmarshal(content, &failed) == nil {
+		return fmt.Errorf("error completing upload of s3://%s/%s: %s", bucket, key, failed.Message)
+	}
+	return nil
+}
+
+func (c *s3Client) abortUpload(ctx context.Context, bucket, key, uploadID string) error {
+	resp, err := c.do(ctx, http.MethodDelete, bucket, key, map[string]string{"uploadId": uploadID}, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// s3Upload writes an object in parts as the output comes in. An object
+// smaller than a part is stored with a single PUT when completed, a larger
+// one with a multipart upload, which S3 only assembles into the object once
+// completed, so an interrupted upload never leaves a partial object behind.
+type s3Upload struct {
+	client *s3Client
+	ctx    context.Context
+	bucket string
+	key    string
+
+	// UploadID and Parts are the multipart upload, once it has started.
+	UploadID string   `json:"upload_id"`
+	Parts    []s3Part `json:"parts"`
+	// Uploaded is the size of the parts.
+	Uploaded int64 `json:"uploaded"`
+
+	buf []byte
+}
+
+func newS3Upload(ctx context.Context, client *s3Client, bucket, key string) *s3Upload {
+	return &s3Upload{client: client, ctx: ctx, bucket: bucket, key: key}
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	u.buf = append(u.buf, p...)
+	for len(u.buf) >= s3PartSize {
+		if err := u.uploadPart(u.buf[:s3PartSize]); err != nil {
+			return 0, err
+		}
+		u.buf = append(u.buf[:0], u.buf[s3PartSize:]...)
+	}
+	return len(p), nil
+}
+
+// Buffered is the output not uploaded yet.
+func (u *s3Upload) Buffered() int {
+	return len(u.buf)
+}
+
+// FlushPart uploads the buffered output as a part if it is large enough to be
+// one, and reports whether it did. Once it did, everything written so far is
+// stored with the upload.
+func (u *s3Upload) FlushPart() (bool, error) {
+	if len(u.buf) < s3MinPartSize {
+		return false, nil
+	}
+	if err := u.uploadPart(u.buf); err != nil {
+		return false, err
+	}
+	u.buf = u.buf[:0]
+	return true, nil
+}
+
+func (u *s3Upload) uploadPart(body []byte) error {
+	if u.UploadID == "" {
+		id, err := u.client.createUpload(u.ctx, u.bucket, u.key)
+		if err != nil {
+			return err
+		}
+		u.UploadID = id
+	}
+	part, err := u.client.uploadPart(u.ctx, u.bucket, u.key, u.UploadID, len(u.Parts)+1, body)
+	if err != nil {
+		return err
+	}
+	u.Parts = append(u.Parts, part)
+	u.Uploaded += int64(len(body))
+	return nil
+}
+
+// Complete stores the object.
+func (u *s3Upload) Complete() error {
+	if u.UploadID == "" {
+		return u.client.put(u.ctx, u.bucket, u.key, u.buf)
+	}
+	if len(u.buf) > 0 {
+		if err := u.uploadPart(u.buf); err != nil {
+			return err
+		}
+		u.buf = u.buf[:0]
+	}
+	if err := u.client.completeUpload(u.ctx, u.bucket, u.key, u.UploadID, u.Parts); err != nil {
+		return err
+	}
+	u.UploadID = ""
+	return nil
+}
+
+// Abort drops the parts of an upload that was not completed.
+func (u *s3Upload) Abort() error {
+	if u.UploadID == "" {
+		return nil
+	}
+	// The context of the upload may be what was canceled
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	err := u.client.abortUpload(ctx, u.bucket, u.key, u.UploadID)
+	u.UploadID = ""
+	return err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// keyTables are the tables of the key type scenario. They hold the same rows,
+// keyed by a bigserial, a random UUIDv4, a time-ordered UUIDv7 and the
+// non-numeric types real schemas often use for keys.
+var keyTables = []struct {
+	label   string
+	name    string
+	keyType string
+	key     string
+	// setup creates what the key type needs before the table is created.
+	setup []string
+}{
+	{"bigserial", "bench_keys_bigserial", "bigint", "g", nil},
+	{"uuidv4", "bench_keys_uuidv4", "uuid", "gen_random_uuid()", nil},
+	// UUIDv7 as laid out in RFC 9562, with a timestamp one millisecond apart
+	// per row to emulate rows inserted over time
+	{"uuidv7", "bench_keys_uuidv7", "uuid", `encode(set_bit(set_bit(overlay(uuid_send(gen_random_uuid())
+		placing substring(int8send(floor(extract(epoch FROM now()) * 1000)::bigint + g) FROM 3)
+		FROM 1 FOR 6), 52, 1), 53, 1), 'hex')::uuid`, nil},
+	// Case-insensitive keys in mixed case, like e-mail addresses
+	{"citext", "bench_keys_citext", "citext", "CASE WHEN g % 2 = 0 THEN upper(md5(g::text)) ELSE md5(g::text) END", []string{
+		"CREATE EXTENSION IF NOT EXISTS citext",
+	}},
+	// A status enum of a few labels, which sort by their position in the
+	// type, not alphabetically. Labels repeat, so the key pairs the status
+	// with a sequence number in a composite type to stay unique.
+	{"enum", "bench_keys_enum", "bench_key_enum_key", "ROW((enum_range(NULL::bench_key_enum))[1 + g % 5], g)::bench_key_enum_key", []string{
+		"DROP TYPE IF EXISTS bench_key_enum_key",
+		"DROP TYPE IF EXISTS bench_key_enum",
+		"CREATE TYPE bench_key_enum AS ENUM ('pending', 'active', 'suspended', 'closed', 'archived')",
+		"CREATE TYPE bench_key_enum_key AS (status bench_key_enum, seq bigint)",
+	}},
+	{"domain", "bench_keys_domain", "bench_key_domain", "g", []string{
+		"DROP DOMAIN IF EXISTS bench_key_domain",
+		"CREATE DOMAIN bench_key_domain AS bigint CHECK (VALUE > 0)",
+	}},
+}
+
+// seedKeyTables creates the key type scenario tables with rows rows each and
+// prints how well each primary key index correlates with the heap order.
+func seedKeyTables(ctx context.Context, rows int) error {
+	start := time.Now()
+
+	for _, table := range keyTables {
+		statements := []string{fmt.Sprintf("DROP TABLE IF EXISTS %s", table.name)}
+		statements = append(statements, table.setup...)
+		statements = append(statements,
+			fmt.Sprintf("CREATE TABLE %s (id %s PRIMARY KEY, abalance int, filler char(84))", table.name, table.keyType),
+			fmt.Sprintf(`
+				INSERT INTO %s (id, abalance, filler)
+				SELECT %s, 0, ''
+				FROM generate_series(1, %d) g`, table.name, table.key, rows),
+			fmt.Sprintf("VACUUM ANALYZE %s", table.name),
+		)
+		for _, statement := range statements {
+			if _, err := pool.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("failed to seed %s: %w", table.name, err)
+			}
+		}
+
+		var indexSize string
+		var correlation float64
+		err := pool.QueryRow(ctx, `
+			SELECT pg_size_pretty(pg_relation_size($1::regclass)), s.correlation
+			FROM pg_stats s
+			WHERE s.tablename = $2 AND s.attname = 'id'`,
+			table.name+"_pkey", table.name).Scan(&indexSize, &correlation)
+		if err != nil {
+			return fmt.Errorf("failed to read index stats of %s: %w", table.name, err)
+		}
+		fmt.Printf("%s: primary key index %s, heap correlation %.3f\n", table.name, indexSize, correlation)
+	}
+
+	fmt.Printf("seeded %d rows per key type in %.2f second\n", rows, time.Since(start).Seconds())
+	return nil
+}
+
+// fetchKeyTable returns a keyset strategy over one key type scenario table.
+func fetchKeyTable(label, table, keyType string) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: "keyset_" + label,
+		}
+
+		// Open the sink the rows are written to
+		out, err := openSink(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		header := []string{"id", "abalance"}
+		if err := out.WriteHeader(header); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		sizes := newRowSizeRecorder()
+
+		// Keys are compared in their own type but carried around as text
+		var lastId string
+		for {
+			batchStart := time.Now()
+			bctx, timings := traceQueries(ctx)
+
+			// The first page has no lower bound
+			first := len(result.Batches) == 0
+			var args []any
+			if !first {
+				args = append(args, lastId)
+			}
+
+			rows, err := pool.Query(bctx, keyTablePageQuery(table, keyType, first), args...)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch data: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			var count int
+			var firstId string
+			for rows.Next() {
+				var id string
+				var abalance int
+
+				if err := rows.Scan(&id, &abalance); err != nil {
+					err = fmt.Errorf("failed to scan row: %w", err)
+					result.Err = err
+					res <- result
+					return err
+				}
+
+				n, err := timings.WriteRow(out, []string{id, fmt.Sprintf("%d", abalance)})
+				if err != nil {
+					result.Err = err
+					res <- result
+					return err
+				}
+				sizes.Add(n)
+
+				if count == 0 {
+					firstId = id
+				}
+				lastId = id
+				count++
+			}
+
+			rows.Close()
+
+			if rows.Err() != nil {
+				err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Check if there are no more rows
+			if count == 0 {
+				break
+			}
+
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("id %s..%s", firstId, lastId),
+				Rows:     count,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+
+			// Hold still while the run is paused
+			result.Paused += control.Wait(ctx)
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.RowSizes = sizes.Summary()
+		result.Writes = out.Stats()
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
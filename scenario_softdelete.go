@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const softDeleteTable = "bench_soft_delete"
+
+// softDeleteRate is the number of rows per second the soft delete scenario
+// soft-deletes while its strategies run. Zero leaves the table alone.
+var softDeleteRate = 100
+
+// seedSoftDelete creates the soft delete scenario table, with a tenth of its
+// rows already soft-deleted and a partial index over the live rows.
+func seedSoftDelete(ctx context.Context, rows int) error {
+	start := time.Now()
+
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", softDeleteTable),
+		fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, balance int NOT NULL, deleted_at timestamptz)", softDeleteTable),
+		fmt.Sprintf(`
+			INSERT INTO %s (id, balance, deleted_at)
+			SELECT g, 0, CASE WHEN random() < 0.1 THEN now() - interval '1 day' END
+			FROM generate_series(1, %d) g`, softDeleteTable, rows),
+		fmt.Sprintf("CREATE INDEX %s_live ON %s (id) WHERE deleted_at IS NULL", softDeleteTable, softDeleteTable),
+		fmt.Sprintf("VACUUM ANALYZE %s", softDeleteTable),
+	}
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", softDeleteTable, err)
+		}
+	}
+
+	var live int
+	if err := pool.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE deleted_at IS NULL", softDeleteTable)).Scan(&live); err != nil {
+		return fmt.Errorf("failed to count rows of %s: %w", softDeleteTable, err)
+	}
+	fmt.Printf("seeded %d rows into %s, %d of them live, in %.2f second\n",
+		rows, softDeleteTable, live, time.Since(start).Seconds())
+	return nil
+}
+
+// SoftDeleteCheck is how consistently a strategy handled the rows that were
+// soft-deleted while it ran.
+type SoftDeleteCheck struct {
+	Rows int
+	// Repeated rows were exported more than once.
+	Repeated int
+	// Missed rows were live the whole run but not exported.
+	Missed int
+	// Stale rows were deleted before the run but exported.
+	Stale int
+	// DeletedDuring rows were deleted mid-run, of which Exported made it
+	// into the export, which is correct either way.
+	DeletedDuring int
+	Exported      int
+
+	seen map[int64]int
+}
+
+// softDeleter soft-deletes random live rows at softDeleteRate while the
+// strategies of the scenario run.
+type softDeleter struct {
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan error
+	mu      sync.Mutex
+	deleted int
+}
+
+// startSoftDeleter starts soft-deleting rows, remembering the server time it
+// started at, which tells rows deleted mid-run from those deleted before.
+func startSoftDeleter(ctx context.Context) (*softDeleter, error) {
+	d := &softDeleter{done: make(chan error, 1)}
+	if err := pool.QueryRow(ctx, "SELECT now()").Scan(&d.started); err != nil {
+		return nil, fmt.Errorf("failed to read server time: %w", err)
+	}
+
+	var rows int
+	if err := pool.QueryRow(ctx, fmt.Sprintf("SELECT COALESCE(max(id), 0) FROM %s", softDeleteTable)).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to read size of %s: %w", softDeleteTable, err)
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	d.cancel = cancel
+	if softDeleteRate <= 0 || rows == 0 {
+		close(d.done)
+		return d, nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(softDeleteRate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wctx.Done():
+				d.done <- nil
+				return
+			case <-ticker.C:
+			}
+
+			id := rand.Int64N(int64(rows)) + 1
+			tag, err := pool.Exec(wctx, softDeleteQuery(), id)
+			if err != nil {
+				if wctx.Err() != nil {
+					d.done <- nil
+					return
+				}
+				d.done <- fmt.Errorf("failed to soft-delete row: %w", err)
+				return
+			}
+			d.mu.Lock()
+			d.deleted += int(tag.RowsAffected())
+			d.mu.Unlock()
+		}
+	}()
+	return d, nil
+}
+
+// Stop stops deleting and returns the number of rows deleted.
+func (d *softDeleter) Stop() (int, error) {
+	d.cancel()
+	err := <-d.done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.deleted, err
+}
+
+// checkSoftDeletes classifies the rows of the table by when they were
+// deleted and checks the exports of the scenario's strategies against them.
+func checkSoftDeletes(ctx context.Context, started time.Time, results []Result) error {
+	rows, err := pool.Query(ctx, fmt.Sprintf("SELECT id, deleted_at FROM %s", softDeleteTable))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", softDeleteTable, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var deletedAt []*time.Time
+	for rows.Next() {
+		var id int64
+		var deleted *time.Time
+		if err := rows.Scan(&id, &deleted); err != nil {
+			return fmt.Errorf("failed to read %s: %w", softDeleteTable, err)
+		}
+		ids = append(ids, id)
+		deletedAt = append(deletedAt, deleted)
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("failed to read %s: %w", softDeleteTable, rows.Err())
+	}
+
+	for _, result := range results {
+		c := result.SoftDelete
+		if c == nil || result.Err != nil {
+			continue
+		}
+		for _, n := range c.seen {
+			if n > 1 {
+				c.Repeated++
+			}
+		}
+		for i, id := range ids {
+			n := c.seen[id]
+			switch deleted := deletedAt[i]; {
+			case deleted == nil:
+				if n == 0 {
+					c.Missed++
+				}
+			case deleted.Before(started):
+				if n > 0 {
+					c.Stale++
+				}
+			default:
+				c.DeletedDuring++
+				if n > 0 {
+					c.Exported++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// softFetcher fetches the pages of a soft delete scenario strategy, given
+// the page number and the last id of the previous page.
+type softFetcher func(ctx context.Context, page int, lastId int64) (pgx.Rows, error)
+
+// softOpener starts a soft delete scenario strategy. It returns the fetcher,
+// done, which completes the export once every page was read, and release,
+// which undoes what done did not complete and is deferred.
+type softOpener func(ctx context.Context) (fetch softFetcher, done func() error, release func(), err error)
+
+// fetchSoftKeyset pages through the live rows after the last id of the
+// previous page, using the partial index.
+func fetchSoftKeyset(ctx context.Context, res chan<- Result) error {
+	return fetchSoftPages(ctx, res, "soft_keyset", func(ctx context.Context) (softFetcher, func() error, func(), error) {
+		fetch := func(ctx context.Context, page int, lastId int64) (pgx.Rows, error) {
+			return pool.Query(ctx, softPageQuery(), lastId)
+		}
+		return fetch, func() error { return nil }, func() {}, nil
+	})
+}
+
+// fetchSoftOffset pages through the live rows with OFFSET, which shifts when
+// rows behind the current page are deleted.
+func fetchSoftOffset(ctx context.Context, res chan<- Result) error {
+	return fetchSoftPages(ctx, res, "soft_offset", func(ctx context.Context) (softFetcher, func() error, func(), error) {
+		fetch := func(ctx context.Context, page int, lastId int64) (pgx.Rows, error) {
+			return pool.Query(ctx, softOffsetQuery(), page*batchSize)
+		}
+		return fetch, func() error { return nil }, func() {}, nil
+	})
+}
+
+// fetchSoftCursor fetches the live rows from a cursor, which sees them as of
+// the snapshot of its transaction.
+func fetchSoftCursor(ctx context.Context, res chan<- Result) error {
+	return fetchSoftPages(ctx, res, "soft_cursor", func(ctx context.Context) (softFetcher, func() error, func(), error) {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.Exec(ctx, declareSoftCursorQuery("soft_cursor")); err != nil {
+			tx.Rollback(ctx)
+			return nil, nil, nil, fmt.Errorf("failed to declare cursor: %w", err)
+		}
+		fetch := func(ctx context.Context, page int, lastId int64) (pgx.Rows, error) {
+			return tx.Query(ctx, fetchCursorQuery("soft_cursor"))
+		}
+		done := func() error {
+			if err := tx.Commit(ctx); err != nil {
+				return fmt.Errorf("failed to commit transaction: %w", err)
+			}
+			return nil
+		}
+		// Rolling back a committed transaction does nothing
+		release := func() { tx.Rollback(ctx) }
+		return fetch, done, release, nil
+	})
+}
+
+// fetchSoftPages exports the live rows of the soft delete scenario with the
+// fetcher that open returns, calling done once every page was read. The
+// exported ids are kept for the consistency check.
+func fetchSoftPages(ctx context.Context, res chan<- Result, name string, open softOpener) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:       name,
+		SoftDelete: &SoftDeleteCheck{seen: make(map[int64]int)},
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := []string{"id", "balance"}
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	fetch, done, release, err := open(ctx)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer release()
+
+	var lastId int64
+	for page := 0; ; page++ {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := fetch(bctx, page, lastId)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var count int
+		var firstId int64
+		for rows.Next() {
+			var id int64
+			var balance int
+			if err := rows.Scan(&id, &balance); err != nil {
+				rows.Close()
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			n, err := timings.WriteRow(out, []string{fmt.Sprintf("%d", id), fmt.Sprintf("%d", balance)})
+			if err != nil {
+				rows.Close()
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+			result.SoftDelete.seen[id]++
+
+			if count == 0 {
+				firstId = id
+			}
+			lastId = id
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("id %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	if err := done(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.SoftDelete.Rows = sizes.Summary().Rows
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const tenantTable = "bench_tenants"
+
+// tenantCount is the number of tenants the tenant scenario seeds.
+var tenantCount = 100
+
+// tenantID is the tenant the tenant scenario lists. Zero lists the largest
+// one.
+var tenantID int
+
+// seedTenants creates the tenant scenario table, keyed by (tenant_id, id)
+// like the tables behind SaaS listing endpoints. Tenants get skewed shares
+// of the rows, the first ones the most.
+func seedTenants(ctx context.Context, rows int) error {
+	start := time.Now()
+
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tenantTable),
+		fmt.Sprintf(`CREATE TABLE %s (
+			tenant_id int NOT NULL,
+			id bigint NOT NULL,
+			balance int NOT NULL,
+			created_at timestamptz NOT NULL,
+			PRIMARY KEY (tenant_id, id)
+		)`, tenantTable),
+		fmt.Sprintf(`
+			INSERT INTO %s (tenant_id, id, balance, created_at)
+			SELECT 1 + floor(power(random(), 3) * %d)::int, g, (random() * 10000)::int - 5000,
+				now() - (%d - g) * interval '1 second'
+			FROM generate_series(1, %d) g`, tenantTable, tenantCount, rows, rows),
+		fmt.Sprintf("VACUUM ANALYZE %s", tenantTable),
+	}
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", tenantTable, err)
+		}
+	}
+
+	var largest, largestRows, smallestRows int
+	err := pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT (array_agg(tenant_id ORDER BY n DESC))[1], max(n), min(n)
+		FROM (SELECT tenant_id, count(*) AS n FROM %s GROUP BY tenant_id) t`, tenantTable)).Scan(&largest, &largestRows, &smallestRows)
+	if err != nil {
+		return fmt.Errorf("failed to read tenants of %s: %w", tenantTable, err)
+	}
+	fmt.Printf("seeded %d rows of %d tenants into %s, tenant %d is the largest with %d rows, the smallest has %d, in %.2f second\n",
+		rows, tenantCount, tenantTable, largest, largestRows, smallestRows, time.Since(start).Seconds())
+	return nil
+}
+
+// tenantToList returns TENANT_ID, or else the tenant with the most rows.
+func tenantToList(ctx context.Context) (int, error) {
+	if tenantID != 0 {
+		return tenantID, nil
+	}
+	var tenant int
+	if err := pool.QueryRow(ctx, largestTenantQuery()).Scan(&tenant); err != nil {
+		return 0, fmt.Errorf("failed to find the largest tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+// fetchTenantKeyset lists the rows of one tenant with keyset pagination on
+// the composite key, the way a listing endpoint pages with a cursor token.
+func fetchTenantKeyset(ctx context.Context, res chan<- Result) error {
+	return fetchTenantPages(ctx, res, "tenant_keyset", func(page int, lastId int64) (string, []any) {
+		if page == 0 {
+			return tenantPageQuery(true), nil
+		}
+		return tenantPageQuery(false), []any{lastId}
+	})
+}
+
+// fetchTenantOffset lists the rows of one tenant with OFFSET, the way a
+// listing endpoint pages with page numbers.
+func fetchTenantOffset(ctx context.Context, res chan<- Result) error {
+	return fetchTenantPages(ctx, res, "tenant_offset", func(page int, lastId int64) (string, []any) {
+		return tenantOffsetQuery(), []any{page * batchSize}
+	})
+}
+
+// fetchTenantPages runs one query per page of a tenant's rows. The tenant is
+// bound to $1, further arguments follow it.
+func fetchTenantPages(ctx context.Context, res chan<- Result, name string, pageQuery func(page int, lastId int64) (string, []any)) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: name,
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := []string{"tenant_id", "id", "balance", "created_at"}
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	tenant, err := tenantToList(ctx)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	var lastId int64
+	for page := 0; ; page++ {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		query, args := pageQuery(page, lastId)
+		rows, err := pool.Query(bctx, query, append([]any{tenant}, args...)...)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		var count int
+		var firstId int64
+		for rows.Next() {
+			var tenantID, balance int
+			var id int64
+			var createdAt time.Time
+			if err := rows.Scan(&tenantID, &id, &balance, &createdAt); err != nil {
+				err = fmt.Errorf("failed to scan row: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			record := []string{
+				fmt.Sprintf("%d", tenantID),
+				fmt.Sprintf("%d", id),
+				fmt.Sprintf("%d", balance),
+				createdAt.Format(time.RFC3339Nano),
+			}
+			n, err := timings.WriteRow(out, record)
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			sizes.Add(n)
+
+			if count == 0 {
+				firstId = id
+			}
+			lastId = id
+			count++
+		}
+
+		rows.Close()
+
+		if rows.Err() != nil {
+			err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("tenant %d id %d..%d", tenant, firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareTenants compares the last page of the tenant listing strategies,
+// where OFFSET has to skip the most rows.
+func compareTenants(results []Result) (string, bool) {
+	var keyset, offset *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil || len(r.Batches) == 0:
+		case r.Type == "tenant_keyset":
+			keyset = r
+		case r.Type == "tenant_offset":
+			offset = r
+		}
+	}
+	if keyset == nil || offset == nil {
+		return "", false
+	}
+	return fmt.Sprintf("listing %d rows in %d pages took tenant_keyset %.2fs and tenant_offset %.2fs, the last page %.1fms and %.1fms",
+		keyset.RowSizes.Rows, len(keyset.Batches), keyset.Duration.Seconds(), offset.Duration.Seconds(),
+		float64(keyset.Batches[len(keyset.Batches)-1].Duration)/float64(time.Millisecond),
+		float64(offset.Batches[len(offset.Batches)-1].Duration)/float64(time.Millisecond)), true
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const toastTable = "bench_toast"
+
+// toastSize is the size in bytes of the text values the TOAST scenario seeds.
+var toastSize = 8192
+
+// seedToast creates the TOAST scenario table whose doc column holds random
+// text of toastSize bytes per row, large enough to be moved out of line into
+// the TOAST table.
+func seedToast(ctx context.Context, rows int) error {
+	start := time.Now()
+
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", toastTable),
+		fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, abalance int, doc text)", toastTable),
+		fmt.Sprintf(`
+			INSERT INTO %s (id, abalance, doc)
+			SELECT g, 0, substring(r.doc FROM 1 FOR %d)
+			FROM generate_series(1, %d) g,
+			LATERAL (
+				SELECT string_agg(md5(random()::text || g || i), '') AS doc
+				FROM generate_series(1, %d) i
+			) r`, toastTable, toastSize, rows, (toastSize+31)/32),
+		fmt.Sprintf("VACUUM ANALYZE %s", toastTable),
+	}
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", toastTable, err)
+		}
+	}
+
+	var heap, toast string
+	err := pool.QueryRow(ctx, `
+		SELECT pg_size_pretty(pg_relation_size(c.oid)), pg_size_pretty(pg_relation_size(c.reltoastrelid))
+		FROM pg_class c
+		WHERE c.oid = $1::regclass`, toastTable).Scan(&heap, &toast)
+	if err != nil {
+		return fmt.Errorf("failed to read size of %s: %w", toastTable, err)
+	}
+	fmt.Printf("seeded %d rows of %d byte docs into %s, heap %s, TOAST %s, in %.2f second\n",
+		rows, toastSize, toastTable, heap, toast, time.Since(start).Seconds())
+	return nil
+}
+
+// toastColumns are the columns a TOAST scenario strategy exports, with or
+// without the TOASTed doc.
+func toastColumns(withDoc bool) []string {
+	if withDoc {
+		return []string{"id", "abalance", "doc"}
+	}
+	return []string{"id", "abalance"}
+}
+
+func toastStrategyName(kind string, withDoc bool) string {
+	if withDoc {
+		return "toast_" + kind
+	}
+	return "toast_" + kind + "_no_doc"
+}
+
+// fetchToastKeyset returns a keyset strategy over the TOAST scenario table.
+func fetchToastKeyset(withDoc bool) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: toastStrategyName("keyset", withDoc),
+		}
+
+		// Open the sink the rows are written to
+		out, err := openSink(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		columns := toastColumns(withDoc)
+		if err := out.WriteHeader(columns); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		sizes := newRowSizeRecorder()
+
+		var lastId int64
+		for {
+			batchStart := time.Now()
+			bctx, timings := traceQueries(ctx)
+
+			// The first page has no lower bound
+			first := len(result.Batches) == 0
+			var args []any
+			if !first {
+				args = append(args, lastId)
+			}
+
+			rows, err := pool.Query(bctx, toastPageQuery(columns, first), args...)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch data: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			var count int
+			var firstId int64
+			for rows.Next() {
+				var id int64
+				var abalance int
+				var doc string
+
+				dest := []any{&id, &abalance}
+				if withDoc {
+					dest = append(dest, &doc)
+				}
+				if err := rows.Scan(dest...); err != nil {
+					err = fmt.Errorf("failed to scan row: %w", err)
+					result.Err = err
+					res <- result
+					return err
+				}
+
+				record := []string{fmt.Sprintf("%d", id), fmt.Sprintf("%d", abalance)}
+				if withDoc {
+					record = append(record, doc)
+				}
+				n, err := timings.WriteRow(out, record)
+				if err != nil {
+					result.Err = err
+					res <- result
+					return err
+				}
+				sizes.Add(n)
+
+				if count == 0 {
+					firstId = id
+				}
+				lastId = id
+				count++
+			}
+
+			rows.Close()
+
+			if rows.Err() != nil {
+				err = fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			// Check if there are no more rows
+			if count == 0 {
+				break
+			}
+
+			result.addBatch(ctx, Batch{
+				Seq:      len(result.Batches) + 1,
+				Start:    batchStart,
+				Key:      fmt.Sprintf("id %d..%d", firstId, lastId),
+				Rows:     count,
+				Duration: time.Since(batchStart),
+
+				QueryTimings: *timings,
+			})
+
+			// Hold still while the run is paused
+			result.Paused += control.Wait(ctx)
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.RowSizes = sizes.Summary()
+		result.Writes = out.Stats()
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
+
+// fetchToastCopy returns a COPY strategy over the TOAST scenario table.
+func fetchToastCopy(withDoc bool) func(context.Context, chan<- Result) error {
+	return func(ctx context.Context, res chan<- Result) error {
+		defer wg.Done()
+		start := time.Now()
+		result := Result{
+			Type: toastStrategyName("copy", withDoc),
+		}
+
+		// COPY encodes the rows itself, so it writes straight to the destination
+		out, err := openDestination(result.Type)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer out.Close()
+
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+		defer conn.Release()
+
+		columns := toastColumns(withDoc)
+		counter := &countingWriter{w: out}
+		tag, err := conn.Conn().PgConn().CopyTo(ctx, counter, toastCopyCommand(columns))
+		if err != nil {
+			err = fmt.Errorf("failed to copy data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Move the finished file into place
+		if err := out.Finalize(); err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		end := time.Now()
+		duration := end.Sub(start)
+
+		result.Duration = duration
+		result.Writes = out.Stats()
+
+		// COPY writes whole rows itself, so only the mean size is known
+		header := exportCSV.headerSize(columns)
+		result.RowSizes = RowSizes{Rows: int(tag.RowsAffected()), Bytes: counter.n - header}
+		if result.RowSizes.Rows > 0 {
+			result.RowSizes.Mean = float64(result.RowSizes.Bytes) / float64(result.RowSizes.Rows)
+		}
+		result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+		res <- result
+
+		return nil
+	}
+}
+
+// ToastOverhead is the cost of exporting the TOASTed column with a strategy.
+type ToastOverhead struct {
+	Strategy string
+	With     time.Duration
+	Without  time.Duration
+	// Bytes is how much larger the export with the column was.
+	Bytes int64
+}
+
+// toastOverheads pairs every TOAST scenario strategy with its variant without
+// the doc column.
+func toastOverheads(results []Result) []ToastOverhead {
+	byType := make(map[string]Result)
+	for _, result := range results {
+		if result.Err == nil {
+			byType[result.Type] = result
+		}
+	}
+
+	var overheads []ToastOverhead
+	for _, kind := range []string{"keyset", "copy"} {
+		with, ok := byType[toastStrategyName(kind, true)]
+		if !ok {
+			continue
+		}
+		without, ok := byType[toastStrategyName(kind, false)]
+		if !ok {
+			continue
+		}
+		overheads = append(overheads, ToastOverhead{
+			Strategy: with.Type,
+			With:     with.Duration,
+			Without:  without.Duration,
+			Bytes:    with.RowSizes.Bytes - without.RowSizes.Bytes,
+		})
+	}
+	return overheads
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// schemaPolicy decides what a run does when the columns of the benchmark
+// table changed since the previous run: fail refuses to run, adapt runs with
+// the columns that are left and versions the schema in the manifest.
+var schemaPolicy = "fail"
+
+// previousRunDir is the directory of the run the latest link pointed at
+// before this run started.
+var previousRunDir string
+
+// runDir is the directory of this run.
+var runDir string
+
+// TableSchema is the definition of the benchmark table during a run. Version
+// starts at 1 and goes up with every run of the same table that saw different
+// columns.
+type TableSchema struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Version int      `json:"version"`
+	Columns []string `json:"columns"`
+}
+
+// sameTable reports whether both schemas describe the same table, only then
+// are their columns compared.
+func (s *TableSchema) sameTable(other *TableSchema) bool {
+	return s.Schema == other.Schema && s.Table == other.Table
+}
+
+// readTableSchema reads the schema the benchmark table is in and lists its
+// columns with their types in table order.
+func readTableSchema(ctx context.Context) (*TableSchema, error) {
+	schema := &TableSchema{Table: benchTable}
+	err := pool.QueryRow(ctx, "SELECT COALESCE(NULLIF($1, ''), current_schema())", benchSchema).Scan(&schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT column_name || ' ' || data_type
+		FROM information_schema.columns
+		WHERE table_name = $1 AND table_schema = $2
+		ORDER BY ordinal_position`, schema.Table, schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		schema.Columns = append(schema.Columns, column)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", rows.Err())
+	}
+	return schema, nil
+}
+
+// previousSchema returns the table schema in the manifest of the previous
+// run, in the directory of the same target, if there is one.
+func previousSchema() *TableSchema {
+	if previousRunDir == "" || runDir == "" {
+		return nil
+	}
+	rel, err := filepath.Rel(runDir, outputDir)
+	if err != nil {
+		return nil
+	}
+	manifest, err := readManifest(filepath.Join(previousRunDir, rel, "manifest.json"))
+	if err != nil {
+		return nil
+	}
+	return manifest.Schema
+}
+
+// schemaChanges describes how the columns differ between two schemas.
+func schemaChanges(before, after []string) []string {
+	types := func(columns []string) map[string]string {
+		m := make(map[string]string)
+		for _, c := range columns {
+			name, dataType, _ := strings.Cut(c, " ")
+			m[name] = dataType
+		}
+		return m
+	}
+	old, current := types(before), types(after)
+
+	var changes []string
+	for _, c := range before {
+		name, _, _ := strings.Cut(c, " ")
+		dataType, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("column %s was dropped", name))
+		case dataType != old[name]:
+			changes = append(changes, fmt.Sprintf("column %s changed from %s to %s", name, old[name], dataType))
+		}
+	}
+	for _, c := range after {
+		name, dataType, _ := strings.Cut(c, " ")
+		if _, ok := old[name]; !ok {
+			changes = append(changes, fmt.Sprintf("column %s %s was added", name, dataType))
+		}
+	}
+	if len(changes) == 0 && !slices.Equal(before, after) {
+		changes = append(changes, "columns were reordered")
+	}
+	return changes
+}
+
+// checkSchema compares the columns of the benchmark table with the previous
+// run of the same table and applies the schema policy. Adapting drops the
+// exported columns the table lost, the caller restores the projection after
+// the run. A schema that cannot be read is not checked.
+func checkSchema(ctx context.Context) (*TableSchema, error) {
+	schema, err := readTableSchema(ctx)
+	if err != nil {
+		fmt.Println("schema check disabled:", err)
+		return nil, nil
+	}
+	schema.Version = 1
+	columns := schema.Columns
+
+	// A previous run of another table, or from before the table was
+	// recorded, starts the versions over
+	previous := previousSchema()
+	if previous == nil || !previous.sameTable(schema) {
+		return schema, nil
+	}
+	changes := schemaChanges(previous.Columns, columns)
+	if len(changes) == 0 {
+		schema.Version = previous.Version
+		return schema, nil
+	}
+	schema.Version = previous.Version + 1
+
+	if schemaPolicy == "fail" {
+		return nil, fmt.Errorf("schema of %s changed since the previous run, set SCHEMA_POLICY=adapt to run anyway: %s",
+			tableName(), strings.Join(changes, ", "))
+	}
+	fmt.Printf("schema of %s changed since the previous run, now version %d:\n", tableName(), schema.Version)
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c)
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i], _, _ = strings.Cut(c, " ")
+	}
+	if !slices.Contains(names, keyColumn) {
+		return nil, fmt.Errorf("key column %s was dropped from %s", keyColumn, tableName())
+	}
+	var kept []string
+	for _, column := range projection {
+		if slices.Contains(names, column) {
+			kept = append(kept, column)
+		} else {
+			fmt.Printf("  no longer exporting column %s\n", column)
+		}
+	}
+	useProjection(kept)
+	return schema, nil
+}
+
+func validSchemaPolicy(policy string) error {
+	if policy != "fail" && policy != "adapt" {
+		return fmt.Errorf("unknown SCHEMA_POLICY %q, expected fail or adapt", policy)
+	}
+	return nil
+}
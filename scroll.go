@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// scrollBackEvery is the number of pages after which the scroll cursor
+// strategy steps back a page and returns, like a user paging back and forth.
+// Zero disables it.
+var scrollBackEvery int
+
+// ScrollStats times the extra fetches of the scroll cursor strategy, whose
+// rows are not exported again.
+type ScrollStats struct {
+	Backward []time.Duration
+	// Return are the forward fetches back to where the backward fetches
+	// started.
+	Return []time.Duration
+}
+
+// fetchWithScrollCursor pages through a SCROLL cursor, stepping back a page
+// with FETCH BACKWARD every scrollBackEvery pages and returning with FETCH
+// FORWARD. Only the forward pages are exported and recorded as batches, so
+// they compare with the cursor strategy.
+func fetchWithScrollCursor(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:   "cursor_scroll",
+		Scroll: &ScrollStats{},
+	}
+
+	// Open the sink the rows are written to
+	out, err := openSink(result.Type)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer out.Close()
+
+	header := projection
+	if err := out.WriteHeader(header); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	sizes := newRowSizeRecorder()
+
+	// Start a transaction
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, declareScrollCursorQuery("scroll_cursor"))
+	if err != nil {
+		err = fmt.Errorf("failed to declare cursor: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// skip times a fetch whose rows were already exported
+	skip := func(query string) (time.Duration, error) {
+		fetchStart := time.Now()
+		rows, err := tx.Query(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch data: %w", err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return 0, fmt.Errorf("error occurred while iterating rows: %w", rows.Err())
+		}
+		return time.Since(fetchStart), nil
+	}
+
+	for page := 1; ; page++ {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		rows, err := tx.Query(bctx, fetchForwardQuery("scroll_cursor"))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch data: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		count, err := writeAccountRows(bctx, rows, out, sizes)
+		if err != nil {
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// Check if there are no more rows
+		if count == 0 {
+			break
+		}
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("page %d", page),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		// Step back a page and return to the current row
+		if page%scrollBackEvery == 0 {
+			backward, err := skip(fetchBackwardQuery("scroll_cursor"))
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			forward, err := skip(fetchForwardQuery("scroll_cursor"))
+			if err != nil {
+				result.Err = err
+				res <- result
+				return err
+			}
+			result.Scroll.Backward = append(result.Scroll.Backward, backward)
+			result.Scroll.Return = append(result.Scroll.Return, forward)
+		}
+
+		// Hold still while the run is paused
+		result.Paused += control.Wait(ctx)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Move the finished file into place
+	if err := out.Finalize(); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	result.Duration = duration
+	result.RowSizes = sizes.Summary()
+	result.Writes = out.Stats()
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareScrollCursor compares the median fetches of the scroll cursor with
+// those of the forward only cursor strategy.
+func compareScrollCursor(results []Result) (string, bool) {
+	var scroll, cursor *Result
+	for i := range results {
+		switch r := &results[i]; {
+		case r.Err != nil:
+		case r.Type == "cursor_scroll":
+			scroll = r
+		case r.Type == "cursor":
+			cursor = r
+		}
+	}
+	if scroll == nil || cursor == nil || len(scroll.Scroll.Backward) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("cursor_scroll median fetch %s forward, %s backward, %s returning, cursor %s forward only",
+		median(batchDurations(scroll.Batches)), median(scroll.Scroll.Backward), median(scroll.Scroll.Return),
+		median(batchDurations(cursor.Batches))), true
+}
+
+func batchDurations(batches []Batch) []time.Duration {
+	durations := make([]time.Duration, len(batches))
+	for i, b := range batches {
+		durations[i] = b.Duration
+	}
+	return durations
+}
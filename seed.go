@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// seedKeys maps each distribution to the SQL expression generating the aid of
+// row g, where g counts from 1. All expressions are strictly increasing in g
+// so keys stay unique.
+var seedKeys = map[string]string{
+	// 1, 2, 3, ... like pgbench itself
+	"dense": "g",
+	// random gaps of 1 to 19 keys, 10 on average
+	"gaps": "sum(1 + floor(random() * 19)::bigint) OVER (ORDER BY g)",
+	// hot runs of 1000 consecutive keys followed by 9000 unused keys
+	"clustered": "((g - 1) / 1000) * 10000 + (g - 1) % 1000 + 1",
+	// density falls off with the key like a Zipf distribution: keys are
+	// packed at the start of the range and ever sparser towards the end
+	"zipf": "floor(power(g, 1.5))::bigint",
+}
+
+// seed replaces pgbench_accounts with rows whose aid follows the given
+// distribution, keeping the pgbench column layout so every strategy works on
+// it unchanged.
+func seed(ctx context.Context, distribution string, rows int) error {
+	key, ok := seedKeys[distribution]
+	if !ok {
+		return fmt.Errorf("unknown key distribution %q", distribution)
+	}
+
+	start := time.Now()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	statements := []string{
+		"DROP TABLE IF EXISTS pgbench_accounts",
+		`CREATE TABLE pgbench_accounts (
+			aid bigint NOT NULL,
+			bid int,
+			abalance int,
+			filler char(84)
+		)`,
+		fmt.Sprintf(`
+			INSERT INTO pgbench_accounts (aid, bid, abalance, filler)
+			SELECT %s, (g - 1) / 100000 + 1, 0, ''
+			FROM generate_series(1, %d) g`, key, rows),
+		"ALTER TABLE pgbench_accounts ADD PRIMARY KEY (aid)",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to seed table: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if _, err := pool.Exec(ctx, "VACUUM ANALYZE pgbench_accounts"); err != nil {
+		return fmt.Errorf("failed to vacuum table: %w", err)
+	}
+
+	var minKey, maxKey int64
+	err = pool.QueryRow(ctx, "SELECT min(aid), max(aid) FROM pgbench_accounts").Scan(&minKey, &maxKey)
+	if err != nil {
+		return fmt.Errorf("failed to read key range: %w", err)
+	}
+
+	span := maxKey - minKey + 1
+	fmt.Printf("seeded %d rows with %s keys in %.2f second\n", rows, distribution, time.Since(start).Seconds())
+	fmt.Printf("keys %d..%d, %.1f%% of the key range is used\n", minKey, maxKey, 100*float64(rows)/float64(span))
+	fmt.Printf("DATA_LIMIT bounds the key range, set it to %d to export every row\n", maxKey)
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// snapshotSettings are the server settings saved in the manifest, the
+// fingerprinted ones and the planner and I/O settings that are most often
+// tuned between runs.
+var snapshotSettings = append([]string{
+	"maintenance_work_mem", "max_parallel_workers", "max_worker_processes",
+	"parallel_setup_cost", "parallel_tuple_cost", "cpu_tuple_cost",
+	"cpu_index_tuple_cost", "cpu_operator_cost", "default_statistics_target",
+	"enable_seqscan", "enable_indexscan", "enable_indexonlyscan",
+	"enable_bitmapscan", "enable_sort", "enable_hashjoin", "enable_nestloop",
+	"track_io_timing", "wal_compression", "temp_buffers", "io_combine_limit",
+	"maintenance_io_concurrency",
+}, fingerprintSettings...)
+
+// readSettings reads the current value of the snapshot settings, in the units
+// SHOW displays them with.
+func readSettings(ctx context.Context) (map[string]string, error) {
+	rows, err := pool.Query(ctx, "SELECT name, current_setting(name) FROM pg_settings WHERE name = ANY($1)", snapshotSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to read settings: %w", err)
+		}
+		settings[name] = value
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", rows.Err())
+	}
+	return settings, nil
+}
+
+// SettingChange is a server setting that differs between two runs. An empty
+// value means the run did not record the setting.
+type SettingChange struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// changedSettings lists the settings that differ between two snapshots,
+// sorted by name.
+func changedSettings(before, after map[string]string) []SettingChange {
+	var changes []SettingChange
+	for name, value := range after {
+		if before[name] != value {
+			changes = append(changes, SettingChange{Name: name, Before: before[name], After: value})
+		}
+	}
+	for name, value := range before {
+		if _, ok := after[name]; !ok {
+			changes = append(changes, SettingChange{Name: name, Before: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// destination is where a strategy's encoded output ends up.
+type destination interface {
+	io.Writer
+	// Finalize publishes the output once everything has been written.
+	Finalize() error
+	// Close releases the destination, leaving unfinalized output marked as
+	// partial.
+	Close() error
+	Stats() WriteStats
+}
+
+// rowSink encodes the rows exported by a strategy.
+type rowSink interface {
+	WriteHeader(columns []string) error
+	// WriteRow encodes one row and returns its encoded size in bytes.
+	WriteRow(record []string) (int, error)
+	Finalize() error
+	Close() error
+	Stats() WriteStats
+}
+
+var streamStrategy string
+var streamFormat string
+
+// outputFormat is the encoding of the exports that are not streamed, csv,
+// jsonl, parquet or arrow.
+var outputFormat string
+
+// openDestination opens the output of the named strategy on every sink in
+// SINKS, counted and hashed for the metrics when they are served.
+func openDestination(name string) (destination, error) {
+	dst, err := openSinks(name)
+	if err != nil || metricsAddr == "" {
+		return dst, err
+	}
+	return newMetricsDestination(dst, name), nil
+}
+
+func openSinks(name string) (destination, error) {
+	if len(sinkNames) == 1 {
+		return openSinkDestination(sinkNames[0], name)
+	}
+
+	fanout := &fanoutDestination{}
+	for _, sink := range sinkNames {
+		dst, err := openSinkDestination(sink, name)
+		if err != nil {
+			fanout.Close()
+			return nil, err
+		}
+		fanout.sinks = append(fanout.sinks, &timedDestination{destination: dst, timing: SinkTiming{Name: sink}})
+	}
+	return fanout, nil
+}
+
+// openFileDestination opens the file sink of the named strategy, which is
+// stdout for the strategy selected with STREAM and a CSV file in the output
+// directory otherwise.
+func openFileDestination(name string) (destination, error) {
+	if name == streamStrategy {
+		return stdoutDestination{bufio.NewWriter(stdout)}, nil
+	}
+
+	file, err := createOutput(name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	return file, nil
+}
+
+// openSink opens the row encoder of the named strategy, checking its rows
+// against VALIDATION_RULES when there are any.
+func openSink(name string) (rowSink, error) {
+	sink, err := openEncoder(name)
+	if err != nil || len(validationRules) == 0 {
+		return sink, err
+	}
+	return newValidatingSink(sink, name), nil
+}
+
+func openEncoder(name string) (rowSink, error) {
+	dst, err := openDestination(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ndjson := outputFormat == "jsonl"
+	if name == streamStrategy {
+		ndjson = streamFormat == "ndjson"
+	} else if outputFormat == "parquet" {
+		return newParquetSink(dst), nil
+	} else if outputFormat == "arrow" {
+		return newArrowSink(dst, name), nil
+	}
+	if ndjson {
+		// NDJSON has no header line, and JSON escapes the newlines in values
+		if m, ok := dst.(*metricsDestination); ok {
+			m.inHeader = false
+			m.lines = true
+		}
+		return &ndjsonSink{dst: dst, w: bufio.NewWriter(dst)}, nil
+	}
+	return &csvSink{dst: dst, w: newCSVWriter(dst, exportCSV), dialect: exportCSV}, nil
+}
+
+// stdout is the real standard output. In streaming mode os.Stdout is pointed
+// at stderr so that progress messages do not mix with the streamed rows.
+var stdout = os.Stdout
+
+type stdoutDestination struct {
+	*bufio.Writer
+}
+
+func (s stdoutDestination) Finalize() error {
+	return s.Flush()
+}
+
+func (s stdoutDestination) Close() error {
+	return s.Flush()
+}
+
+func (s stdoutDestination) Stats() WriteStats {
+	return WriteStats{}
+}
+
+type csvSink struct {
+	dst     destination
+	w       *csvWriter
+	dialect csvDialect
+}
+
+func (s *csvSink) WriteHeader(columns []string) error {
+	if !s.dialect.header {
+		return nil
+	}
+	_, err := s.WriteRow(columns)
+	return err
+}
+
+func (s *csvSink) WriteRow(record []string) (int, error) {
+	if err := s.w.Write(record); err != nil {
+		return 0, fmt.Errorf("error writing record to CSV: %v", err)
+	}
+	return s.dialect.recordSize(record), nil
+}
+
+func (s *csvSink) Finalize() error {
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("error writing record to CSV: %v", err)
+	}
+	return s.dst.Finalize()
+}
+
+func (s *csvSink) Close() error {
+	return s.dst.Close()
+}
+
+func (s *csvSink) Stats() WriteStats {
+	return s.dst.Stats()
+}
+
+// ndjsonSink writes one JSON object per row, keyed by the header columns in
+// column order.
+type ndjsonSink struct {
+	dst  destination
+	w    *bufio.Writer
+	keys [][]byte
+	line []byte
+}
+
+func (s *ndjsonSink) WriteHeader(columns []string) error {
+	s.keys = make([][]byte, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return fmt.Errorf("error encoding column to JSON: %v", err)
+		}
+		s.keys[i] = append(key, ':')
+	}
+	return nil
+}
+
+func (s *ndjsonSink) WriteRow(record []string) (int, error) {
+	line := append(s.line[:0], '{')
+	for i, value := range record {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding record to JSON: %v", err)
+		}
+		line = append(line, s.keys[i]...)
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+	s.line = line
+
+	if _, err := s.w.Write(line); err != nil {
+		return 0, fmt.Errorf("error writing record to JSON: %v", err)
+	}
+	return len(line), nil
+}
+
+func (s *ndjsonSink) Finalize() error {
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("error writing record to JSON: %v", err)
+	}
+	return s.dst.Finalize()
+}
+
+func (s *ndjsonSink) Close() error {
+	return s.dst.Close()
+}
+
+func (s *ndjsonSink) Stats() WriteStats {
+	return s.dst.Stats()
+}
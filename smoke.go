@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// smokeTest runs every strategy over the first smokeLimit keys without
+// writing any output, to check configuration and connectivity in seconds.
+var smokeTest bool
+
+// smokeLimit is the highest key a smoke test reads, in at most smokePages
+// pages per strategy.
+const (
+	smokeLimit = 1000
+	smokePages = 10
+)
+
+// applySmokeTest caps the run to the keys and pages of a smoke test and
+// discards the rows, so the strategies only count them. The strategies read
+// the limit and batch size like in any other run and need no checks of
+// their own.
+func applySmokeTest() {
+	sinkNames = []string{"discard"}
+	limit = min(limit, smokeLimit)
+	batchSize = max(batchSize, (limit+smokePages-1)/smokePages)
+}
+
+// reportSmokeTest prints the row count of every strategy and returns an error
+// if any of them failed.
+func reportSmokeTest(results []Result) error {
+	var failed int
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", result.Type, result.Err)
+			continue
+		}
+		fmt.Printf("ok   %s: %s rows in %s\n", result.Type, human.Int(int64(result.RowSizes.Rows)), human.Seconds(result.Duration))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("smoke test failed: %d of %d strategies failed", failed, len(results))
+	}
+	fmt.Printf("smoke test passed: %d strategies\n", len(results))
+	return nil
+}
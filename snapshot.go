@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// snapshotParallel adds the snapshot parallel strategy to the parallel ones.
+var snapshotParallel bool
+
+// SnapshotStats describes how the workers of the snapshot parallel strategy
+// came to share one snapshot.
+type SnapshotStats struct {
+	// ID is the exported snapshot and Snapshot the txid snapshot every
+	// worker saw after importing it.
+	ID       string
+	Snapshot string
+	Workers  int
+	// Setup is the time from beginning the exporting transaction until the
+	// last worker imported the snapshot.
+	Setup time.Duration
+}
+
+// fetchWithSnapshotParallel partitions the key range like range_parallel,
+// but the workers page in REPEATABLE READ transactions that import one
+// snapshot exported by a coordinating transaction, so together they read
+// the table as of a single point in time.
+func fetchWithSnapshotParallel(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type: "snapshot_parallel",
+	}
+
+	// Every worker holds a connection for the whole export, and the
+	// coordinator one more until they imported the snapshot
+	if conns := pool.Config().MaxConns; int(conns) < parallelWorkers+1 {
+		err := fmt.Errorf("snapshot_parallel needs %d connections for %d workers, the pool has %d", parallelWorkers+1, parallelWorkers, conns)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Open the sink the rows are written to
+	export, err := openParallelExport(&result, parallelWorkers)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer export.Close()
+
+	options := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	coordinator, err := pool.BeginTx(ctx, options)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+	defer coordinator.Rollback(ctx)
+
+	stats := &SnapshotStats{}
+	if err := coordinator.QueryRow(ctx, exportSnapshotQuery()).Scan(&stats.ID, &stats.Snapshot); err != nil {
+		err = fmt.Errorf("failed to export snapshot: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Split the keys that are there in the snapshot
+	var first, last *int
+	if err := coordinator.QueryRow(ctx, keyBoundsQuery()).Scan(&first, &last); err != nil {
+		err = fmt.Errorf("failed to read key range: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	var workers []partitionWorker
+	if first != nil {
+		width := (*last-*first)/parallelWorkers + 1
+		for worker := 0; worker < parallelWorkers; worker++ {
+			after := *first - 1 + worker*width
+			end := min(after+width, *last)
+			if after >= end {
+				break
+			}
+
+			tx, err := pool.BeginTx(ctx, options)
+			if err != nil {
+				err = fmt.Errorf("failed to begin transaction: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			defer tx.Rollback(ctx)
+
+			// Check that the import worked rather than trust it did
+			var snapshot string
+			if _, err := tx.Exec(ctx, importSnapshotQuery(stats.ID)); err != nil {
+				err = fmt.Errorf("failed to import snapshot: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			if err := tx.QueryRow(ctx, currentSnapshotQuery()).Scan(&snapshot); err != nil {
+				err = fmt.Errorf("failed to read snapshot: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			if snapshot != stats.Snapshot {
+				err = fmt.Errorf("worker %d sees snapshot %s instead of the exported %s", worker, snapshot, stats.Snapshot)
+				result.Err = err
+				res <- result
+				return err
+			}
+
+			workers = append(workers, partitionWorker{
+				after:     after,
+				pageQuery: func(lastId int) (string, []any) { return rangePageQuery(), []any{lastId, end} },
+				label:     fmt.Sprintf("worker %d", worker),
+				conn:      tx,
+			})
+		}
+	}
+	stats.Workers = len(workers)
+	stats.Setup = time.Since(start)
+
+	// The workers hold the snapshot from here on
+	if err := coordinator.Commit(ctx); err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	if err := runPartitions(ctx, export, workers); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+	for _, w := range workers {
+		if err := w.conn.(pgx.Tx).Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+	}
+
+	result.Snapshot = stats
+	return finishParallel(export, start, result, res)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// median returns the middle value of the durations, averaging the two middle
+// values for an even count.
+func median(values []time.Duration) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// findOutliers returns the batches whose latency is more than k median
+// absolute deviations above the median batch latency.
+func findOutliers(batches []Batch, k float64) []Batch {
+	if len(batches) < 3 || k <= 0 {
+		return nil
+	}
+
+	durations := make([]time.Duration, len(batches))
+	for i, b := range batches {
+		durations[i] = b.Duration
+	}
+	med := median(durations)
+
+	deviations := make([]time.Duration, len(durations))
+	for i, d := range durations {
+		deviations[i] = (d - med).Abs()
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return nil
+	}
+
+	threshold := med + time.Duration(k*float64(mad))
+
+	var outliers []Batch
+	for _, b := range batches {
+		if b.Duration > threshold {
+			outliers = append(outliers, b)
+		}
+	}
+	return outliers
+}
+
+// percentile returns the p-th percentile (0-100) of the durations using the
+// nearest-rank method.
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Capacity is the sustainable page rate of a paginated endpoint served by a
+// strategy at a given concurrency.
+type Capacity struct {
+	Concurrency int
+	MeanLatency time.Duration
+	P95Latency  time.Duration
+	PagesPerSec float64
+	RowsPerSec  float64
+}
+
+// estimateCapacity applies Little's Law to the measured page latencies. With N
+// clients each waiting R for a page and thinking Z between pages, the
+// sustainable throughput is N / (R + Z).
+func estimateCapacity(batches []Batch, concurrency int, think time.Duration) (Capacity, bool) {
+	if len(batches) == 0 || concurrency <= 0 {
+		return Capacity{}, false
+	}
+
+	durations := make([]time.Duration, len(batches))
+	var total time.Duration
+	var rows int
+	for i, b := range batches {
+		durations[i] = b.Duration
+		total += b.Duration
+		rows += b.Rows
+	}
+
+	mean := total / time.Duration(len(batches))
+	cycle := (mean + think).Seconds()
+	if cycle <= 0 {
+		return Capacity{}, false
+	}
+
+	pages := float64(concurrency) / cycle
+	return Capacity{
+		Concurrency: concurrency,
+		MeanLatency: mean,
+		P95Latency:  percentile(durations, 95),
+		PagesPerSec: pages,
+		RowsPerSec:  pages * float64(rows) / float64(len(batches)),
+	}, true
+}
+
+// CostModel is a least squares fit of batch latency against the number of rows
+// already read when the batch started, i.e. the rows an OFFSET query skips.
+type CostModel struct {
+	Base time.Duration
+	// Per10k is the latency added per 10,000 rows of position.
+	Per10k time.Duration
+	R2     float64
+}
+
+func fitCostModel(batches []Batch) (CostModel, bool) {
+	if len(batches) < 3 {
+		return CostModel{}, false
+	}
+
+	xs := make([]float64, len(batches))
+	ys := make([]float64, len(batches))
+	var position int
+	for i, b := range batches {
+		xs[i] = float64(position)
+		ys[i] = float64(b.Duration)
+		position += b.Rows
+	}
+
+	slope, intercept, r2, ok := linearRegression(xs, ys)
+	if !ok {
+		return CostModel{}, false
+	}
+	return CostModel{
+		Base:   time.Duration(intercept),
+		Per10k: time.Duration(slope * 10000),
+		R2:     r2,
+	}, true
+}
+
+// linearRegression fits y = slope*x + intercept and returns the coefficient of
+// determination of the fit.
+func linearRegression(xs, ys []float64) (slope, intercept, r2 float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	if syy > 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, intercept, r2, true
+}
@@ -0,0 +1,837 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+type strategy struct {
+	name string
+	run  func(context.Context, chan<- Result) error
+	// enabled reports whether the strategy is part of a run with the
+	// current configuration.
+	enabled bool
+	// usesCopy marks the strategies that export with COPY, whose output the
+	// server encodes, only as CSV with LF line endings.
+	usesCopy bool
+	// workers is the number of workers of a parallel strategy, each of
+	// which writes its own shard with PARALLEL_SHARDS.
+	workers int
+	doc     strategyDoc
+}
+
+// strategyDoc describes a strategy for `explain`.
+type strategyDoc struct {
+	Summary string
+	// SQL returns the statements the strategy runs, built by the same
+	// functions the strategy uses with the current configuration.
+	SQL         func() []string
+	Consistency string
+	Example     string
+}
+
+// registeredStrategies returns every strategy the tool knows about, the
+// built in ones followed by those added with Register.
+func registeredStrategies() []strategy {
+	strategies := builtinStrategies()
+	for _, p := range plugins {
+		strategies = append(strategies, pluginStrategy(p))
+	}
+	return strategies
+}
+
+// builtinStrategies returns the strategies of the tool itself.
+func builtinStrategies() []strategy {
+	strategies := []strategy{
+		{
+			name:    "cursor",
+			run:     fetchWithCursor,
+			enabled: true,
+			doc: strategyDoc{
+				Summary: "Declares a server side cursor in a transaction and fetches it in batches.",
+				SQL: func() []string {
+					return []string{declareCursorQuery("my_cursor", 0), fetchCursorQuery("my_cursor")}
+				},
+				Consistency: "Every batch comes from the snapshot of the cursor's transaction, which stays open for the whole export and holds back vacuum.",
+				Example:     "STREAM=cursor go run .",
+			},
+		},
+		{
+			name:    "offset_limit",
+			run:     fetchWithOffsetLimit,
+			enabled: true,
+			doc: strategyDoc{
+				Summary: "Runs one query per page, skipping the pages before it with OFFSET.",
+				SQL: func() []string {
+					return []string{offsetPageQuery()}
+				},
+				Consistency: "Each page sees its own snapshot. Rows inserted or deleted before the current offset shift the pages, so rows can be repeated or missed. Latency grows with the offset.",
+				Example:     "STREAM=offset_limit go run .",
+			},
+		},
+		{
+			name:    "custom_cursor",
+			run:     fetchWithCustomCursor,
+			enabled: true,
+			doc: strategyDoc{
+				Summary: "Keyset pagination: every page starts after the last key of the previous page.",
+				SQL: func() []string {
+					return []string{keysetPageQuery(selectList())}
+				},
+				Consistency: "Each page sees its own snapshot. Rows never repeat, but rows changed behind the current key are missed and rows ahead of it show their latest version.",
+				Example:     "STREAM=custom_cursor go run .",
+			},
+		},
+		{
+			name:     "copy",
+			run:      fetchWithCopy,
+			enabled:  true,
+			usesCopy: true,
+			doc: strategyDoc{
+				Summary: "Streams the whole result with COPY TO STDOUT, letting the server encode the CSV.",
+				SQL: func() []string {
+					return []string{copyCommand()}
+				},
+				Consistency: "A single statement, so the export is one consistent snapshot.",
+				Example:     "STREAM=copy go run .",
+			},
+		},
+	}
+
+	strategies = append(strategies,
+		strategy{
+			name:    "custom_cursor_unprepared",
+			run:     fetchWithCustomCursorUnprepared,
+			enabled: prepareCompare,
+			doc: strategyDoc{
+				Summary: "The custom_cursor strategy with every page sent as an unnamed statement, parsed and planned again instead of prepared once per connection.",
+				SQL: func() []string {
+					return []string{keysetPageQuery(selectList())}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "PREPARE_COMPARE=true go run .",
+			},
+		},
+		strategy{
+			name:    "custom_cursor_desc",
+			run:     fetchWithReverseKeyset,
+			enabled: reverseCompare,
+			doc: strategyDoc{
+				Summary: "The custom_cursor strategy in descending key order, latest first, paging below the first key of the previous page.",
+				SQL: func() []string {
+					return []string{reverseKeysetPageQuery()}
+				},
+				Consistency: "Same as custom_cursor, with rows inserted above the current page missed instead of rows inserted below it.",
+				Example:     "REVERSE_COMPARE=true go run .",
+			},
+		},
+		strategy{
+			name:    "custom_cursor_text",
+			run:     fetchWithTextFormat,
+			enabled: formatCompare,
+			doc: strategyDoc{
+				Summary: "The custom_cursor strategy with every column of the result in the text format, timing the scans.",
+				SQL: func() []string {
+					return []string{keysetPageQuery(selectList())}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "RESULT_FORMAT_COMPARE=true go run .",
+			},
+		},
+		strategy{
+			name:    "custom_cursor_binary",
+			run:     fetchWithBinaryFormat,
+			enabled: formatCompare,
+			doc: strategyDoc{
+				Summary: "The custom_cursor strategy with every column of the result in the binary format, timing the scans.",
+				SQL: func() []string {
+					return []string{keysetPageQuery(selectList())}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "RESULT_FORMAT_COMPARE=true go run .",
+			},
+		},
+		strategy{
+			name:    "offset_limit_unprepared",
+			run:     fetchWithOffsetLimitUnprepared,
+			enabled: prepareCompare,
+			doc: strategyDoc{
+				Summary: "The offset_limit strategy with every page sent as an unnamed statement, parsed and planned again instead of prepared once per connection.",
+				SQL: func() []string {
+					return []string{offsetPageQuery()}
+				},
+				Consistency: "Same as offset_limit.",
+				Example:     "PREPARE_COMPARE=true go run .",
+			},
+		},
+	)
+
+	for _, load := range []struct {
+		name, summary string
+		run           func(context.Context, chan<- Result) error
+		sql           func() string
+	}{
+		{"load_insert", "Loads LOAD_FILE back into a copy of the table with multi-row INSERTs of DATA_BATCH_SIZE rows.", loadWithInsert,
+			func() string { return insertRowsQuery("bench_load_insert", projection, 2) }},
+		{"load_copy", "Streams LOAD_FILE as it is into a copy of the table with COPY FROM STDIN, parsed by the server.", loadWithCopy,
+			func() string { return copyFromCommand("bench_load_copy", projection) }},
+		{"load_copyfrom", "Parses LOAD_FILE on the client and loads it into a copy of the table with pgx.CopyFrom in the binary COPY format.", loadWithCopyFrom,
+			func() string {
+				return fmt.Sprintf("COPY %s (%s) FROM STDIN BINARY", quoteIdent("bench_load_copyfrom"), quoteColumns(projection))
+			}},
+	} {
+		strategies = append(strategies, strategy{
+			name:    load.name,
+			run:     load.run,
+			enabled: loadFile != "",
+			doc: strategyDoc{
+				Summary: load.summary,
+				SQL: func() []string {
+					return append(createLoadTableQueries("bench_"+load.name, projection), load.sql())
+				},
+				Consistency: "Writes its own table bench_" + load.name + ", dropped and created empty before every run.",
+				Example:     "LOAD_FILE=output/latest/copy.csv go run .",
+			},
+		})
+	}
+
+	strategies = append(strategies,
+		strategy{
+			name:    "load_duckdb",
+			run:     loadWithDuckDB,
+			enabled: loadFile != "" && duckdbDatabase != "",
+			doc: strategyDoc{
+				Summary: "Loads LOAD_FILE into a table of the DuckDB database DUCKDB_DATABASE with read_csv, run by the duckdb client.",
+				SQL: func() []string {
+					return []string{duckdbLoadQuery("bench_load_duckdb")}
+				},
+				Consistency: "Writes its own table bench_load_duckdb in DUCKDB_DATABASE, dropped and created empty with the types of the exported columns before every run.",
+				Example:     "LOAD_FILE=output/latest/copy.csv DUCKDB_DATABASE=bench.duckdb go run .",
+			},
+		},
+		strategy{
+			name:    "load_clickhouse",
+			run:     loadWithClickHouse,
+			enabled: loadFile != "" && clickhouseURL != "",
+			doc: strategyDoc{
+				Summary: "Streams LOAD_FILE as it is to an INSERT over the HTTP interface of ClickHouse at CLICKHOUSE_URL, parsed by ClickHouse.",
+				SQL: func() []string {
+					return []string{clickhouseInsertQuery("bench_load_clickhouse")}
+				},
+				Consistency: "Writes its own MergeTree table bench_load_clickhouse, dropped and created empty with the types of the exported columns before every run.",
+				Example:     "LOAD_FILE=output/latest/copy.csv CLICKHOUSE_URL=http://localhost:8123 go run .",
+			},
+		},
+	)
+
+	for _, write := range []struct {
+		name, summary string
+		run           func(context.Context, chan<- Result) error
+		sql           func() string
+		supported     bool
+	}{
+		{"update", "Updates the rows of a copy of the table in pages of DATA_BATCH_SIZE keys with UPDATE, setting every exported column to itself.", writeWithUpdate,
+			func() string { return updatePageQuery("bench_update") }, true},
+		{"upsert", "Writes the rows of the table over a copy of it in pages of DATA_BATCH_SIZE keys with INSERT ... ON CONFLICT DO UPDATE.", writeWithUpsert,
+			func() string { return upsertPageQuery("bench_upsert") }, true},
+		{"merge", "Writes the rows of the table over a copy of it in pages of DATA_BATCH_SIZE keys with MERGE, on PostgreSQL 15 and later. Before PostgreSQL 17 MERGE can not return the written keys, so they are read in the same round trip.", writeWithMerge,
+			func() string { return mergePageQuery("bench_merge", server.MergeReturning) }, server.Merge},
+		{"delete", "Deletes the rows of a copy of the table in key ranges of DELETE_CHUNK keys with DELETE ... BETWEEN, vacuuming every DELETE_VACUUM_EVERY chunks.", writeWithDelete,
+			func() string { return deleteChunkQuery("bench_delete") }, true},
+	} {
+		strategies = append(strategies, strategy{
+			name:    write.name,
+			run:     write.run,
+			enabled: writeCompare && write.supported,
+			doc: strategyDoc{
+				Summary: write.summary,
+				SQL: func() []string {
+					table := "bench_" + write.name
+					return append(createWriteTableQueries(table), vacuumQuery(table), write.sql())
+				},
+				Consistency: "Writes its own copy of the table bench_" + write.name + ", created again before every run. Every page is its own transaction.",
+				Example:     "WRITE_COMPARE=true go run .",
+			},
+		})
+	}
+
+	strategies = append(strategies, strategy{
+		name:    "cursor_adaptive",
+		run:     fetchWithAdaptiveCursor,
+		enabled: adaptiveBatch,
+		doc: strategyDoc{
+			Summary: "Fetches a cursor starting with FETCH 10 and tunes the size after every batch toward ADAPTIVE_TARGET latency and at most ADAPTIVE_MAX_BYTES per batch.",
+			SQL: func() []string {
+				return []string{declareCursorQuery("adaptive_cursor", 0), fetchCursorSizeQuery("adaptive_cursor", adaptiveStart)}
+			},
+			Consistency: "Same as cursor.",
+			Example:     "ADAPTIVE_BATCH=true ADAPTIVE_TARGET=50ms go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "cursor_hold",
+		run:     fetchWithHoldCursor,
+		enabled: holdCursor,
+		doc: strategyDoc{
+			Summary: "Declares a WITH HOLD cursor and commits, materializing its rows, then fetches the batches outside of any transaction.",
+			SQL: func() []string {
+				return []string{declareHoldCursorQuery("hold_cursor"), fetchCursorQuery("hold_cursor")}
+			},
+			Consistency: "The rows are materialized from the snapshot of the declaring transaction, which ends before the first fetch, so no snapshot holds back vacuum while fetching.",
+			Example:     "HOLD_CURSOR=true go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "logical_decoding",
+		run:     fetchWithLogicalDecoding,
+		enabled: decodingPlugin != "",
+		doc: strategyDoc{
+			Summary: "Updates every row of a copy of the table through a temporary logical replication slot and times consuming the changes with LOGICAL_DECODING, pgoutput, wal2json or test_decoding.",
+			SQL: func() []string {
+				statements := createWriteTableQueries(decodingTable)
+				if decodingPlugin == "pgoutput" {
+					statements = append(statements, createPublicationQueries(decodingTable)...)
+				}
+				return append(statements, createDecodingSlotQuery(), updatePageQuery(decodingTable),
+					currentWALQuery(), decodingChangesQuery(decodingPlugin, decodingTable))
+			},
+			Consistency: "Reads every committed change of its own table in commit order, from the slot's creation to the end of the window, one transaction per page.",
+			Example:     "LOGICAL_DECODING=pgoutput go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "rest_api",
+		run:     fetchWithRestAPI,
+		enabled: restAPI.URL != "",
+		doc: strategyDoc{
+			Summary: "Paginates the REST endpoint API_URL by page and limit or by cursor token and writes its rows through the same sinks.",
+			SQL: func() []string {
+				return []string{"GET " + restPageURL(1, "")}
+			},
+			Consistency: "Whatever the API gives. Page and limit pagination behaves like offset_limit when the API pages with OFFSET.",
+			Example:     "API_URL=http://localhost:8080/accounts go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "refcursor",
+		run:     fetchWithRefcursor,
+		enabled: refcursorEnabled,
+		doc: strategyDoc{
+			Summary: "Calls a server-side function returning a refcursor, REFCURSOR_FUNCTION or a temporary one, and fetches the cursor in batches.",
+			SQL: func() []string {
+				fetch := fetchCursorQuery(`"<unnamed portal 1>"`)
+				if refcursorFunction != "" {
+					return []string{callRefcursorQuery(refcursorFunction), fetch}
+				}
+				return []string{createRefcursorFunctionQuery(), callRefcursorQuery(defaultRefcursorFunction), fetch}
+			},
+			Consistency: "Same as cursor.",
+			Example:     "REFCURSOR=true go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "cursor_scroll",
+		run:     fetchWithScrollCursor,
+		enabled: scrollBackEvery > 0,
+		doc: strategyDoc{
+			Summary: "Fetches a SCROLL cursor forward, stepping back a page with FETCH BACKWARD and returning every SCROLL_BACK_EVERY pages.",
+			SQL: func() []string {
+				return []string{declareScrollCursorQuery("scroll_cursor"), fetchForwardQuery("scroll_cursor"), fetchBackwardQuery("scroll_cursor")}
+			},
+			Consistency: "Same as cursor.",
+			Example:     "SCROLL_BACK_EVERY=3 go run .",
+		},
+	})
+
+	for _, fraction := range cursorTupleFractions {
+		strategies = append(strategies, strategy{
+			name:    cursorFractionStrategy(fraction),
+			run:     fetchWithCursorFraction(fraction),
+			enabled: true,
+			doc: strategyDoc{
+				Summary: fmt.Sprintf("The cursor strategy with its cursor planned for cursor_tuple_fraction %g of the rows.", fraction),
+				SQL: func() []string {
+					return []string{cursorTupleFractionQuery(fraction), declareCursorQuery("ctf_cursor", 0), fetchCursorQuery("ctf_cursor")}
+				},
+				Consistency: "Same as cursor.",
+				Example:     "CURSOR_TUPLE_FRACTIONS=0.01,0.1,1 go run .",
+			},
+		})
+	}
+
+	for _, driver := range drivers {
+		strategies = append(strategies, strategy{
+			name:    driverStrategy(driver),
+			run:     fetchWithDriver(driver),
+			enabled: true,
+			doc: strategyDoc{
+				Summary: fmt.Sprintf("The custom_cursor strategy through database/sql with the %s driver.", driver),
+				SQL: func() []string {
+					return []string{keysetPageQuery(selectList())}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "DRIVERS=stdlib,pq go run .",
+			},
+		})
+	}
+
+	strategies = append(strategies, strategy{
+		name:    "custom_cursor_prefetch",
+		run:     fetchWithPrefetch,
+		enabled: os.Getenv("PREFETCH") == "true",
+		doc: strategyDoc{
+			Summary: "Keyset pagination that queries the next page on a second connection while the current page is written.",
+			SQL: func() []string {
+				return []string{keysetPageQuery(selectList())}
+			},
+			Consistency: "Same as custom_cursor.",
+			Example:     "PREFETCH=true go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "hash_parallel",
+		run:     fetchWithHashParallel,
+		enabled: parallelWorkers > 0,
+		workers: parallelWorkers,
+		doc: strategyDoc{
+			Summary: "PARALLEL_WORKERS workers each keyset paginate over the rows whose aid modulo the worker count is their id.",
+			SQL: func() []string {
+				return []string{hashPageQuery(max(parallelWorkers, 4))}
+			},
+			Consistency: "Same as custom_cursor, for every worker on its own.",
+			Example:     "PARALLEL_WORKERS=4 go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "snapshot_parallel",
+		run:     fetchWithSnapshotParallel,
+		enabled: parallelWorkers > 0 && snapshotParallel,
+		workers: parallelWorkers,
+		doc: strategyDoc{
+			Summary: "The range_parallel strategy with every worker paging in a REPEATABLE READ transaction that imports one snapshot exported by a coordinating transaction.",
+			SQL: func() []string {
+				return []string{exportSnapshotQuery(), keyBoundsQuery(), importSnapshotQuery("00000003-0000001B-1"), rangePageQuery()}
+			},
+			Consistency: "All workers read the snapshot of the coordinating transaction, so the export is one consistent snapshot like copy, at the cost of a connection and an open transaction per worker that hold back vacuum.",
+			Example:     "PARALLEL_WORKERS=4 SNAPSHOT_PARALLEL=true go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "range_parallel",
+		run:     fetchWithRangeParallel,
+		enabled: parallelWorkers > 0,
+		workers: parallelWorkers,
+		doc: strategyDoc{
+			Summary: "PARALLEL_WORKERS workers each keyset paginate over an equal width part of the key range.",
+			SQL: func() []string {
+				return []string{keyBoundsQuery(), rangePageQuery()}
+			},
+			Consistency: "Same as custom_cursor, for every worker on its own.",
+			Example:     "PARALLEL_WORKERS=4 go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "adaptive_parallel",
+		run:     fetchWithAdaptiveParallel,
+		enabled: adaptiveWorkers > 0,
+		workers: adaptiveWorkers,
+		doc: strategyDoc{
+			Summary: "Up to ADAPTIVE_WORKERS workers keyset paginate through chunks of the key range while a controller adds a worker as long as it raises the throughput and halves them once it does not or page latency degrades.",
+			SQL: func() []string {
+				return []string{keyBoundsQuery(), rangePageQuery()}
+			},
+			Consistency: "Same as custom_cursor, for every chunk on its own.",
+			Example:     "ADAPTIVE_WORKERS=16 go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "ctid_range",
+		run:     fetchWithCtidRanges,
+		enabled: ctidBlocks > 0,
+		doc: strategyDoc{
+			Summary: "Pages through the heap by physical position, CTID_BLOCKS blocks per query, with the rows in heap order.",
+			SQL: func() []string {
+				return []string{relationBlocksQuery(), ctidRangeQuery()}
+			},
+			Consistency: "Each range is its own snapshot. A row updated from a block ahead into one already read is missed, one updated the other way is read twice.",
+			Example:     "CTID_BLOCKS=1000 go run .",
+		},
+	})
+
+	strategies = append(strategies, strategy{
+		name:    "minmax_skip",
+		run:     fetchWithMinMaxSkip,
+		enabled: minmaxChunk > 0,
+		doc: strategyDoc{
+			Summary: "A pre-pass finds the min and max aid of every chunk of MINMAX_CHUNK keys, then one range query reads each non-empty chunk and the empty ones are skipped.",
+			SQL: func() []string {
+				return []string{minmaxSummaryQuery(max(minmaxChunk, batchSize)), keyRangeQuery()}
+			},
+			Consistency: "Each range is its own snapshot, rows inserted into a chunk after the pre-pass but outside its range are missed.",
+			Example:     "go run . seed clustered && MINMAX_CHUNK=1000 go run .",
+		},
+	})
+
+	for _, q := range []struct {
+		name    string
+		run     func(context.Context, chan<- Result) error
+		summary string
+		sql     func() []string
+		like    string
+	}{
+		{"query_cursor", fetchQueryWithCursor, "Fetches the QUERY_FILE query in batches from a cursor ordered by the key.",
+			func() []string {
+				return []string{declareQueryCursorQuery("query_cursor"), fetchCursorQuery("query_cursor")}
+			}, "cursor"},
+		{"query_keyset", fetchQueryWithKeyset, "Keyset pagination over the QUERY_FILE query, binding the last key of the previous page to $1 and the page size to $2.",
+			func() []string { return []string{queryPageQuery()} }, "custom_cursor"},
+		{"query_offset", fetchQueryWithOffset, "OFFSET pagination over the QUERY_FILE query ordered by the key.",
+			func() []string { return []string{queryPageQuery()} }, "offset_limit"},
+	} {
+		strategies = append(strategies, strategy{
+			name:    q.name,
+			run:     q.run,
+			enabled: customQuery != "",
+			doc: strategyDoc{
+				Summary:     q.summary,
+				SQL:         q.sql,
+				Consistency: fmt.Sprintf("Same as %s.", q.like),
+				Example:     "QUERY_FILE=query.sql BENCH_KEY=aid go run .",
+			},
+		})
+	}
+
+	for _, table := range keyTables {
+		strategies = append(strategies, strategy{
+			name:    "keyset_" + table.label,
+			run:     fetchKeyTable(table.label, table.name, table.keyType),
+			enabled: os.Getenv("SCENARIO") == "keys",
+			doc: strategyDoc{
+				Summary: fmt.Sprintf("Keyset pagination over %s, keyed by %s, from the key type scenario.", table.name, table.label),
+				SQL: func() []string {
+					return []string{keyTablePageQuery(table.name, table.keyType, false)}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "go run . seed keys && SCENARIO=keys go run .",
+			},
+		})
+	}
+
+	strategies = append(strategies, []strategy{
+		{
+			name:    "keyset_multi",
+			run:     fetchWithKeysetMulti,
+			enabled: len(keysetOrder) > 0,
+			doc: strategyDoc{
+				Summary: "Keyset pagination over a multi-column sort specification with mixed directions.",
+				SQL: func() []string {
+					order := keysetOrder
+					if len(order) == 0 {
+						order, _ = parseSortSpec("bid DESC, aid ASC")
+					}
+					return []string{keysetMultiPageQuery(order, false)}
+				},
+				Consistency: "Same as custom_cursor. The exported rows are checked against the sort specification.",
+				Example:     `KEYSET_ORDER="bid DESC, aid ASC" go run .`,
+			},
+		},
+		{
+			name:    "custom_cursor_index_only",
+			run:     fetchWithIndexOnly,
+			enabled: indexOnlyCompare,
+			doc: strategyDoc{
+				Summary: "Keyset pagination selecting only the primary key, so pages can use index only scans.",
+				SQL: func() []string {
+					return []string{keysetPageQuery(keyName())}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "INDEX_ONLY_COMPARE=true go run .",
+			},
+		},
+		{
+			name:    "cursor_jump",
+			run:     fetchWithCursorJump,
+			enabled: jumpStride > 0,
+			doc: strategyDoc{
+				Summary: "Jumps to every JUMP_STRIDE-th page of an open cursor with MOVE and fetches it.",
+				SQL: func() []string {
+					return []string{
+						declareCursorQuery("jump_cursor", 0),
+						moveCursorQuery("jump_cursor", (max(jumpStride, 2)-1)*batchSize),
+						fetchForwardQuery("jump_cursor"),
+					}
+				},
+				Consistency: "Same as cursor.",
+				Example:     "JUMP_STRIDE=10 go run .",
+			},
+		},
+		{
+			name:    "offset_jump",
+			run:     fetchWithOffsetJump,
+			enabled: jumpStride > 0,
+			doc: strategyDoc{
+				Summary: "Fetches every JUMP_STRIDE-th page with an OFFSET query.",
+				SQL: func() []string {
+					return []string{offsetPageQuery()}
+				},
+				Consistency: "Same as offset_limit.",
+				Example:     "JUMP_STRIDE=10 go run .",
+			},
+		},
+		{
+			name:    "key_array",
+			run:     fetchWithKeyArray,
+			enabled: os.Getenv("KEYS_FILE") != "",
+			doc: strategyDoc{
+				Summary:     "Looks up a list of keys with one = ANY($1) query per batch of keys.",
+				SQL:         func() []string { return []string{keyArrayQuery()} },
+				Consistency: "Each batch of keys sees its own snapshot.",
+				Example:     "KEYS_FILE=keys.txt go run .",
+			},
+		},
+		{
+			name:    "key_point",
+			run:     fetchWithKeyPoint,
+			enabled: os.Getenv("KEYS_FILE") != "",
+			doc: strategyDoc{
+				Summary:     "Looks up a list of keys with one query per key.",
+				SQL:         func() []string { return []string{keyPointQuery()} },
+				Consistency: "Every key sees its own snapshot.",
+				Example:     "KEYS_FILE=keys.txt go run .",
+			},
+		},
+		{
+			name:    "key_join",
+			run:     fetchWithKeyJoin,
+			enabled: os.Getenv("KEYS_FILE") != "",
+			doc: strategyDoc{
+				Summary:     "Copies a list of keys into a temporary table and joins it in one query.",
+				SQL:         func() []string { return []string{keyJoinQuery()} },
+				Consistency: "A single statement, so all keys see one snapshot.",
+				Example:     "KEYS_FILE=keys.txt go run .",
+			},
+		},
+		{
+			name:    "blobs",
+			run:     fetchWithBlobs,
+			enabled: os.Getenv("SCENARIO") == "blobs",
+			doc: strategyDoc{
+				Summary: "Keyset pagination over a table with a bytea column, encoded by BLOB_FORMAT, optionally streaming a large object per row.",
+				SQL: func() []string {
+					return []string{blobPageQuery(false)}
+				},
+				Consistency: "Each page is read in its own transaction, so large objects are read from the same snapshot as their rows.",
+				Example:     "go run . seed blobs && SCENARIO=blobs BLOB_FORMAT=base64 go run .",
+			},
+		},
+	}...)
+
+	strategies = append(strategies,
+		strategy{
+			name:    "tenant_keyset",
+			run:     fetchTenantKeyset,
+			enabled: os.Getenv("SCENARIO") == "tenants",
+			doc: strategyDoc{
+				Summary: "Lists the rows of one tenant of the tenant scenario with keyset pagination on the (tenant_id, id) key.",
+				SQL: func() []string {
+					return []string{tenantPageQuery(false)}
+				},
+				Consistency: "Same as custom_cursor.",
+				Example:     "go run . seed tenants && SCENARIO=tenants go run .",
+			},
+		},
+		strategy{
+			name:    "tenant_offset",
+			run:     fetchTenantOffset,
+			enabled: os.Getenv("SCENARIO") == "tenants",
+			doc: strategyDoc{
+				Summary: "Lists the rows of one tenant of the tenant scenario with OFFSET pagination.",
+				SQL: func() []string {
+					return []string{tenantOffsetQuery()}
+				},
+				Consistency: "Same as offset_limit.",
+				Example:     "go run . seed tenants && SCENARIO=tenants go run .",
+			},
+		},
+	)
+
+	strategies = append(strategies,
+		strategy{
+			name:    "soft_keyset",
+			run:     fetchSoftKeyset,
+			enabled: os.Getenv("SCENARIO") == "softdelete",
+			doc: strategyDoc{
+				Summary: "Keyset pagination over the live rows of the soft delete scenario, using the partial index on deleted_at IS NULL.",
+				SQL: func() []string {
+					return []string{softPageQuery()}
+				},
+				Consistency: "Every page sees its own snapshot. Rows deleted ahead of the current page are left out, rows behind it were already exported, none are repeated or missed.",
+				Example:     "go run . seed softdelete && SCENARIO=softdelete go run .",
+			},
+		},
+		strategy{
+			name:    "soft_offset",
+			run:     fetchSoftOffset,
+			enabled: os.Getenv("SCENARIO") == "softdelete",
+			doc: strategyDoc{
+				Summary: "OFFSET pagination over the live rows of the soft delete scenario.",
+				SQL: func() []string {
+					return []string{softOffsetQuery()}
+				},
+				Consistency: "Every page sees its own snapshot. A row deleted behind the current page shifts the later pages back, so a live row is missed for every such deletion.",
+				Example:     "go run . seed softdelete && SCENARIO=softdelete go run .",
+			},
+		},
+		strategy{
+			name:    "soft_cursor",
+			run:     fetchSoftCursor,
+			enabled: os.Getenv("SCENARIO") == "softdelete",
+			doc: strategyDoc{
+				Summary: "Fetches the live rows of the soft delete scenario from a cursor.",
+				SQL: func() []string {
+					return []string{declareSoftCursorQuery("soft_cursor"), fetchCursorQuery("soft_cursor")}
+				},
+				Consistency: "One snapshot, so rows deleted mid-run are all exported.",
+				Example:     "go run . seed softdelete && SCENARIO=softdelete go run .",
+			},
+		},
+	)
+
+	for _, withDoc := range []bool{true, false} {
+		columns := toastColumns(withDoc)
+		without := ""
+		if !withDoc {
+			without = "out"
+		}
+		strategies = append(strategies,
+			strategy{
+				name:    toastStrategyName("keyset", withDoc),
+				run:     fetchToastKeyset(withDoc),
+				enabled: os.Getenv("SCENARIO") == "toast",
+				doc: strategyDoc{
+					Summary: fmt.Sprintf("Keyset pagination over the TOAST scenario table with%s its TOASTed doc column.", without),
+					SQL: func() []string {
+						return []string{toastPageQuery(columns, false)}
+					},
+					Consistency: "Same as custom_cursor.",
+					Example:     "go run . seed toast && SCENARIO=toast go run .",
+				},
+			},
+			strategy{
+				name:     toastStrategyName("copy", withDoc),
+				run:      fetchToastCopy(withDoc),
+				enabled:  os.Getenv("SCENARIO") == "toast",
+				usesCopy: true,
+				doc: strategyDoc{
+					Summary: fmt.Sprintf("COPY of the TOAST scenario table with%s its TOASTed doc column.", without),
+					SQL: func() []string {
+						return []string{toastCopyCommand(columns)}
+					},
+					Consistency: "Same as copy.",
+					Example:     "go run . seed toast && SCENARIO=toast go run .",
+				},
+			},
+		)
+	}
+
+	return strategies
+}
+
+// selectStrategies returns the named strategies of the enabled ones, in the
+// order they were named. Names prefixed with - are left out instead, and with
+// only such names every other enabled strategy is selected. All unknown names
+// are reported at once.
+func selectStrategies(enabled []strategy, names []string) ([]strategy, error) {
+	var selected []strategy
+	var excluded, unknown []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		i := slices.IndexFunc(enabled, func(s strategy) bool { return s.name == name })
+		switch {
+		case i >= 0 && exclude:
+			excluded = append(excluded, name)
+		case i >= 0:
+			selected = append(selected, enabled[i])
+		case exclude:
+			// Leaving out a strategy that would not run anyway is fine
+			if !slices.ContainsFunc(registeredStrategies(), func(s strategy) bool { return s.name == name }) {
+				unknown = append(unknown, name)
+			}
+		case slices.ContainsFunc(registeredStrategies(), func(s strategy) bool { return s.name == name }):
+			return nil, fmt.Errorf("strategy %s is not enabled, see `explain %s` for how to enable it", name, name)
+		default:
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown strategies %s, `explain` lists them", strings.Join(unknown, ", "))
+	}
+
+	if len(selected) == 0 {
+		selected = enabled
+	}
+	selected = slices.DeleteFunc(slices.Clone(selected), func(s strategy) bool { return slices.Contains(excluded, s.name) })
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no strategies left to run")
+	}
+	return selected, nil
+}
+
+// explainStrategy prints the description of the named strategy, or a list of
+// all strategies when name is empty.
+func explainStrategy(name string) error {
+	strategies := registeredStrategies()
+
+	if name == "" {
+		for _, s := range strategies {
+			fmt.Printf("%-26s %s\n", s.name, s.doc.Summary)
+		}
+		return nil
+	}
+
+	for _, s := range strategies {
+		if s.name != name {
+			continue
+		}
+
+		fmt.Printf("%s\n\n%s\n\nSQL:\n", s.name, s.doc.Summary)
+		for _, statement := range s.doc.SQL() {
+			fmt.Printf("%s\n\n", formatSQL(statement))
+		}
+		fmt.Printf("Consistency:\n  %s\n\nExample:\n  %s\n", s.doc.Consistency, s.doc.Example)
+		return nil
+	}
+
+	return fmt.Errorf("unknown strategy %q", name)
+}
+
+// formatSQL indents every line of a statement by two spaces, dropping the
+// indentation it had in the Go source.
+func formatSQL(statement string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(statement), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSelectStrategies(t *testing.T) {
+	enabled := []strategy{{name: "cursor"}, {name: "offset_limit"}, {name: "custom_cursor"}}
+	tests := []struct {
+		names   string
+		want    []string
+		wantErr string
+	}{
+		{names: "", want: []string{"cursor", "offset_limit", "custom_cursor"}},
+		{names: "custom_cursor,cursor", want: []string{"custom_cursor", "cursor"}},
+		{names: " cursor , ", want: []string{"cursor"}},
+		{names: "-offset_limit", want: []string{"cursor", "custom_cursor"}},
+		{names: "cursor,offset_limit,-offset_limit", want: []string{"cursor"}},
+		// A registered strategy that is not enabled may be left out
+		{names: "-copy", want: []string{"cursor", "offset_limit", "custom_cursor"}},
+		{names: "copy", wantErr: "not enabled"},
+		{names: "nope,cursor,-never", wantErr: "unknown strategies nope, never"},
+		{names: "-cursor,-offset_limit,-custom_cursor", wantErr: "no strategies left"},
+	}
+	for _, tt := range tests {
+		got, err := selectStrategies(enabled, strings.Split(tt.names, ","))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("selectStrategies(%q) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("selectStrategies(%q): %v", tt.names, err)
+			continue
+		}
+		names := make([]string, len(got))
+		for i, s := range got {
+			names[i] = s.name
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("selectStrategies(%q) = %v, want %v", tt.names, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sweepMaxWorkers is the most workers sweep runs the parallel strategy with.
+var sweepMaxWorkers = 16
+
+// sweepKneeGain is the share of throughput doubling the workers has to add
+// for the curve to count as still rising.
+const sweepKneeGain = 0.25
+
+// SweepPoint is the throughput of the parallel strategy at a worker count.
+type SweepPoint struct {
+	Workers       int           `json:"workers"`
+	Seconds       float64       `json:"seconds"`
+	Rows          int           `json:"rows"`
+	RowsPerSecond float64       `json:"rows_per_second"`
+	P95           time.Duration `json:"p95_page_ns"`
+}
+
+// SweepReport is the saturation curve of a parallel strategy, written to
+// sweep.json.
+type SweepReport struct {
+	Strategy string       `json:"strategy"`
+	Points   []SweepPoint `json:"points"`
+	// Knee is the worker count after which more workers stop paying off,
+	// the first whose next count added less than sweepKneeGain throughput
+	// per doubling. It is the last count when the curve never flattened.
+	Knee int `json:"knee"`
+	// Saturated is whether the curve flattened within the sweep.
+	Saturated bool `json:"saturated"`
+}
+
+// sweepStrategies are the parallel strategies sweep can run.
+var sweepStrategies = map[string]func(context.Context, chan<- Result) error{
+	"range_parallel": fetchWithRangeParallel,
+	"hash_parallel":  fetchWithHashParallel,
+}
+
+// loadSweep reads SWEEP_MAX_WORKERS.
+func loadSweep() error {
+	if w := os.Getenv("SWEEP_MAX_WORKERS"); w != "" {
+		workers, err := strconv.Atoi(w)
+		if err != nil || workers < 2 {
+			return fmt.Errorf("SWEEP_MAX_WORKERS must be a number of at least 2: %s", w)
+		}
+		sweepMaxWorkers = workers
+	}
+	return nil
+}
+
+// sweepCounts returns the worker counts of a sweep, doubling from one up to
+// most, which ends the sweep even when it is no power of two.
+func sweepCounts(most int) []int {
+	var counts []int
+	for workers := 1; workers < most; workers *= 2 {
+		counts = append(counts, workers)
+	}
+	return append(counts, most)
+}
+
+// runSweep runs the parallel strategy once with every worker count of the
+// sweep, one after another.
+func runSweep(ctx context.Context, strategy string) (SweepReport, error) {
+	run, ok := sweepStrategies[strategy]
+	if !ok {
+		return SweepReport{}, fmt.Errorf("sweep runs range_parallel or hash_parallel, not %s", strategy)
+	}
+
+	saved := parallelWorkers
+	defer func() { parallelWorkers = saved }()
+
+	report := SweepReport{Strategy: strategy}
+	for _, workers := range sweepCounts(sweepMaxWorkers) {
+		fmt.Printf("running %s with %d workers\n", strategy, workers)
+		parallelWorkers = workers
+
+		res := make(chan Result, 1)
+		wg.Add(1)
+		progress.start(strategy)
+		run(ctx, res)
+		result := <-res
+		progress.done(result)
+		if result.Err != nil {
+			return report, fmt.Errorf("%s with %d workers failed: %w", strategy, workers, result.Err)
+		}
+
+		point := SweepPoint{Workers: workers, Seconds: result.Duration.Seconds()}
+		latencies := make([]time.Duration, len(result.Batches))
+		for i, b := range result.Batches {
+			point.Rows += b.Rows
+			latencies[i] = b.Duration
+		}
+		if result.Duration > 0 {
+			point.RowsPerSecond = float64(point.Rows) / result.Duration.Seconds()
+		}
+		point.P95 = percentile(latencies, 95)
+		report.Points = append(report.Points, point)
+	}
+
+	report.Knee, report.Saturated = sweepKnee(report.Points)
+	return report, nil
+}
+
+// sweepKnee returns the worker count of the knee of the curve and whether
+// the curve flattened after it.
+func sweepKnee(points []SweepPoint) (int, bool) {
+	if len(points) == 0 {
+		return 0, false
+	}
+	for i := 1; i < len(points); i++ {
+		prev, p := points[i-1], points[i]
+		if prev.RowsPerSecond == 0 {
+			continue
+		}
+		// Scale the gain to a doubling, the last step may be a smaller one
+		doublings := math.Log2(float64(p.Workers) / float64(prev.Workers))
+		if math.Pow(p.RowsPerSecond/prev.RowsPerSecond, 1/doublings)-1 < sweepKneeGain {
+			return prev.Workers, true
+		}
+	}
+	return points[len(points)-1].Workers, false
+}
+
+// printSweepReport prints the saturation curve with the knee marked.
+func printSweepReport(report SweepReport) {
+	var best float64
+	for _, p := range report.Points {
+		best = max(best, p.RowsPerSecond)
+	}
+
+	fmt.Printf("%7s %14s %8s %10s\n", "workers", "rows/sec", "speedup", "p95 page")
+	for _, p := range report.Points {
+		speedup := 0.0
+		if first := report.Points[0].RowsPerSecond; first > 0 {
+			speedup = p.RowsPerSecond / first
+		}
+		bar := ""
+		if best > 0 {
+			bar = strings.Repeat("#", int(p.RowsPerSecond/best*40))
+		}
+		line := fmt.Sprintf("%7d %14s %7.1fx %10s  %s", p.Workers, human.Float(p.RowsPerSecond, 0), speedup, human.Duration(p.P95), bar)
+		if p.Workers == report.Knee {
+			line += " <- knee"
+		}
+		fmt.Println(line)
+	}
+
+	switch {
+	case report.Knee == 0:
+	case report.Saturated:
+		fmt.Printf("%s saturates at %d workers, doubling them adds less than %.0f%% throughput beyond\n",
+			report.Strategy, report.Knee, sweepKneeGain*100)
+	default:
+		fmt.Printf("%s still scales at %d workers, raise SWEEP_MAX_WORKERS to find its knee\n", report.Strategy, report.Knee)
+	}
+	if conns := int(pool.Config().MaxConns); sweepMaxWorkers > conns {
+		fmt.Printf("workers beyond the pool's %d connections wait for one, see POOL_MAX_CONNS\n", conns)
+	}
+}
+
+func writeSweepReport(path string, report SweepReport) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding sweep results: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing sweep results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSweepCounts(t *testing.T) {
+	tests := []struct {
+		most int
+		want []int
+	}{
+		{2, []int{1, 2}},
+		{8, []int{1, 2, 4, 8}},
+		{12, []int{1, 2, 4, 8, 12}},
+	}
+	for _, tt := range tests {
+		if got := sweepCounts(tt.most); !slices.Equal(got, tt.want) {
+			t.Errorf("sweepCounts(%d) = %v, want %v", tt.most, got, tt.want)
+		}
+	}
+}
+
+func TestSweepKnee(t *testing.T) {
+	points := func(workersAndRates ...float64) []SweepPoint {
+		var p []SweepPoint
+		for i := 0; i < len(workersAndRates); i += 2 {
+			p = append(p, SweepPoint{Workers: int(workersAndRates[i]), RowsPerSecond: workersAndRates[i+1]})
+		}
+		return p
+	}
+	tests := []struct {
+		name          string
+		points        []SweepPoint
+		knee          int
+		wantSaturated bool
+	}{
+		{"empty", nil, 0, false},
+		{"still scaling", points(1, 100, 2, 190, 4, 360), 4, false},
+		{"flattens", points(1, 100, 2, 190, 4, 220, 8, 230), 2, true},
+		{"drops", points(1, 100, 2, 80), 1, true},
+		// 8 to 12 workers is 0.58 doublings, 1.15 times the rows is 27% per doubling
+		{"short last step", points(1, 100, 2, 200, 4, 400, 8, 800, 12, 920), 12, false},
+		{"short last step flattens", points(1, 100, 2, 200, 4, 400, 8, 800, 12, 880), 8, true},
+		{"no rows", points(1, 0, 2, 100, 4, 110), 2, true},
+	}
+	for _, tt := range tests {
+		knee, saturated := sweepKnee(tt.points)
+		if knee != tt.knee || saturated != tt.wantSaturated {
+			t.Errorf("%s: sweepKnee = %d, %v, want %d, %v", tt.name, knee, saturated, tt.knee, tt.wantSaturated)
+		}
+	}
+}
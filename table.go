@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The table the strategies read and the columns they export. The key must be
+// a unique, indexed integer column, since the strategies page and bound the
+// run by it.
+var (
+	benchSchema string
+	benchTable  = "pgbench_accounts"
+	keyColumn   = "aid"
+	projection  = []string{"aid", "bid", "abalance"}
+)
+
+// customTable is set when the strategies read something other than the
+// pgbench columns, whose rows are then scanned generically.
+var customTable bool
+
+// keyIndex is the position of the key column in the projection.
+var keyIndex int
+
+// loadTable reads BENCH_SCHEMA, BENCH_TABLE, BENCH_KEY and BENCH_COLUMNS. The
+// key is exported first when the column list leaves it out.
+func loadTable() error {
+	benchSchema = os.Getenv("BENCH_SCHEMA")
+	if t := os.Getenv("BENCH_TABLE"); t != "" {
+		benchTable = t
+	}
+	if k := os.Getenv("BENCH_KEY"); k != "" {
+		keyColumn = k
+	}
+	if c := os.Getenv("BENCH_COLUMNS"); c != "" {
+		projection = nil
+		for _, column := range strings.Split(c, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				projection = append(projection, column)
+			}
+		}
+	}
+	if !slices.Contains(projection, keyColumn) {
+		projection = append([]string{keyColumn}, projection...)
+	}
+	useProjection(projection)
+
+	if customTable && os.Getenv("KEYSET_ORDER") != "" {
+		return fmt.Errorf("KEYSET_ORDER only works with the pgbench columns")
+	}
+	return nil
+}
+
+// useProjection makes the strategies export the columns, which must include
+// the key.
+func useProjection(columns []string) {
+	projection = columns
+	keyIndex = slices.Index(projection, keyColumn)
+	customTable = benchSchema != "" || benchTable != "pgbench_accounts" ||
+		!slices.Equal(projection, []string{"aid", "bid", "abalance"})
+}
+
+var plainIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// quoteIdent quotes an identifier unless it is a plain lower case name, so the
+// SQL of the default table reads as written.
+func quoteIdent(name string) string {
+	if plainIdentifier.MatchString(name) {
+		return name
+	}
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// tableName is the quoted, optionally schema qualified benchmark table.
+func tableName() string {
+	if benchSchema != "" {
+		return quoteIdent(benchSchema) + "." + quoteIdent(benchTable)
+	}
+	return quoteIdent(benchTable)
+}
+
+func keyName() string {
+	return quoteIdent(keyColumn)
+}
+
+// selectList is the quoted projection of the strategies' queries.
+func selectList() string {
+	return quoteColumns(projection)
+}
+
+// quoteColumns quotes the columns and joins them into a list.
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// scanner is the part of pgx.Rows scanRecord needs.
+type scanner interface {
+	Scan(dest ...any) error
+	Values() ([]any, error)
+}
+
+// scanRecord scans a row of the projection into its CSV record and returns its
+// key.
+func scanRecord(rows scanner) ([]string, int, error) {
+	if !customTable {
+		var aid, bid, abalance int
+		if err := rows.Scan(&aid, &bid, &abalance); err != nil {
+			return nil, 0, err
+		}
+		return []string{
+			fmt.Sprintf("%d", aid),
+			fmt.Sprintf("%d", bid),
+			fmt.Sprintf("%d", abalance),
+		}, aid, nil
+	}
+
+	values, err := rows.Values()
+	if err != nil {
+		return nil, 0, err
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatValue(v)
+	}
+	key, err := integerKey(values[keyIndex])
+	if err != nil {
+		return nil, 0, err
+	}
+	return record, key, nil
+}
+
+// scanSQLRecord is scanRecord for database/sql rows, which scan any column
+// into a string.
+func scanSQLRecord(rows *sql.Rows) ([]string, int, error) {
+	if !customTable {
+		return scanRecord(sqlScanner{rows})
+	}
+
+	values := make([]sql.NullString, len(projection))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, 0, err
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = v.String
+	}
+	var key int
+	if _, err := fmt.Sscan(record[keyIndex], &key); err != nil {
+		return nil, 0, fmt.Errorf("key column %s must be an integer: %v", keyColumn, err)
+	}
+	return record, key, nil
+}
+
+type sqlScanner struct {
+	*sql.Rows
+}
+
+func (s sqlScanner) Values() ([]any, error) {
+	return nil, fmt.Errorf("database/sql rows have no values")
+}
+
+func integerKey(v any) (int, error) {
+	switch k := v.(type) {
+	case int64:
+		return int(k), nil
+	case int32:
+		return int(k), nil
+	case int16:
+		return int(k), nil
+	}
+	return 0, fmt.Errorf("key column %s must be an integer, got %T", keyColumn, v)
+}
+
+// formatValue formats a scanned value for the CSV output: bytea in the hex
+// format of Postgres, timestamps in RFC 3339 and uuids in their usual form.
+func formatValue(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return fmt.Sprintf(`\x%x`, value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", value[0:4], value[4:6], value[6:8], value[8:10], value[10:16])
+	case driver.Valuer:
+		if v, err := value.Value(); err == nil {
+			return formatValue(v)
+		}
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprint(v)
+}
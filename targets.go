@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Target is a database the benchmark is repeated against, such as a primary
+// and its replica.
+type Target struct {
+	Name string
+	DSN  string
+	// Settings are set on every connection to the target.
+	Settings []sessionSetting
+}
+
+// targets are the databases from TARGETS, empty to only run against DB_*.
+var targets []Target
+
+// parseTargets parses a comma separated list of name=dsn pairs. The name
+// becomes the output directory of the target's run.
+func parseTargets(spec string) ([]Target, error) {
+	var parsed []Target
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		name, dsn, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || dsn == "" {
+			return nil, fmt.Errorf("expected name=dsn, got %q", entry)
+		}
+		if strings.ContainsAny(name, `/\.`) {
+			return nil, fmt.Errorf("target name %q must not contain a path", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate target %s", name)
+		}
+		seen[name] = true
+		parsed = append(parsed, Target{Name: name, DSN: dsn})
+	}
+	return parsed, nil
+}
+
+// targetRun holds the results of the benchmark against one target.
+type targetRun struct {
+	Target  Target
+	Results []Result
+}
+
+// runTargets repeats the benchmark against every target in turn, each writing
+// its files to a directory named after the target, and then compares the
+// strategy timings across the targets.
+func runTargets(ctx context.Context, targets []Target) error {
+	base := outputDir
+	defer func() { outputDir = base }()
+
+	var runs []targetRun
+	for _, target := range targets {
+		fmt.Printf("target %s\n", target.Name)
+
+		missing, err := missingSettings(ctx, target.DSN, target.Settings)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", target.Name, err)
+		}
+		if len(missing) > 0 {
+			fmt.Printf("skipping target %s, the server has no %s\n", target.Name, strings.Join(missing, ", "))
+			continue
+		}
+
+		sessionSettings = target.Settings
+		p, err := newPool(ctx, target.DSN)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", target.Name, err)
+		}
+
+		outputDir = outputPath(target.Name)
+		if err := os.MkdirAll(outputDir, 0o755); err != nil {
+			p.Close()
+			return fmt.Errorf("error creating output directory: %v", err)
+		}
+
+		// The strategies all read from the global pool
+		previous, previousDSN := pool, poolDSN
+		pool, poolDSN = p, target.DSN
+		results, err := runBenchmark(ctx)
+		pool, poolDSN = previous, previousDSN
+		p.Close()
+		sessionSettings = nil
+		if err != nil {
+			return fmt.Errorf("target %s: %w", target.Name, err)
+		}
+
+		runs = append(runs, targetRun{Target: target, Results: results})
+	}
+
+	compareTargets(runs)
+	return nil
+}
+
+// compareTargets prints the seconds of every strategy per target, with the
+// change of each target against the first.
+func compareTargets(runs []targetRun) {
+	if len(runs) == 0 {
+		return
+	}
+
+	var names []string
+	seconds := make(map[string]map[string]string)
+	change := make(map[string]map[string]string)
+	for _, run := range runs {
+		for _, r := range run.Results {
+			if _, ok := seconds[r.Type]; !ok {
+				names = append(names, r.Type)
+				seconds[r.Type] = make(map[string]string)
+				change[r.Type] = make(map[string]string)
+			}
+			if r.Err != nil {
+				seconds[r.Type][run.Target.Name] = "failed"
+				continue
+			}
+			seconds[r.Type][run.Target.Name] = fmt.Sprintf("%.2fs", r.Duration.Seconds())
+		}
+	}
+
+	// The first target is the baseline the others are compared against
+	first := make(map[string]Result)
+	for _, r := range runs[0].Results {
+		first[r.Type] = r
+	}
+	for _, run := range runs[1:] {
+		for _, r := range run.Results {
+			b, ok := first[r.Type]
+			if !ok || b.Err != nil || r.Err != nil || b.Duration <= 0 {
+				continue
+			}
+			change[r.Type][run.Target.Name] = fmt.Sprintf(" (%+.1f%%)", 100*(r.Duration.Seconds()-b.Duration.Seconds())/b.Duration.Seconds())
+		}
+	}
+
+	fmt.Printf("%-26s", "strategy")
+	for _, run := range runs {
+		fmt.Printf(" %18s", run.Target.Name)
+	}
+	fmt.Println()
+	for _, name := range names {
+		fmt.Printf("%-26s", name)
+		for _, run := range runs {
+			value, ok := seconds[name][run.Target.Name]
+			if !ok {
+				value = "-"
+			}
+			fmt.Printf(" %18s", value+change[name][run.Target.Name])
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Target
+		wantErr bool
+	}{
+		{spec: "pg16=postgres://a/db", want: []Target{{Name: "pg16", DSN: "postgres://a/db"}}},
+		{
+			spec: "old=postgres://a/db?sslmode=disable, new=host=b dbname=db",
+			want: []Target{{Name: "old", DSN: "postgres://a/db?sslmode=disable"}, {Name: "new", DSN: "host=b dbname=db"}},
+		},
+		{spec: "postgres://a/db", wantErr: true},
+		{spec: "=postgres://a/db", wantErr: true},
+		{spec: "pg16=", wantErr: true},
+		{spec: "../pg16=postgres://a/db", wantErr: true},
+		{spec: "pg.16=postgres://a/db", wantErr: true},
+		{spec: "a=postgres://a/db,a=postgres://b/db", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTargets(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTargets(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTargets(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// thinkTime emulates a client pausing between page requests. The pause is drawn
+// from a uniform distribution over [0, 2*mean] or an exponential distribution
+// with the given mean, so both have the same average.
+type thinkTime struct {
+	mean time.Duration
+	dist string
+}
+
+func parseThinkTime(mean, dist string) (thinkTime, error) {
+	var t thinkTime
+	if mean == "" {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(mean)
+	if err != nil {
+		return t, fmt.Errorf("invalid think time: %w", err)
+	}
+	t.mean = d
+
+	switch dist {
+	case "", "uniform":
+		t.dist = "uniform"
+	case "exponential":
+		t.dist = dist
+	default:
+		return t, fmt.Errorf("unknown think time distribution %q", dist)
+	}
+
+	return t, nil
+}
+
+func (t thinkTime) next() time.Duration {
+	if t.mean <= 0 {
+		return 0
+	}
+	if t.dist == "exponential" {
+		return time.Duration(rand.ExpFloat64() * float64(t.mean))
+	}
+	return time.Duration(rand.Int64N(int64(2*t.mean) + 1))
+}
+
+// Sleep pauses for the next think time and returns how long it slept.
+func (t thinkTime) Sleep(ctx context.Context) time.Duration {
+	d := t.next()
+	if d == 0 {
+		return 0
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	return time.Since(start)
+}
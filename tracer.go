@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryTimings accumulates the wire-level timings of the queries issued with a
+// context returned by traceQueries.
+type QueryTimings struct {
+	Queries int
+	// Prepare is the time spent in parse and describe round trips. pgx
+	// prepares every distinct SQL text before executing it.
+	Prepare time.Duration
+	// Query runs from sending a query until its rows were closed, which
+	// includes Prepare and Write.
+	Query time.Duration
+	// Write is the time spent handing rows to the sink while the query was
+	// still open.
+	Write time.Duration
+
+	// The slowest query of the batch, which a slow batch explains
+	slowest     time.Duration
+	slowestSQL  string
+	slowestArgs []any
+}
+
+// WriteRow writes the record to the sink, charging the time to Write so it can
+// be told apart from the time spent waiting on the server.
+func (t *QueryTimings) WriteRow(out rowSink, record []string) (int, error) {
+	start := time.Now()
+	n, err := out.WriteRow(record)
+	if t != nil {
+		t.Write += time.Since(start)
+	}
+	return n, err
+}
+
+// timingsFrom returns the timings carried by ctx, or nil.
+func timingsFrom(ctx context.Context) *QueryTimings {
+	timings, _ := ctx.Value(timingsKey{}).(*QueryTimings)
+	return timings
+}
+
+type timingsKey struct{}
+type traceStartKey struct{}
+type traceQueryKey struct{}
+
+// traceQueries returns a context whose queries are timed into the returned
+// QueryTimings.
+func traceQueries(ctx context.Context) (context.Context, *QueryTimings) {
+	timings := &QueryTimings{}
+	return context.WithValue(ctx, timingsKey{}, timings), timings
+}
+
+// queryTracer implements the pgx tracer interfaces and records durations into
+// the QueryTimings carried by the query context, if any.
+type queryTracer struct{}
+
+func traceStart(ctx context.Context) context.Context {
+	if ctx.Value(timingsKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceStartKey{}, time.Now())
+}
+
+func traceEnd(ctx context.Context, record func(t *QueryTimings, d time.Duration)) {
+	timings, ok := ctx.Value(timingsKey{}).(*QueryTimings)
+	if !ok {
+		return
+	}
+	start, ok := ctx.Value(traceStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	record(timings, time.Since(start))
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx = traceStart(ctx)
+	if ctx.Value(timingsKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceQueryKey{}, data)
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryEndData) {
+	traceEnd(ctx, func(t *QueryTimings, d time.Duration) {
+		t.Queries++
+		t.Query += d
+
+		if query, ok := ctx.Value(traceQueryKey{}).(pgx.TraceQueryStartData); ok && d > t.slowest {
+			t.slowest = d
+			t.slowestSQL = query.SQL
+			t.slowestArgs = queryArgs(query.Args)
+		}
+	})
+}
+
+// queryArgs returns the arguments of a query without the pgx options that
+// may precede them, such as a QueryExecMode.
+func queryArgs(args []any) []any {
+	for len(args) > 0 {
+		switch args[0].(type) {
+		case pgx.QueryExecMode, pgx.QueryResultFormats, pgx.QueryResultFormatsByOID:
+			args = args[1:]
+		default:
+			return args
+		}
+	}
+	return args
+}
+
+func (queryTracer) TracePrepareStart(ctx context.Context, _ *pgx.Conn, _ pgx.TracePrepareStartData) context.Context {
+	return traceStart(ctx)
+}
+
+func (queryTracer) TracePrepareEnd(ctx context.Context, _ *pgx.Conn, data pgx.TracePrepareEndData) {
+	if data.AlreadyPrepared {
+		return
+	}
+	traceEnd(ctx, func(t *QueryTimings, d time.Duration) {
+		t.Prepare += d
+	})
+}
+
+func (queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return traceStart(ctx)
+}
+
+func (queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchQueryData) {
+	if timings, ok := ctx.Value(timingsKey{}).(*QueryTimings); ok {
+		timings.Queries++
+	}
+}
+
+func (queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchEndData) {
+	traceEnd(ctx, func(t *QueryTimings, d time.Duration) {
+		t.Query += d
+	})
+}
+
+// writeBatchTimings saves the per-batch timings of a strategy as CSV. Server
+// time is the part of the queries not spent preparing or writing rows, which
+// covers execution, transfer and decoding of the rows.
+func writeBatchTimings(path string, batches []Batch) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"seq", "start", "key", "rows", "duration_ms", "queries", "prepare_ms", "server_ms", "write_ms", "trace_id"})
+	for _, b := range batches {
+		writer.Write([]string{
+			fmt.Sprintf("%d", b.Seq),
+			b.Start.Format(time.RFC3339Nano),
+			b.Key,
+			fmt.Sprintf("%d", b.Rows),
+			milliseconds(b.Duration),
+			fmt.Sprintf("%d", b.Queries),
+			milliseconds(b.Prepare),
+			milliseconds(b.Query - b.Prepare - b.Write),
+			milliseconds(b.Write),
+			b.TraceID,
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing record to CSV: %v", err)
+	}
+	return file.Close()
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validationRules are checked against every exported row. Rules of columns a
+// strategy does not export are ignored for it.
+var validationRules []validationRule
+
+// writeRejects copies the rows that break a rule to a rejects file per
+// strategy.
+var writeRejects bool
+
+type validationRule struct {
+	Column string
+	// Kind is notnull, range or regex.
+	Kind     string
+	Min, Max *float64
+	Pattern  *regexp.Regexp
+	text     string
+}
+
+// parseValidationRules parses VALIDATION_RULES, a semicolon separated list of
+// column:notnull, column:range=min..max, where either bound may be left out,
+// and column:regex=pattern.
+func parseValidationRules(value string) ([]validationRule, error) {
+	var rules []validationRule
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		column, check, ok := strings.Cut(spec, ":")
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid validation rule %q, expected column:rule", spec)
+		}
+		kind, arg, _ := strings.Cut(check, "=")
+		rule := validationRule{Column: strings.TrimSpace(column), Kind: strings.TrimSpace(kind), text: spec}
+
+		switch rule.Kind {
+		case "notnull":
+		case "range":
+			low, high, ok := strings.Cut(arg, "..")
+			if !ok {
+				return nil, fmt.Errorf("invalid range in validation rule %q, expected min..max", spec)
+			}
+			var err error
+			if rule.Min, err = parseBound(low); err != nil {
+				return nil, fmt.Errorf("invalid range in validation rule %q: %w", spec, err)
+			}
+			if rule.Max, err = parseBound(high); err != nil {
+				return nil, fmt.Errorf("invalid range in validation rule %q: %w", spec, err)
+			}
+		case "regex":
+			pattern, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern in validation rule %q: %w", spec, err)
+			}
+			rule.Pattern = pattern
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q in %q, expected notnull, range or regex", rule.Kind, spec)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseBound(s string) (*float64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// check reports whether the value passes the rule. Null values are exported
+// as empty strings and only break notnull.
+func (r validationRule) check(value string) bool {
+	if value == "" {
+		return r.Kind != "notnull"
+	}
+	switch r.Kind {
+	case "range":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+	case "regex":
+		return r.Pattern.MatchString(value)
+	}
+	return true
+}
+
+// ValidationStats counts the rows that broke the validation rules.
+type ValidationStats struct {
+	Rows int
+	// Rules counts the violations of every rule.
+	Rules map[string]int
+}
+
+// Summary lists the violated rules by their number of violations.
+func (s *ValidationStats) Summary() string {
+	rules := make([]string, 0, len(s.Rules))
+	for rule := range s.Rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if s.Rules[rules[i]] != s.Rules[rules[j]] {
+			return s.Rules[rules[i]] > s.Rules[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		parts[i] = fmt.Sprintf("%s %d", rule, s.Rules[rule])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validatingSink checks the rows written to a sink against the validation
+// rules. The rows are exported either way.
+type validatingSink struct {
+	rowSink
+	name    string
+	columns []int
+	rules   []validationRule
+	stats   ValidationStats
+
+	rejects     *os.File
+	rejectsFile *csv.Writer
+}
+
+func newValidatingSink(sink rowSink, name string) *validatingSink {
+	return &validatingSink{rowSink: sink, name: name, stats: ValidationStats{Rules: map[string]int{}}}
+}
+
+func (s *validatingSink) WriteHeader(columns []string) error {
+	for _, rule := range validationRules {
+		for i, column := range columns {
+			if column == rule.Column {
+				s.rules = append(s.rules, rule)
+				s.columns = append(s.columns, i)
+			}
+		}
+	}
+
+	if writeRejects && len(s.rules) > 0 {
+		file, err := os.Create(outputPath(s.name + ".rejects.csv"))
+		if err != nil {
+			return fmt.Errorf("error creating rejects file: %v", err)
+		}
+		s.rejects = file
+		s.rejectsFile = csv.NewWriter(file)
+		if err := s.rejectsFile.Write(append(append([]string(nil), columns...), "violations")); err != nil {
+			return fmt.Errorf("error writing rejects file: %v", err)
+		}
+	}
+	return s.rowSink.WriteHeader(columns)
+}
+
+func (s *validatingSink) WriteRow(record []string) (int, error) {
+	var violations []string
+	for i, rule := range s.rules {
+		if !rule.check(record[s.columns[i]]) {
+			violations = append(violations, rule.text)
+			s.stats.Rules[rule.text]++
+		}
+	}
+	if len(violations) > 0 {
+		s.stats.Rows++
+		if s.rejectsFile != nil {
+			if err := s.rejectsFile.Write(append(append([]string(nil), record...), strings.Join(violations, "; "))); err != nil {
+				return 0, fmt.Errorf("error writing rejects file: %v", err)
+			}
+		}
+	}
+	return s.rowSink.WriteRow(record)
+}
+
+func (s *validatingSink) Finalize() error {
+	if s.rejectsFile != nil {
+		s.rejectsFile.Flush()
+		if err := s.rejectsFile.Error(); err != nil {
+			return fmt.Errorf("error writing rejects file: %v", err)
+		}
+		if err := s.rejects.Close(); err != nil {
+			return fmt.Errorf("error closing rejects file: %v", err)
+		}
+		s.rejectsFile = nil
+	}
+	return s.rowSink.Finalize()
+}
+
+func (s *validatingSink) Close() error {
+	if s.rejectsFile != nil {
+		s.rejects.Close()
+	}
+	return s.rowSink.Close()
+}
+
+func (s *validatingSink) Stats() WriteStats {
+	stats := s.rowSink.Stats()
+	stats.Validation = &s.stats
+	return stats
+}
@@ -0,0 +1,60 @@
+package main
+
+// valuePooling makes the strategies that hold whole pages in memory share
+// repeated values within a page and keep the page's records in one slab.
+var valuePooling bool
+
+// PoolStats compares the bytes of the values of in-memory pages with and
+// without value pooling. It counts the bytes of the strings alone, not the
+// string headers, slices and map a page needs besides, so it tells how much
+// pooling saves on values rather than what a page takes in memory.
+type PoolStats struct {
+	Values int
+	Unique int
+	// ValueBytes is the length of all values, PooledValueBytes the length of
+	// the values the pooled pages actually keep.
+	ValueBytes       int64
+	PooledValueBytes int64
+}
+
+func (s *PoolStats) add(other PoolStats) {
+	s.Values += other.Values
+	s.Unique += other.Unique
+	s.ValueBytes += other.ValueBytes
+	s.PooledValueBytes += other.PooledValueBytes
+}
+
+// valuePool interns the values of one page, so equal values share their
+// bytes, and lays out the page's records back to back in one slab instead of
+// a slice per row. Pages are exported as a whole, so the pool lives and dies
+// with its page.
+type valuePool struct {
+	values map[string]string
+	slab   []string
+	stats  PoolStats
+}
+
+func newValuePool() *valuePool {
+	return &valuePool{
+		values: make(map[string]string),
+		slab:   make([]string, 0, batchSize*len(projection)),
+	}
+}
+
+// add stores the record in the pool and returns the pooled copy.
+func (p *valuePool) add(record []string) []string {
+	start := len(p.slab)
+	for _, v := range record {
+		pooled, ok := p.values[v]
+		if !ok {
+			pooled = v
+			p.values[v] = v
+			p.stats.Unique++
+			p.stats.PooledValueBytes += int64(len(v))
+		}
+		p.slab = append(p.slab, pooled)
+		p.stats.ValueBytes += int64(len(v))
+	}
+	p.stats.Values += len(record)
+	return p.slab[start:len(p.slab):len(p.slab)]
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// duckdbDatabase adds load_duckdb, which loads LOAD_FILE into the DuckDB
+// database file with the duckdb command line client duckdbCLI, and
+// clickhouseURL adds load_clickhouse, which loads it into ClickHouse over its
+// HTTP interface. Neither needs a driver built into the tool.
+var (
+	duckdbDatabase string
+	duckdbCLI      = "duckdb"
+	clickhouseURL  string
+)
+
+// clickhouseTimeout bounds a request to ClickHouse, which for the insert
+// includes sending the whole load file.
+const clickhouseTimeout = time.Hour
+
+// loadWarehouses reads DUCKDB_DATABASE, DUCKDB_CLI and CLICKHOUSE_URL.
+func loadWarehouses() error {
+	duckdbDatabase = os.Getenv("DUCKDB_DATABASE")
+	if cli := os.Getenv("DUCKDB_CLI"); cli != "" {
+		duckdbCLI = cli
+	}
+
+	clickhouseURL = os.Getenv("CLICKHOUSE_URL")
+	if clickhouseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(clickhouseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("CLICKHOUSE_URL must be an http or https URL, e.g. http://localhost:8123: %s", clickhouseURL)
+	}
+	if exportCSV.quote != '"' && exportCSV.quote != '\'' {
+		return fmt.Errorf("ClickHouse reads CSV quoted with \" or ' only, not with CSV_QUOTE %c", exportCSV.quote)
+	}
+	return nil
+}
+
+// warehouseType is the column type a Postgres column loads as in DuckDB and
+// ClickHouse.
+type warehouseType struct {
+	duckdb     string
+	clickhouse string
+}
+
+// warehouseTypes are the types of the Postgres types with an equivalent. The
+// exports write dates and timestamps in RFC 3339, which both read into a
+// timestamp in UTC, and every other type as text.
+var warehouseTypes = map[uint32]warehouseType{
+	pgtype.BoolOID:        {"BOOLEAN", "Bool"},
+	pgtype.Int2OID:        {"SMALLINT", "Int16"},
+	pgtype.Int4OID:        {"INTEGER", "Int32"},
+	pgtype.Int8OID:        {"BIGINT", "Int64"},
+	pgtype.Float4OID:      {"REAL", "Float32"},
+	pgtype.Float8OID:      {"DOUBLE", "Float64"},
+	pgtype.UUIDOID:        {"UUID", "UUID"},
+	pgtype.DateOID:        {"TIMESTAMPTZ", "Date32"},
+	pgtype.TimestampOID:   {"TIMESTAMPTZ", "DateTime64(6, 'UTC')"},
+	pgtype.TimestamptzOID: {"TIMESTAMPTZ", "DateTime64(6, 'UTC')"},
+}
+
+var warehouseText = warehouseType{"VARCHAR", "String"}
+
+// loadColumnTypes returns the warehouse types of the columns of the
+// benchmark table.
+func loadColumnTypes(ctx context.Context, columns []string) ([]warehouseType, error) {
+	rows, err := pool.Query(ctx, fmt.Sprintf("SELECT %s FROM %s LIMIT 0", quoteColumns(columns), tableName()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", tableName(), err)
+	}
+	fields := rows.FieldDescriptions()
+	rows.Close()
+
+	types := make([]warehouseType, len(fields))
+	for i, field := range fields {
+		t, ok := warehouseTypes[field.DataTypeOID]
+		if !ok {
+			t = warehouseText
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+// lastCount returns the number on the last line of out, the count a query of
+// a warehouse ended with.
+func lastCount(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	count, err := strconv.ParseInt(strings.TrimSpace(lines[len(lines)-1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected row count %q", strings.TrimSpace(out))
+	}
+	return count, nil
+}
+
+func duckdbIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func duckdbCreateTableQuery(table string, columns []string, types []warehouseType) string {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = duckdbIdent(column) + " " + types[i].duckdb
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s; CREATE TABLE %s (%s);",
+		duckdbIdent(table), duckdbIdent(table), strings.Join(definitions, ", "))
+}
+
+// duckdbLoadQuery inserts the load file into table, read as text in the CSV
+// dialect of the exports and cast to the column types by the insert, and
+// counts the rows of the table.
+func duckdbLoadQuery(table string) string {
+	quote := quoteLiteral(string(exportCSV.quote))
+	return fmt.Sprintf("INSERT INTO %s SELECT * FROM read_csv(%s, header = %t, delim = %s, quote = %s, escape = %s, all_varchar = true); SELECT count(*) FROM %s;",
+		duckdbIdent(table), quoteLiteral(loadFile), exportCSV.header, quoteLiteral(string(exportCSV.delimiter)), quote, quote, duckdbIdent(table))
+}
+
+// runDuckDB runs the statements with the duckdb client and returns what it
+// printed, as CSV without a header.
+func runDuckDB(ctx context.Context, sql string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, duckdbCLI, duckdbDatabase, "-csv", "-noheader", "-c", sql)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("duckdb failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// loadWithDuckDB loads the file into DUCKDB_DATABASE, parsed by DuckDB. The
+// time includes starting the duckdb client.
+func loadWithDuckDB(ctx context.Context, res chan<- Result) error {
+	create := func(ctx context.Context, table string, columns []string) error {
+		types, err := loadColumnTypes(ctx, columns)
+		if err != nil {
+			return err
+		}
+		_, err = runDuckDB(ctx, duckdbCreateTableQuery(table, columns, types))
+		return err
+	}
+	return runLoad(ctx, res, "load_duckdb", create, func(ctx context.Context, table string, columns []string, result *Result) (int64, error) {
+		out, err := runDuckDB(ctx, duckdbLoadQuery(table))
+		if err != nil {
+			return 0, err
+		}
+		return lastCount(out)
+	})
+}
+
+func clickhouseIdent(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+func clickhouseCreateTableQuery(table string, columns []string, types []warehouseType) string {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		// The exports write NULL as an empty value, which loads as NULL
+		definitions[i] = fmt.Sprintf("%s Nullable(%s)", clickhouseIdent(column), types[i].clickhouse)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s) ENGINE = MergeTree ORDER BY tuple()", clickhouseIdent(table), strings.Join(definitions, ", "))
+}
+
+func clickhouseInsertQuery(table string) string {
+	format := "CSV"
+	if exportCSV.header {
+		format = "CSVWithNames"
+	}
+	return fmt.Sprintf("INSERT INTO %s FORMAT %s", clickhouseIdent(table), format)
+}
+
+// clickhouseCSVSettings are the settings that read the load file in the CSV
+// dialect of the exports.
+func clickhouseCSVSettings() url.Values {
+	settings := url.Values{}
+	settings.Set("format_csv_delimiter", string(exportCSV.delimiter))
+	settings.Set("format_csv_allow_double_quotes", strconv.FormatBool(exportCSV.quote == '"'))
+	settings.Set("format_csv_allow_single_quotes", strconv.FormatBool(exportCSV.quote == '\''))
+	settings.Set("date_time_input_format", "best_effort")
+	return settings
+}
+
+// clickhouseQuery sends the query to CLICKHOUSE_URL with its data in body, if
+// any, and returns the response. Credentials are taken from the URL.
+func clickhouseQuery(ctx context.Context, query string, settings url.Values, body io.Reader) (string, error) {
+	u, err := url.Parse(clickhouseURL)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	params.Set("query", query)
+	for name, values := range settings {
+		params[name] = values
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: clickhouseTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("clickhouse request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading clickhouse response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("clickhouse answered %s: %s", resp.Status, strings.TrimSpace(string(content)))
+	}
+	return string(content), nil
+}
+
+// loadWithClickHouse streams the file as it is to an INSERT of CLICKHOUSE_URL,
+// parsed by ClickHouse into a MergeTree table.
+func loadWithClickHouse(ctx context.Context, res chan<- Result) error {
+	create := func(ctx context.Context, table string, columns []string) error {
+		types, err := loadColumnTypes(ctx, columns)
+		if err != nil {
+			return err
+		}
+		if _, err := clickhouseQuery(ctx, "DROP TABLE IF EXISTS "+clickhouseIdent(table), nil, nil); err != nil {
+			return err
+		}
+		_, err = clickhouseQuery(ctx, clickhouseCreateTableQuery(table, columns, types), nil, nil)
+		return err
+	}
+	return runLoad(ctx, res, "load_clickhouse", create, func(ctx context.Context, table string, columns []string, result *Result) (int64, error) {
+		file, err := os.Open(loadFile)
+		if err != nil {
+			return 0, fmt.Errorf("error opening LOAD_FILE: %v", err)
+		}
+		defer file.Close()
+
+		if _, err := clickhouseQuery(ctx, clickhouseInsertQuery(table), clickhouseCSVSettings(), file); err != nil {
+			return 0, err
+		}
+		out, err := clickhouseQuery(ctx, "SELECT count() FROM "+clickhouseIdent(table), nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		return lastCount(out)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestLastCount(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    int64
+		wantErr bool
+	}{
+		{out: "1000\n", want: 1000},
+		{out: "\n42\n", want: 42},
+		{out: "0", want: 0},
+		{out: "", wantErr: true},
+		{out: "count\nmany\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := lastCount(tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lastCount(%q) error = %v, want error %v", tt.out, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("lastCount(%q) = %d, want %d", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestWarehouseCreateTableQueries(t *testing.T) {
+	columns := []string{"id", `na"me`}
+	types := []warehouseType{warehouseTypes[pgtype.Int8OID], warehouseText}
+
+	want := `DROP TABLE IF EXISTS "bench_load_duckdb"; CREATE TABLE "bench_load_duckdb" ("id" BIGINT, "na""me" VARCHAR);`
+	if got := duckdbCreateTableQuery("bench_load_duckdb", columns, types); got != want {
+		t.Errorf("duckdbCreateTableQuery = %s, want %s", got, want)
+	}
+
+	want = "CREATE TABLE `bench_load_clickhouse` (`id` Nullable(Int64), `na\"me` Nullable(String)) ENGINE = MergeTree ORDER BY tuple()"
+	if got := clickhouseCreateTableQuery("bench_load_clickhouse", columns, types); got != want {
+		t.Errorf("clickhouseCreateTableQuery = %s, want %s", got, want)
+	}
+}
+
+func TestClickHouseFollowsCSVDialect(t *testing.T) {
+	defer func(d csvDialect) { exportCSV = d }(exportCSV)
+
+	exportCSV = csvDialect{header: true, delimiter: ',', quote: '"'}
+	if got, want := clickhouseInsertQuery("t"), "INSERT INTO `t` FORMAT CSVWithNames"; got != want {
+		t.Errorf("clickhouseInsertQuery = %s, want %s", got, want)
+	}
+
+	exportCSV = csvDialect{header: false, delimiter: ';', quote: '\''}
+	if got, want := clickhouseInsertQuery("t"), "INSERT INTO `t` FORMAT CSV"; got != want {
+		t.Errorf("clickhouseInsertQuery = %s, want %s", got, want)
+	}
+	settings := clickhouseCSVSettings()
+	for name, want := range map[string]string{
+		"format_csv_delimiter":           ";",
+		"format_csv_allow_double_quotes": "false",
+		"format_csv_allow_single_quotes": "true",
+	} {
+		if got := settings.Get(name); got != want {
+			t.Errorf("clickhouseCSVSettings %s = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// writeCompare adds the update, upsert, merge and delete strategies, which
+// write the key range instead of reading it.
+var writeCompare bool
+
+// WriteAmplification describes what a write strategy cost the server beyond
+// the rows it wrote. WAL is read server wide, so it includes the writes of
+// any strategy running at the same time.
+type WriteAmplification struct {
+	Table string
+	Rows  int64
+	// WAL is the bytes of WAL written while the strategy ran.
+	WAL int64
+	// Growth is how much the table with its indexes grew, mostly from the
+	// dead row versions the writes left behind.
+	Growth int64
+
+	// Vacuums and Vacuum are the count and time of the vacuums the delete
+	// strategy ran between its chunks.
+	Vacuums int
+	Vacuum  time.Duration
+}
+
+// createWriteTable creates the copy of the benchmark table a write strategy
+// modifies and returns the WAL position and table size its writes start from.
+func createWriteTable(ctx context.Context, table string) (string, int64, error) {
+	for _, statement := range append(createWriteTableQueries(table), vacuumQuery(table)) {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			return "", 0, fmt.Errorf("failed to create %s: %w", table, err)
+		}
+	}
+
+	var lsn string
+	var size int64
+	if err := pool.QueryRow(ctx, walPositionQuery(), table).Scan(&lsn, &size); err != nil {
+		return "", 0, fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	return lsn, size, nil
+}
+
+// measure records the WAL written since lsn and how much the table grew from
+// size.
+func (w *WriteAmplification) measure(ctx context.Context, lsn string, size int64) error {
+	var grown int64
+	if err := pool.QueryRow(ctx, walSinceQuery(), lsn, w.Table).Scan(&w.WAL, &grown); err != nil {
+		return fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	w.Growth = grown - size
+	return nil
+}
+
+// runWrite runs a write strategy page by page over its own copy of the
+// benchmark table, bench_<strategy>. page is the statement that writes the
+// page after the bound key and returns the written keys. For statements that
+// can not return them, keys is the query that reads the keys of the page,
+// run in the same round trip before the statement.
+func runWrite(ctx context.Context, res chan<- Result, name, page, keys string) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:  name,
+		Write: &WriteAmplification{Table: "bench_" + name},
+	}
+	table := result.Write.Table
+
+	lsn, size, err := createWriteTable(ctx, table)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Time the writes only, not copying their table
+	start = time.Now()
+	var lastId int
+	for {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		var rows pgx.Rows
+		finish := func() error { return nil }
+		if keys == "" {
+			rows, err = pool.Query(bctx, page, keysetPageArgs(lastId)...)
+		} else {
+			batch := &pgx.Batch{}
+			batch.Queue(keys, keysetPageArgs(lastId)...)
+			batch.Queue(page, keysetPageArgs(lastId)...)
+			br := pool.SendBatch(bctx, batch)
+			finish = func() error {
+				_, err := br.Exec()
+				if cerr := br.Close(); err == nil {
+					err = cerr
+				}
+				return err
+			}
+			if rows, err = br.Query(); err != nil {
+				br.Close()
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to write page: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		// The keys come back in no particular order
+		firstId := lastId + 1
+		var count int
+		for rows.Next() {
+			var key int
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				finish()
+				err = fmt.Errorf("failed to scan key: %w", err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			lastId = max(lastId, key)
+			count++
+		}
+		rows.Close()
+		err = rows.Err()
+		if ferr := finish(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to write page: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+
+		if count == 0 {
+			break
+		}
+		result.Write.Rows += int64(count)
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", firstId, lastId),
+			Rows:     count,
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		result.Paused += control.Wait(ctx)
+		result.ThinkTime += think.Sleep(ctx)
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	if err := result.Write.measure(ctx, lsn, size); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	result.Duration = duration
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// writeWithUpdate updates the rows of its table in pages with UPDATE.
+func writeWithUpdate(ctx context.Context, res chan<- Result) error {
+	return runWrite(ctx, res, "update", updatePageQuery("bench_update"), "")
+}
+
+// writeWithUpsert writes the rows of the benchmark table over the rows of its
+// table in pages with INSERT ... ON CONFLICT DO UPDATE.
+func writeWithUpsert(ctx context.Context, res chan<- Result) error {
+	return runWrite(ctx, res, "upsert", upsertPageQuery("bench_upsert"), "")
+}
+
+// writeWithMerge writes the rows of the benchmark table over the rows of its
+// table in pages with MERGE, which needs PostgreSQL 15. Before PostgreSQL 17
+// MERGE has no RETURNING, so the keys of every page are read alongside it.
+func writeWithMerge(ctx context.Context, res chan<- Result) error {
+	if server.MergeReturning {
+		return runWrite(ctx, res, "merge", mergePageQuery("bench_merge", true), "")
+	}
+	return runWrite(ctx, res, "merge", mergePageQuery("bench_merge", false), keysetPageQuery(keyName()))
+}
+
+// deleteChunk is the width of the key ranges the delete strategy deletes at a
+// time, DATA_BATCH_SIZE when zero, and deleteVacuumEvery the chunks after
+// which it vacuums its table, never when zero.
+var deleteChunk, deleteVacuumEvery int
+
+// writeWithDelete deletes the key range of its table in chunks of
+// DELETE_CHUNK keys, vacuuming the table every DELETE_VACUUM_EVERY chunks like
+// a long running purge would.
+func writeWithDelete(ctx context.Context, res chan<- Result) error {
+	defer wg.Done()
+	start := time.Now()
+	result := Result{
+		Type:  "delete",
+		Write: &WriteAmplification{Table: "bench_delete"},
+	}
+	table := result.Write.Table
+
+	lsn, size, err := createWriteTable(ctx, table)
+	if err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	// Chunk the keys that are there, not the keys that could be
+	var first, last *int
+	if err := pool.QueryRow(ctx, keyBoundsQuery()).Scan(&first, &last); err != nil {
+		err = fmt.Errorf("failed to read key range: %w", err)
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	chunk := deleteChunk
+	if chunk <= 0 {
+		chunk = batchSize
+	}
+
+	// Time the deletes only, not copying their table
+	start = time.Now()
+	for low := 0; first != nil && *first+low <= *last; low += chunk {
+		batchStart := time.Now()
+		bctx, timings := traceQueries(ctx)
+
+		from, to := *first+low, *first+low+chunk-1
+		tag, err := pool.Exec(bctx, deleteChunkQuery(table), from, to)
+		if err != nil {
+			err = fmt.Errorf("failed to delete chunk: %w", err)
+			result.Err = err
+			res <- result
+			return err
+		}
+		result.Write.Rows += tag.RowsAffected()
+
+		result.addBatch(ctx, Batch{
+			Seq:      len(result.Batches) + 1,
+			Start:    batchStart,
+			Key:      fmt.Sprintf("aid %d..%d", from, to),
+			Rows:     int(tag.RowsAffected()),
+			Duration: time.Since(batchStart),
+
+			QueryTimings: *timings,
+		})
+
+		if deleteVacuumEvery > 0 && len(result.Batches)%deleteVacuumEvery == 0 {
+			vacuumStart := time.Now()
+			if _, err := pool.Exec(ctx, vacuumQuery(table)); err != nil {
+				err = fmt.Errorf("failed to vacuum %s: %w", table, err)
+				result.Err = err
+				res <- result
+				return err
+			}
+			result.Write.Vacuums++
+			result.Write.Vacuum += time.Since(vacuumStart)
+		}
+
+		result.Paused += control.Wait(ctx)
+		result.ThinkTime += think.Sleep(ctx)
+	}
+
+	end := time.Now()
+	duration := end.Sub(start)
+
+	if err := result.Write.measure(ctx, lsn, size); err != nil {
+		result.Err = err
+		res <- result
+		return err
+	}
+
+	result.Duration = duration
+	result.Message = fmt.Sprintf("%.2f second", duration.Seconds())
+	res <- result
+
+	return nil
+}
+
+// compareWrites compares the throughput and write amplification of the
+// write strategies.
+func compareWrites(results []Result) []string {
+	var lines []string
+	for _, r := range results {
+		w := r.Write
+		if r.Err != nil || w == nil || w.Rows == 0 || r.Duration == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s wrote %s rows at %s rows/sec, %s bytes of WAL per row, %s grew by %s bytes",
+			r.Type, human.Int(w.Rows), human.Float(float64(w.Rows)/r.Duration.Seconds(), 0),
+			human.Float(float64(w.WAL)/float64(w.Rows), 0), w.Table, human.Int(w.Growth)))
+		if w.Vacuums > 0 {
+			lines = append(lines, fmt.Sprintf("%s ran %d vacuums between its chunks in %s",
+				r.Type, w.Vacuums, human.Duration(w.Vacuum)))
+		}
+	}
+	return lines
+}